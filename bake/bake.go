@@ -45,6 +45,9 @@ var (
 type File struct {
 	Name string
 	Data []byte
+
+	// namespace is the name of the import block the file was loaded from
+	namespace string
 }
 
 type ParseOpt struct {
@@ -269,10 +272,59 @@ func ReadTargets(ctx context.Context, files []File, targets, overrides []string,
 			return nil, nil, err
 		}
 	}
+	for name, t := range targetsMap {
+		if err := c.loadDependencies(name, t, targetsMap, o, nil, ent); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := validateTargets(targetsMap, groupsMap); err != nil {
+		return nil, nil, formatHCLError(err, files)
+	}
 
 	return targetsMap, groupsMap, nil
 }
 
+// validateTargets evaluates the validation blocks of the resolved targets and
+// groups, with self set to the target or group they are declared in.
+func validateTargets(targets map[string]*Target, groups map[string]*Group) error {
+	var diags hcl.Diagnostics
+	validate := func(kind, name string, v any, validations []*hclparser.Validation) error {
+		if len(validations) == 0 {
+			return nil
+		}
+		typ, err := hclparser.ImpliedType(v)
+		if err != nil {
+			return err
+		}
+		self, err := hclparser.ToCtyValue(v, typ)
+		if err != nil {
+			return err
+		}
+		for _, validation := range validations {
+			for _, d := range validation.Validate(self) {
+				d.Summary = fmt.Sprintf("%s for %s %q", d.Summary, kind, name)
+				diags = append(diags, d)
+			}
+		}
+		return nil
+	}
+	for _, name := range slices.Sorted(maps.Keys(groups)) {
+		if err := validate("group", name, groups[name], groups[name].validations); err != nil {
+			return err
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(targets)) {
+		if err := validate("target", name, targets[name], targets[name].validations); err != nil {
+			return err
+		}
+	}
+	if diags.HasErrors() {
+		return diags
+	}
+	return nil
+}
+
 func dedupSlice(s []string) []string {
 	if len(s) == 0 {
 		return s
@@ -366,6 +418,11 @@ func ParseFiles(files []File, defaults, vars map[string]string, opts ...ParseOpt
 				if err != nil {
 					return nil, nil, err
 				}
+				if f.namespace != "" {
+					if err := namespaceHCLFile(hf, f.namespace); err != nil {
+						return nil, nil, err
+					}
+				}
 				hclFiles = append(hclFiles, hf)
 			} else if composeErr != nil {
 				return nil, nil, errors.Wrapf(err, "failed to parse %s: parsing yaml: %v, parsing hcl", f.Name, composeErr)
@@ -474,7 +531,7 @@ func (t *Target) rebaseContextPaths() {
 }
 
 func localFileDir(name string) (string, bool) {
-	if name == "" || name == "-" || urlutil.IsRemoteURL(name) {
+	if name == "" || name == "-" || urlutil.IsRemoteURL(name) || strings.HasPrefix(name, ociImportPrefix) {
 		return "", false
 	}
 	return filepath.Dir(name), true
@@ -645,6 +702,37 @@ func (c Config) loadLinks(name string, t *Target, m map[string]*Target, o map[st
 	return nil
 }
 
+// loadDependencies resolves the targets listed in depends_on that have not
+// been requested, so they are built before the dependent target. Unlike linked
+// targets, dependencies keep their outputs.
+func (c Config) loadDependencies(name string, t *Target, m map[string]*Target, o map[string]map[string]Override, visited []string, ent *EntitlementConf) error {
+	visited = append(visited, name)
+	for _, target := range t.DependsOn {
+		if target == name {
+			return errors.Errorf("target %s cannot depend on itself", target)
+		}
+		if slices.Contains(visited, target) {
+			return errors.Errorf("infinite loop from %s to %s", name, target)
+		}
+		t2, ok := m[target]
+		if !ok {
+			var err error
+			t2, err = c.ResolveTarget(target, o, ent)
+			if err != nil {
+				return err
+			}
+			m[target] = t2
+			if err := c.loadLinks(target, t2, m, o, nil, ent); err != nil {
+				return err
+			}
+		}
+		if err := c.loadDependencies(target, t2, m, o, visited, ent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c Config) newOverrides(v []string) (map[string]map[string]Override, error) {
 	m := map[string]map[string]Override{}
 	for _, v := range v {
@@ -680,12 +768,12 @@ func (c Config) newOverrides(v []string) (map[string]map[string]Override, error)
 			// IMPORTANT: if you add more fields here, do not forget to update
 			// docs/reference/buildx_bake.md (--set) and https://docs.docker.com/build/bake/overrides/
 			switch keys[1] {
-			case "output", "cache-to", "cache-from", "tags", "platform", "secrets", "ssh", "attest", "entitlements", "network", "annotations", "policy":
+			case "output", "cache-to", "cache-from", "tags", "platform", "secrets", "ssh", "attest", "entitlements", "network", "annotations", "policy", "depends_on":
 				if len(parts) == 2 {
 					override.Append = appendTo
 					override.ArrValue = append(override.ArrValue, parts[1])
 				}
-			case "resources", "secret":
+			case "resources", "retry", "secret":
 				if len(keys) != 3 {
 					return nil, errors.Errorf("invalid key %s, %s requires name", parts[0], keys[1])
 				}
@@ -826,6 +914,8 @@ type Group struct {
 	Description string   `json:"description,omitempty" hcl:"description,optional" cty:"description"`
 	Targets     []string `json:"targets" hcl:"targets" cty:"targets"`
 	// Target // TODO?
+
+	validations []*hclparser.Validation
 }
 
 type Target struct {
@@ -862,11 +952,18 @@ type Target struct {
 	Entitlements     []string                    `json:"entitlements,omitempty" hcl:"entitlements,optional" cty:"entitlements"`
 	ExtraHosts       map[string]*string          `json:"extra-hosts,omitempty" hcl:"extra-hosts,optional" cty:"extra-hosts"`
 	Policy           buildflags.PolicyConfigs    `json:"policy,omitempty" hcl:"policy,optional" cty:"policy"`
+	DependsOn        []string                    `json:"depends_on,omitempty" hcl:"depends_on,optional" cty:"depends_on"`
+	KeepGoing        *bool                       `json:"keep-going,omitempty" hcl:"keep-going,optional" cty:"keep-going"`
+	Retry            *buildflags.RetryConfig     `json:"retry,omitempty" hcl:"retry,optional" cty:"retry"`
 	// IMPORTANT: if you add more fields here, do not forget to update newOverrides/AddOverrides and docs/bake-reference.md.
 
 	// linked is a private field to mark a target used as a linked one
 	linked bool
 
+	// validations are the validation blocks of the target and the ones it
+	// inherits from
+	validations []*hclparser.Validation
+
 	defaultContextBase    string
 	hasDefaultContextBase bool
 	useDefaultContextBase bool
@@ -923,10 +1020,20 @@ var (
 	_ hclparser.WithEvalContexts = &Target{}
 	_ hclparser.WithGetName      = &Target{}
 	_ hclparser.WithBlockSource  = &Target{}
+	_ hclparser.WithValidations  = &Target{}
 	_ hclparser.WithEvalContexts = &Group{}
 	_ hclparser.WithGetName      = &Group{}
+	_ hclparser.WithValidations  = &Group{}
 )
 
+func (t *Target) AddValidations(validations []*hclparser.Validation) {
+	t.validations = append(t.validations, validations...)
+}
+
+func (g *Group) AddValidations(validations []*hclparser.Validation) {
+	g.validations = append(g.validations, validations...)
+}
+
 func (t *Target) SetBlockSource(block *hcl.Block) {
 	base, _ := localFileDir(block.DefRange.Filename)
 	t.defaultContextBase = base
@@ -1016,6 +1123,7 @@ func (t *Target) normalize() {
 	}
 
 	t.Entitlements = removeDupesStr(t.Entitlements)
+	t.DependsOn = removeDupesStr(t.DependsOn)
 
 	for k, v := range t.Contexts {
 		if v == "" {
@@ -1140,6 +1248,15 @@ func (t *Target) Merge(t2 *Target) {
 	if t2.Entitlements != nil { // merge
 		t.Entitlements = append(t.Entitlements, t2.Entitlements...)
 	}
+	if t2.DependsOn != nil { // merge
+		t.DependsOn = append(t.DependsOn, t2.DependsOn...)
+	}
+	if t2.KeepGoing != nil {
+		t.KeepGoing = t2.KeepGoing
+	}
+	if t2.Retry != nil { // merge
+		t.Retry = t.Retry.Merge(t2.Retry)
+	}
 	for k, v := range t2.ExtraHosts {
 		if v == nil {
 			continue
@@ -1149,6 +1266,7 @@ func (t *Target) Merge(t2 *Target) {
 		}
 		t.ExtraHosts[k] = v
 	}
+	t.validations = slices.Concat(t.validations, t2.validations)
 	t.Inherits = append(t.Inherits, t2.Inherits...)
 }
 
@@ -1307,6 +1425,12 @@ func (t *Target) AddOverrides(overrides map[string]Override, ent *EntitlementCon
 			}
 		case "annotations":
 			t.Annotations = append(t.Annotations, o.ArrValue...)
+		case "depends_on":
+			if o.Append {
+				t.DependsOn = append(t.DependsOn, o.ArrValue...)
+			} else {
+				t.DependsOn = o.ArrValue
+			}
 		case "attest":
 			attest, err := parseArrValue[buildflags.Attest](o.ArrValue)
 			if err != nil {
@@ -1351,6 +1475,22 @@ func (t *Target) AddOverrides(overrides map[string]Override, ent *EntitlementCon
 				return errors.Errorf("invalid value %s for boolean key pull", value)
 			}
 			t.Pull = &pull
+		case "keep-going":
+			keepGoing, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.Errorf("invalid value %s for boolean key keep-going", value)
+			}
+			t.KeepGoing = &keepGoing
+		case "retry":
+			if len(keys) != 2 {
+				return errors.Errorf("invalid format for retry, expecting retry.<name>=<value>")
+			}
+			if t.Retry == nil {
+				t.Retry = &buildflags.RetryConfig{}
+			}
+			if err := t.Retry.SetField(keys[1], value); err != nil {
+				return err
+			}
 		case "push":
 			push, err := strconv.ParseBool(value)
 			if err != nil {
@@ -1743,6 +1883,10 @@ func toBuildOpt(t *Target, inp *Input) (*build.Options, error) {
 	if t.Pull != nil {
 		pull = *t.Pull
 	}
+	keepGoing := false
+	if t.KeepGoing != nil {
+		keepGoing = *t.KeepGoing
+	}
 	networkMode := ""
 	if t.NetworkMode != nil {
 		networkMode = *t.NetworkMode
@@ -1773,6 +1917,7 @@ func toBuildOpt(t *Target, inp *Input) (*build.Options, error) {
 		Pull:          pull,
 		NetworkMode:   networkMode,
 		Linked:        t.linked,
+		KeepGoing:     keepGoing,
 		ShmSize:       *shmSize,
 		ExtraHosts:    extraHosts,
 	}
@@ -1860,7 +2005,14 @@ func toBuildOpt(t *Target, inp *Input) (*build.Options, error) {
 	}
 	bo.ResourceLimits = resourceLimits
 
+	retry, err := build.ParseRetryPolicy(t.Retry)
+	if err != nil {
+		return nil, err
+	}
+	bo.Retry = retry
+
 	bo.Allow = append(bo.Allow, t.Entitlements...)
+	bo.DependsOn = t.DependsOn
 
 	return bo, nil
 }
@@ -8,8 +8,11 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/docker/buildx/build"
 	"github.com/docker/buildx/util/buildflags"
+	"github.com/moby/buildkit/solver/errdefs"
 	"github.com/moby/buildkit/util/entitlements"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -1140,6 +1143,149 @@ func TestReadContexts(t *testing.T) {
 	require.Equal(t, "def", ctxs["abc"].Path)
 }
 
+func TestReadDependsOn(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+		target "lint" {
+			call = "check"
+		}
+		target "test" {
+			depends_on = ["lint"]
+		}
+		target "app" {
+			depends_on = ["test"]
+			tags = ["app"]
+		}
+		`),
+	}
+
+	ctx := context.TODO()
+	m, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, []string{}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Len(t, m, 3)
+	require.Equal(t, []string{"test"}, m["app"].DependsOn)
+	require.Equal(t, []string{"lint"}, m["test"].DependsOn)
+	require.Empty(t, m["app"].Contexts)
+	require.False(t, m["test"].linked)
+
+	bo, err := TargetsToBuildOpt(m, &Input{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"test"}, bo["app"].DependsOn)
+	require.Empty(t, bo["app"].Inputs.NamedContexts)
+
+	m, _, err = ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.depends_on=lint"}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Len(t, m, 2)
+	require.Equal(t, []string{"lint"}, m["app"].DependsOn)
+}
+
+func TestReadKeepGoing(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+		target "_common" {
+			keep-going = true
+		}
+		target "app" {
+			inherits = ["_common"]
+		}
+		target "lint" {
+		}
+		`),
+	}
+
+	ctx := context.TODO()
+	m, _, err := ReadTargets(ctx, []File{fp}, []string{"app", "lint"}, []string{}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	bo, err := TargetsToBuildOpt(m, &Input{})
+	require.NoError(t, err)
+	require.True(t, bo["app"].KeepGoing)
+	require.False(t, bo["lint"].KeepGoing)
+
+	m, _, err = ReadTargets(ctx, []File{fp}, []string{"app", "lint"}, []string{"*.keep-going=false"}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	bo, err = TargetsToBuildOpt(m, &Input{})
+	require.NoError(t, err)
+	require.False(t, bo["app"].KeepGoing)
+	require.False(t, bo["lint"].KeepGoing)
+
+	_, _, err = ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"*.keep-going=maybe"}, nil, nil, &EntitlementConf{})
+	require.ErrorContains(t, err, "invalid value maybe for boolean key keep-going")
+}
+
+func TestReadRetry(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+		target "_common" {
+			retry = {
+				attempts = 4
+				backoff = "10s"
+			}
+		}
+		target "app" {
+			inherits = ["_common"]
+			retry = {
+				on = ["push"]
+			}
+		}
+		target "lint" {
+		}
+		`),
+	}
+
+	ctx := context.TODO()
+	m, _, err := ReadTargets(ctx, []File{fp}, []string{"app", "lint"}, []string{}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), *m["app"].Retry.Attempts)
+	require.Equal(t, ptrstr("10s"), m["app"].Retry.Backoff)
+	require.Equal(t, []string{"push"}, m["app"].Retry.On)
+	require.Nil(t, m["lint"].Retry)
+
+	bo, err := TargetsToBuildOpt(m, &Input{})
+	require.NoError(t, err)
+	require.Equal(t, &build.RetryPolicy{Attempts: 4, Backoff: 10 * time.Second, On: []string{"push"}}, bo["app"].Retry)
+	require.Nil(t, bo["lint"].Retry)
+
+	m, _, err = ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.retry.attempts=2", "app.retry.on=push,cache-import"}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), *m["app"].Retry.Attempts)
+	require.Equal(t, []string{"push", "cache-import"}, m["app"].Retry.On)
+
+	_, _, err = ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.retry.attempts=many"}, nil, nil, &EntitlementConf{})
+	require.ErrorContains(t, err, "invalid value many for int64 key retry.attempts")
+
+	m, _, err = ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.retry.on=network"}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	_, err = TargetsToBuildOpt(m, &Input{})
+	require.ErrorContains(t, err, `invalid retry class "network"`)
+}
+
+func TestReadDependsOnLoop(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+		target "a" {
+			depends_on = ["b"]
+		}
+		target "b" {
+			depends_on = ["a"]
+		}
+		target "c" {
+			depends_on = ["c"]
+		}
+		`),
+	}
+
+	ctx := context.TODO()
+	_, _, err := ReadTargets(ctx, []File{fp}, []string{"a"}, []string{}, nil, nil, &EntitlementConf{})
+	require.ErrorContains(t, err, "infinite loop")
+
+	_, _, err = ReadTargets(ctx, []File{fp}, []string{"c"}, []string{}, nil, nil, &EntitlementConf{})
+	require.ErrorContains(t, err, "cannot depend on itself")
+}
+
 func TestReadContextFromTargetUnknown(t *testing.T) {
 	fp := File{
 		Name: "docker-bake.hcl",
@@ -2636,6 +2782,84 @@ target "app" {
 	require.Contains(t, err.Error(), "This check failed, but has an invalid error message")
 }
 
+func TestTargetValidation(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+target "_common" {
+  validation {
+    condition = !contains([for o in self.output : o.type], "registry") || contains([for a in self.attest : a.type], "provenance")
+    error_message = "Targets pushing to a registry must set provenance."
+  }
+}
+target "app" {
+  inherits = ["_common"]
+  name = "app-${tgt}"
+  matrix = {
+    tgt = ["foo", "bar"]
+  }
+  tags = ["registry.example.com/app:${tgt}"]
+  validation {
+    condition = length([for tag in self.tags : tag if !can(regex("^registry.example.com/", tag))]) == 0
+    error_message = "Tags of ${tgt} must be pushed to registry.example.com."
+  }
+}
+`),
+	}
+
+	ctx := context.TODO()
+
+	t.Run("Valid", func(t *testing.T) {
+		m, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, nil, nil, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.Len(t, m, 2)
+	})
+
+	t.Run("Push", func(t *testing.T) {
+		_, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"*.output=type=registry"}, nil, nil, &EntitlementConf{})
+		require.ErrorContains(t, err, `Validation failed for target "app-bar"`)
+		require.ErrorContains(t, err, "Targets pushing to a registry must set provenance.")
+
+		_, _, err = ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"*.output=type=registry", "*.attest=type=provenance"}, nil, nil, &EntitlementConf{})
+		require.NoError(t, err)
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		_, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app-foo.tags=docker.io/app:foo"}, nil, nil, &EntitlementConf{})
+		require.ErrorContains(t, err, "Tags of foo must be pushed to registry.example.com.")
+		require.NotContains(t, err.Error(), "Tags of bar")
+
+		var se *errdefs.SourceError
+		require.ErrorAs(t, err, &se)
+		require.Equal(t, "docker-bake.hcl", se.Info.Filename)
+		require.Equal(t, int32(16), se.Ranges[0].Start.Line)
+	})
+}
+
+func TestGroupValidation(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+group "release" {
+  targets = ["app"]
+  validation {
+    condition = contains(self.targets, "docs")
+    error_message = "Release must build the docs."
+  }
+}
+target "app" {}
+target "docs" {}
+`),
+	}
+
+	_, _, err := ReadTargets(context.TODO(), []File{fp}, []string{"release"}, nil, nil, nil, &EntitlementConf{})
+	require.ErrorContains(t, err, `Validation failed for group "release"`)
+	require.ErrorContains(t, err, "Release must build the docs.")
+
+	_, _, err = ReadTargets(context.TODO(), []File{fp}, []string{"app"}, nil, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+}
+
 // https://github.com/docker/buildx/issues/2822
 func TestVariableEmpty(t *testing.T) {
 	fp := File{
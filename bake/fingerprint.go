@@ -0,0 +1,246 @@
+package bake
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/docker/buildx/util/urlutil"
+	"github.com/moby/patternmatcher/ignorefile"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/fsutil"
+)
+
+// Fingerprints computes a content-addressed digest for each resolved target.
+// The digest covers the target definition (args, platforms, outputs, etc.),
+// the files of the local build context honoring .dockerignore, the
+// Dockerfile, local named contexts and the fingerprints of linked targets.
+//
+// Targets with inputs that cannot be addressed by content, like a remote
+// context or a named context referencing an image by tag, get an empty
+// fingerprint and must always be considered as changed.
+func Fingerprints(ctx context.Context, m map[string]*Target) (map[string]digest.Digest, error) {
+	f := &fingerprinter{
+		targets: m,
+		results: map[string]digest.Digest{},
+		dirs:    map[string]digest.Digest{},
+	}
+	res := make(map[string]digest.Digest, len(m))
+	for name := range m {
+		dgst, err := f.target(ctx, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		res[name] = dgst
+	}
+	return res, nil
+}
+
+type fingerprinter struct {
+	targets map[string]*Target
+	results map[string]digest.Digest
+	dirs    map[string]digest.Digest
+}
+
+func (f *fingerprinter) target(ctx context.Context, name string, visited []string) (digest.Digest, error) {
+	if dgst, ok := f.results[name]; ok {
+		return dgst, nil
+	}
+	if slices.Contains(visited, name) {
+		return "", errors.Errorf("infinite loop from %s to %s", visited[len(visited)-1], name)
+	}
+	visited = append(visited, name)
+
+	t, ok := f.targets[name]
+	if !ok {
+		return "", errors.Errorf("failed to find target %s", name)
+	}
+
+	tgt := *t
+	tgt.Description = ""
+	dt, err := json.Marshal(&tgt)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	writeField(h, "target", string(dt))
+
+	contextPath := "."
+	if t.Context != nil {
+		contextPath = *t.Context
+	}
+	contextPath = strings.TrimPrefix(contextPath, "cwd://")
+	if urlutil.IsRemoteURL(contextPath) {
+		return f.skip(name)
+	}
+
+	var excludes []string
+	if t.DockerfileInline == nil {
+		dockerfilePath := "Dockerfile"
+		if t.Dockerfile != nil {
+			dockerfilePath = *t.Dockerfile
+		}
+		if urlutil.IsRemoteURL(dockerfilePath) {
+			return f.skip(name)
+		}
+		if v, ok := strings.CutPrefix(dockerfilePath, "cwd://"); ok {
+			dockerfilePath = v
+		} else if !filepath.IsAbs(dockerfilePath) {
+			dockerfilePath = filepath.Join(contextPath, dockerfilePath)
+		}
+		dockerfile, err := os.ReadFile(dockerfilePath)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read dockerfile for target %s", name)
+		}
+		writeField(h, "dockerfile", string(dockerfile))
+
+		// a Dockerfile-specific ignore file takes precedence over the one at
+		// the root of the context
+		if excludes, err = readExcludes(dockerfilePath + ".dockerignore"); err != nil {
+			return "", err
+		}
+	}
+	if excludes == nil {
+		if excludes, err = readExcludes(filepath.Join(contextPath, ".dockerignore")); err != nil {
+			return "", err
+		}
+	}
+	contextDgst, err := f.dir(ctx, contextPath, excludes)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to compute context fingerprint for target %s", name)
+	}
+	writeField(h, "context", contextDgst.String())
+
+	for _, k := range slices.Sorted(maps.Keys(t.Contexts)) {
+		v := t.Contexts[k]
+		switch {
+		case strings.HasPrefix(v, "target:"):
+			dgst, err := f.target(ctx, strings.TrimPrefix(v, "target:"), visited)
+			if err != nil {
+				return "", err
+			}
+			if dgst == "" {
+				return f.skip(name)
+			}
+			writeField(h, "context:"+k, dgst.String())
+		case strings.HasPrefix(v, "docker-image://"), strings.HasPrefix(v, "oci-layout://"):
+			// images are only content-addressed when pinned by digest, which
+			// is already part of the target definition
+			if !strings.Contains(v, "@sha256:") {
+				return f.skip(name)
+			}
+		case urlutil.IsRemoteURL(v):
+			return f.skip(name)
+		default:
+			p := strings.TrimPrefix(v, "cwd://")
+			excludes, err := readExcludes(filepath.Join(p, ".dockerignore"))
+			if err != nil {
+				return "", err
+			}
+			dgst, err := f.dir(ctx, p, excludes)
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to compute fingerprint of context %s for target %s", k, name)
+			}
+			writeField(h, "context:"+k, dgst.String())
+		}
+	}
+
+	dgst := digest.NewDigestFromBytes(digest.SHA256, h.Sum(nil))
+	f.results[name] = dgst
+	return dgst, nil
+}
+
+func (f *fingerprinter) skip(name string) (digest.Digest, error) {
+	f.results[name] = ""
+	return "", nil
+}
+
+// dir computes the digest of a local directory tree. Paths matching the
+// exclude patterns are not taken into account.
+func (f *fingerprinter) dir(ctx context.Context, p string, excludes []string) (digest.Digest, error) {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	key := p + "\x00" + strings.Join(excludes, "\x00")
+	if dgst, ok := f.dirs[key]; ok {
+		return dgst, nil
+	}
+
+	h := sha256.New()
+	err = fsutil.Walk(ctx, p, &fsutil.FilterOpt{
+		ExcludePatterns: excludes,
+	}, func(rel string, fi fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel = path.Clean("/" + filepath.ToSlash(rel))
+		writeField(h, "path", rel)
+		writeField(h, "mode", fi.Mode().String())
+		switch {
+		case fi.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(filepath.Join(p, filepath.FromSlash(rel)))
+			if err != nil {
+				return err
+			}
+			writeField(h, "link", target)
+		case fi.Mode().IsRegular():
+			dgst, err := fileDigest(filepath.Join(p, filepath.FromSlash(rel)))
+			if err != nil {
+				return err
+			}
+			writeField(h, "data", dgst.String())
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	dgst := digest.NewDigestFromBytes(digest.SHA256, h.Sum(nil))
+	f.dirs[key] = dgst
+	return dgst, nil
+}
+
+func fileDigest(p string) (digest.Digest, error) {
+	fh, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+	return digest.SHA256.FromReader(fh)
+}
+
+func readExcludes(p string) ([]string, error) {
+	fh, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer fh.Close()
+	excludes, err := ignorefile.ReadAll(fh)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", p)
+	}
+	if excludes == nil {
+		excludes = []string{}
+	}
+	return excludes, nil
+}
+
+func writeField(w io.Writer, key, value string) {
+	_, _ = fmt.Fprintf(w, "%s\x00%d\x00%s", key, len(value), value)
+}
@@ -0,0 +1,81 @@
+package bake
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprints(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM scratch\nCOPY . .\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo"), []byte("foo"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored"), []byte("ignored"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".dockerignore"), []byte("ignored\n"), 0644))
+
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+target "base" {
+	context = "` + filepath.ToSlash(dir) + `"
+}
+target "app" {
+	context = "` + filepath.ToSlash(dir) + `"
+	contexts = {
+		base = "target:base"
+	}
+	args = {
+		FOO = "bar"
+	}
+}
+target "remote" {
+	context = "https://github.com/docker/buildx.git"
+}
+target "image" {
+	context = "` + filepath.ToSlash(dir) + `"
+	contexts = {
+		alpine = "docker-image://alpine:latest"
+	}
+}
+`),
+	}
+
+	ctx := context.TODO()
+	fingerprints := func(overrides ...string) map[string]string {
+		m, _, err := ReadTargets(ctx, []File{fp}, []string{"app", "remote", "image"}, overrides, nil, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		res, err := Fingerprints(ctx, m)
+		require.NoError(t, err)
+		out := map[string]string{}
+		for k, v := range res {
+			out[k] = v.String()
+		}
+		return out
+	}
+
+	fp1 := fingerprints()
+	require.Len(t, fp1, 4)
+	require.NotEmpty(t, fp1["app"])
+	require.NotEmpty(t, fp1["base"])
+	require.Empty(t, fp1["remote"])
+	require.Empty(t, fp1["image"])
+	require.Equal(t, fp1, fingerprints())
+
+	// ignored files don't change the fingerprint
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored"), []byte("changed"), 0644))
+	require.Equal(t, fp1, fingerprints())
+
+	// args are part of the fingerprint
+	fp2 := fingerprints("app.args.FOO=baz")
+	require.NotEqual(t, fp1["app"], fp2["app"])
+	require.Equal(t, fp1["base"], fp2["base"])
+
+	// changes in the context are propagated to linked targets
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo"), []byte("bar"), 0644))
+	fp3 := fingerprints()
+	require.NotEqual(t, fp1["base"], fp3["base"])
+	require.NotEqual(t, fp1["app"], fp3["app"])
+}
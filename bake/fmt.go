@@ -0,0 +1,27 @@
+package bake
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/pkg/errors"
+)
+
+// FormatFile returns the HCL definition in f rewritten in the canonical
+// format. Only HCL files can be formatted, JSON and compose files return an
+// error.
+func FormatFile(f File) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(f.Name)) {
+	case ".json", ".yml", ".yaml":
+		return nil, errors.Errorf("cannot format %s: only HCL files can be formatted", f.Name)
+	}
+	// hclwrite doesn't report syntax errors so the file is parsed first to
+	// avoid rewriting an invalid definition
+	if _, diags := hclsyntax.ParseConfig(f.Data, f.Name, hcl.InitialPos); diags.HasErrors() {
+		return nil, formatHCLError(diags, []File{f})
+	}
+	return hclwrite.Format(f.Data), nil
+}
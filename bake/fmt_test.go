@@ -0,0 +1,22 @@
+package bake
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatFile(t *testing.T) {
+	dt, err := FormatFile(File{
+		Name: "docker-bake.hcl",
+		Data: []byte("target \"app\" {\ntags=[\"foo\"]\n    dockerfile = \"Dockerfile\"\n}\n"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "target \"app\" {\n  tags       = [\"foo\"]\n  dockerfile = \"Dockerfile\"\n}\n", string(dt))
+
+	_, err = FormatFile(File{Name: "docker-bake.hcl", Data: []byte("target \"app\" {\n")})
+	require.Error(t, err)
+
+	_, err = FormatFile(File{Name: "docker-bake.json", Data: []byte("{}")})
+	require.ErrorContains(t, err, "only HCL files can be formatted")
+}
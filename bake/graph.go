@@ -0,0 +1,250 @@
+package bake
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/docker/buildx/bake/hclparser"
+	"github.com/pkg/errors"
+)
+
+const (
+	GraphFormatDOT     = "dot"
+	GraphFormatMermaid = "mermaid"
+	GraphFormatJSON    = "json"
+)
+
+const (
+	GraphNodeGroup  = "group"
+	GraphNodeTarget = "target"
+	GraphNodeMatrix = "matrix"
+
+	GraphEdgeGroup     = "group"
+	GraphEdgeInherits  = "inherits"
+	GraphEdgeContext   = "context"
+	GraphEdgeDependsOn = "depends_on"
+	GraphEdgeMatrix    = "matrix"
+)
+
+// Graph is the resolved graph of groups and targets of a bake invocation.
+// Edges go from the dependent node to its dependency.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+type GraphNode struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Linked is set for targets only built as a named context of another one
+	Linked bool `json:"linked,omitempty"`
+}
+
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+	// Context is the name of the named context for context edges
+	Context string `json:"context,omitempty"`
+}
+
+// NewGraph returns the graph of the resolved targets and groups. The parsed
+// config is used to resolve inherits and matrix expansions that are no
+// longer visible in the resolved targets.
+func NewGraph(c *Config, pm *hclparser.ParseMeta, tgts map[string]*Target, grps map[string]*Group) *Graph {
+	matrix := map[string][]string{}
+	if pm != nil {
+		for name, names := range pm.Renamed["target"] {
+			if len(names) == 1 && names[0] == name {
+				continue
+			}
+			matrix[name] = dedupSlice(names)
+		}
+	}
+
+	nodes := map[string]GraphNode{}
+	var edges []GraphEdge
+
+	for name, g := range grps {
+		if _, ok := matrix[name]; ok {
+			continue
+		}
+		nodes[name] = GraphNode{Name: name, Type: GraphNodeGroup}
+		for _, t := range g.Targets {
+			edges = append(edges, GraphEdge{From: name, To: t, Type: GraphEdgeGroup})
+		}
+	}
+
+	cfgTargets := map[string]*Target{}
+	if c != nil {
+		for _, t := range c.Targets {
+			cfgTargets[t.Name] = t
+		}
+	}
+	var addInherits func(name string)
+	addInherits = func(name string) {
+		t, ok := cfgTargets[name]
+		if !ok {
+			return
+		}
+		for _, parent := range dedupSlice(t.Inherits) {
+			edges = append(edges, GraphEdge{From: name, To: parent, Type: GraphEdgeInherits})
+			if _, ok := nodes[parent]; ok {
+				continue
+			}
+			nodes[parent] = GraphNode{Name: parent, Type: GraphNodeTarget}
+			addInherits(parent)
+		}
+	}
+
+	for name, t := range tgts {
+		nodes[name] = GraphNode{Name: name, Type: GraphNodeTarget, Linked: t.linked}
+		for k, v := range t.Contexts {
+			if target, ok := strings.CutPrefix(v, "target:"); ok {
+				edges = append(edges, GraphEdge{From: name, To: target, Type: GraphEdgeContext, Context: k})
+			}
+		}
+		for _, dep := range t.DependsOn {
+			edges = append(edges, GraphEdge{From: name, To: dep, Type: GraphEdgeDependsOn})
+		}
+	}
+	for name := range tgts {
+		addInherits(name)
+	}
+
+	for name, names := range matrix {
+		var expanded []string
+		for _, n := range names {
+			if _, ok := tgts[n]; ok {
+				expanded = append(expanded, n)
+			}
+		}
+		if len(expanded) == 0 {
+			continue
+		}
+		nodes[name] = GraphNode{Name: name, Type: GraphNodeMatrix}
+		for _, n := range expanded {
+			edges = append(edges, GraphEdge{From: name, To: n, Type: GraphEdgeMatrix})
+		}
+	}
+
+	g := &Graph{
+		Nodes: slices.SortedFunc(maps.Values(nodes), func(a, b GraphNode) int {
+			return cmp.Compare(a.Name, b.Name)
+		}),
+		Edges: edges,
+	}
+	slices.SortFunc(g.Edges, func(a, b GraphEdge) int {
+		return cmp.Or(
+			cmp.Compare(a.From, b.From),
+			cmp.Compare(a.To, b.To),
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.Context, b.Context),
+		)
+	})
+	g.Edges = slices.Compact(g.Edges)
+	return g
+}
+
+// Write writes the graph to w in the given format.
+func (g *Graph) Write(w io.Writer, format string) error {
+	switch format {
+	case GraphFormatDOT:
+		return g.writeDOT(w)
+	case GraphFormatMermaid:
+		return g.writeMermaid(w)
+	case GraphFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g)
+	default:
+		return errors.Errorf("invalid graph format %q", format)
+	}
+}
+
+func (g *Graph) writeDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph bake {\n")
+	for _, n := range g.Nodes {
+		var attrs []string
+		switch n.Type {
+		case GraphNodeGroup:
+			attrs = append(attrs, "shape=folder")
+		case GraphNodeMatrix:
+			attrs = append(attrs, "shape=box3d")
+		default:
+			attrs = append(attrs, "shape=box")
+		}
+		if n.Linked {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&sb, "  %s [%s];\n", strconv.Quote(n.Name), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		var attrs []string
+		switch e.Type {
+		case GraphEdgeInherits:
+			attrs = append(attrs, `label="inherits"`, "style=dashed")
+		case GraphEdgeContext:
+			attrs = append(attrs, "label="+strconv.Quote("context:"+e.Context))
+		case GraphEdgeDependsOn:
+			attrs = append(attrs, `label="depends_on"`, "style=bold")
+		case GraphEdgeMatrix:
+			attrs = append(attrs, `label="matrix"`, "style=dotted")
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(&sb, "  %s -> %s [%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(&sb, "  %s -> %s;\n", strconv.Quote(e.From), strconv.Quote(e.To))
+		}
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (g *Graph) writeMermaid(w io.Writer) error {
+	// node names are not valid mermaid identifiers in all cases (e.g. "end")
+	// so use generated ones and set the name as label
+	ids := make(map[string]string, len(g.Nodes))
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	for i, n := range g.Nodes {
+		id := "n" + strconv.Itoa(i)
+		ids[n.Name] = id
+		switch n.Type {
+		case GraphNodeGroup:
+			fmt.Fprintf(&sb, "  %s[(%q)]\n", id, n.Name)
+		case GraphNodeMatrix:
+			fmt.Fprintf(&sb, "  %s[[%q]]\n", id, n.Name)
+		default:
+			fmt.Fprintf(&sb, "  %s[%q]\n", id, n.Name)
+		}
+	}
+	for _, e := range g.Edges {
+		from, to := ids[e.From], ids[e.To]
+		if from == "" || to == "" {
+			continue
+		}
+		switch e.Type {
+		case GraphEdgeInherits:
+			fmt.Fprintf(&sb, "  %s -.->|inherits| %s\n", from, to)
+		case GraphEdgeContext:
+			fmt.Fprintf(&sb, "  %s -->|%q| %s\n", from, "context:"+e.Context, to)
+		case GraphEdgeDependsOn:
+			fmt.Fprintf(&sb, "  %s ==>|depends_on| %s\n", from, to)
+		case GraphEdgeMatrix:
+			fmt.Fprintf(&sb, "  %s -.->|matrix| %s\n", from, to)
+		default:
+			fmt.Fprintf(&sb, "  %s --> %s\n", from, to)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
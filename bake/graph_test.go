@@ -0,0 +1,78 @@
+package bake
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+group "default" {
+	targets = ["app", "lint"]
+}
+target "_common" {
+	args = {
+		GO_VERSION = "1.26"
+	}
+}
+target "base" {
+	inherits = ["_common"]
+}
+target "app" {
+	inherits = ["_common"]
+	contexts = {
+		base = "target:base"
+	}
+}
+target "lint" {
+	name = "lint-${tgt}"
+	matrix = {
+		tgt = ["go", "docs"]
+	}
+}
+`),
+	}
+
+	ctx := context.TODO()
+	tgts, grps, err := ReadTargets(ctx, []File{fp}, []string{"default"}, nil, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	cfg, pm, err := ParseFiles([]File{fp}, nil, nil)
+	require.NoError(t, err)
+
+	g := NewGraph(cfg, pm, tgts, grps)
+	require.Equal(t, []GraphNode{
+		{Name: "_common", Type: GraphNodeTarget},
+		{Name: "app", Type: GraphNodeTarget},
+		{Name: "base", Type: GraphNodeTarget, Linked: true},
+		{Name: "default", Type: GraphNodeGroup},
+		{Name: "lint", Type: GraphNodeMatrix},
+		{Name: "lint-docs", Type: GraphNodeTarget},
+		{Name: "lint-go", Type: GraphNodeTarget},
+	}, g.Nodes)
+	require.Equal(t, []GraphEdge{
+		{From: "app", To: "_common", Type: GraphEdgeInherits},
+		{From: "app", To: "base", Type: GraphEdgeContext, Context: "base"},
+		{From: "base", To: "_common", Type: GraphEdgeInherits},
+		{From: "default", To: "app", Type: GraphEdgeGroup},
+		{From: "default", To: "lint", Type: GraphEdgeGroup},
+		{From: "lint", To: "lint-docs", Type: GraphEdgeMatrix},
+		{From: "lint", To: "lint-go", Type: GraphEdgeMatrix},
+	}, g.Edges)
+
+	var buf bytes.Buffer
+	require.NoError(t, g.Write(&buf, GraphFormatDOT))
+	require.Contains(t, buf.String(), `"app" -> "base" [label="context:base"];`)
+	require.Contains(t, buf.String(), `"default" -> "app";`)
+
+	buf.Reset()
+	require.NoError(t, g.Write(&buf, GraphFormatMermaid))
+	require.Contains(t, buf.String(), `n1 -->|"context:base"| n2`)
+	require.Contains(t, buf.String(), `n4[["lint"]]`)
+
+	require.Error(t, g.Write(&buf, "svg"))
+}
@@ -7,6 +7,7 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/docker/buildx/bake/hclparser"
 	hcl "github.com/hashicorp/hcl/v2"
 	"github.com/stretchr/testify/require"
 )
@@ -2463,3 +2464,96 @@ func ptrstr(s any) *string {
 	}
 	return n
 }
+
+func TestHCLOutputs(t *testing.T) {
+	dt := []byte(`
+		variable "VERSION" {
+			default = "1.2.3"
+		}
+		variable "REPO" {
+			default = "docker/buildx"
+		}
+		function "tags" {
+			params = [version]
+			result = ["${REPO}:${version}", "${REPO}:latest"]
+		}
+		target "app" {
+			tags = tags(VERSION)
+		}
+		output "tags" {
+			type = list(string)
+			value = target.app.tags
+			description = "Final tags of the app image"
+		}
+		output "version" {
+			value = VERSION
+		}
+		output "major" {
+			type = number
+			value = split(".", VERSION)[0]
+		}
+		`)
+
+	_, pm, err := ParseFiles([]File{{Name: "docker-bake.hcl", Data: dt}}, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, pm.AllOutputs, 3)
+
+	outputs := map[string]*hclparser.Output{}
+	for _, o := range pm.AllOutputs {
+		outputs[o.Name] = o
+	}
+	require.Equal(t, "list of string", outputs["tags"].Type)
+	require.Equal(t, "Final tags of the app image", outputs["tags"].Description)
+	require.JSONEq(t, `["docker/buildx:1.2.3", "docker/buildx:latest"]`, string(outputs["tags"].Value))
+	require.Empty(t, outputs["version"].Type)
+	require.JSONEq(t, `"1.2.3"`, string(outputs["version"].Value))
+	require.Equal(t, "number", outputs["major"].Type)
+	require.JSONEq(t, `1`, string(outputs["major"].Value))
+
+	t.Setenv("VERSION", "2.0.0")
+	_, pm, err = ParseFiles([]File{{Name: "docker-bake.hcl", Data: dt}}, nil, nil)
+	require.NoError(t, err)
+	for _, o := range pm.AllOutputs {
+		if o.Name == "tags" {
+			require.JSONEq(t, `["docker/buildx:2.0.0", "docker/buildx:latest"]`, string(o.Value))
+		}
+	}
+}
+
+func TestHCLOutputsInvalid(t *testing.T) {
+	dt := []byte(`
+		output "tags" {
+			type = list(string)
+			value = { foo = "bar" }
+		}
+		`)
+	_, _, err := ParseFiles([]File{{Name: "docker-bake.hcl", Data: dt}}, nil, nil)
+	require.ErrorContains(t, err, "invalid type list of string for output tags")
+
+	dt = []byte(`
+		output "tags" {
+			value = target.app.tags
+		}
+		`)
+	_, _, err = ParseFiles([]File{{Name: "docker-bake.hcl", Data: dt}}, nil, nil)
+	require.Error(t, err)
+}
+
+func TestJSONOutputs(t *testing.T) {
+	dt := []byte(`{
+		"variable": {
+			"VERSION": {
+				"default": "1.2.3"
+			}
+		},
+		"output": {
+			"version": {
+				"value": "v${VERSION}"
+			}
+		}
+	}`)
+	_, pm, err := ParseFiles([]File{{Name: "docker-bake.json", Data: dt}}, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, pm.AllOutputs, 1)
+	require.JSONEq(t, `"v1.2.3"`, string(pm.AllOutputs[0].Value))
+}
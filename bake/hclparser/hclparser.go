@@ -2,6 +2,7 @@ package hclparser
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"hash/fnv"
 	"math"
@@ -55,9 +56,24 @@ type functionDef struct {
 	Result   *hcl.Attribute `json:"result,omitempty" hcl:"result"`
 }
 
+type outputDef struct {
+	Name        string         `json:"-" hcl:"name,label"`
+	Value       *hcl.Attribute `json:"value,omitempty" hcl:"value"`
+	Type        hcl.Expression `json:"type,omitempty" hcl:"type,optional"`
+	Description string         `json:"description,omitempty" hcl:"description,optional"`
+}
+
+// importDef is resolved before parsing, when the imported files are loaded.
+type importDef struct {
+	Name   string   `json:"-" hcl:"name,label"`
+	Remain hcl.Body `json:"-" hcl:",remain"`
+}
+
 type inputs struct {
 	Variables []*variable    `hcl:"variable,block"`
 	Functions []*functionDef `hcl:"function,block"`
+	Outputs   []*outputDef   `hcl:"output,block"`
+	Imports   []*importDef   `hcl:"import,block"`
 
 	Remain hcl.Body `json:"-" hcl:",remain"`
 }
@@ -95,6 +111,29 @@ type WithBlockSource interface {
 	SetBlockSource(block *hcl.Block)
 }
 
+// WithValidations is implemented by blocks supporting validation blocks. The
+// validations are evaluated by the caller once the block is fully resolved.
+type WithValidations interface {
+	AddValidations(validations []*Validation)
+}
+
+// Validation is a validation block declared in a block implementing
+// WithValidations.
+type Validation struct {
+	Condition    hcl.Expression
+	ErrorMessage hcl.Expression
+
+	ectx *hcl.EvalContext
+}
+
+// Validate evaluates the condition of the validation block with self set to
+// the resolved value of the block.
+func (v *Validation) Validate(self cty.Value) hcl.Diagnostics {
+	ectx := v.ectx.NewChild()
+	ectx.Variables = map[string]cty.Value{"self": self}
+	return checkValidation(v.Condition, v.ErrorMessage, ectx)
+}
+
 // errUndefined is returned when a variable or function is not defined.
 type errUndefined struct{}
 
@@ -414,6 +453,15 @@ func (p *parser) resolveBlock(block *hcl.Block, target *hcl.BodySchema) (err err
 	if !ok {
 		return nil
 	}
+	_, withValidations := reflect.New(t).Interface().(WithValidations)
+	blockBody := block.Body
+	if withValidations {
+		// validation blocks are not part of the decoded value
+		blockBody = FilterExcludeBody(block.Body, &hcl.BodySchema{
+			Blocks: []hcl.BlockHeaderSchema{{Type: "validation"}},
+		})
+	}
+
 	var outputs []reflect.Value
 	var ectxs []*hcl.EvalContext
 	if prev, ok := p.blockValues[block]; ok {
@@ -499,7 +547,7 @@ func (p *parser) resolveBlock(block *hcl.Block, target *hcl.BodySchema) (err err
 		// create a filtered body that contains only the target properties
 		body := func() hcl.Body {
 			if target != nil {
-				return FilterIncludeBody(block.Body, target)
+				return FilterIncludeBody(blockBody, target)
 			}
 
 			filter := &hcl.BodySchema{}
@@ -507,7 +555,7 @@ func (p *parser) resolveBlock(block *hcl.Block, target *hcl.BodySchema) (err err
 				filter.Attributes = append(filter.Attributes, hcl.AttributeSchema{Name: k})
 				filter.Blocks = append(filter.Blocks, hcl.BlockHeaderSchema{Type: k})
 			}
-			return FilterExcludeBody(block.Body, filter)
+			return FilterExcludeBody(blockBody, filter)
 		}
 
 		// load dependencies from all targeted properties
@@ -535,6 +583,17 @@ func (p *parser) resolveBlock(block *hcl.Block, target *hcl.BodySchema) (err err
 			return diag
 		}
 
+		if withValidations && target == nil {
+			if _, ok := p.doneB[key(block, ectx)]["validation"]; !ok {
+				validations, diag := p.resolveValidations(block, ectx)
+				if diag.HasErrors() {
+					return diag
+				}
+				output.Interface().(WithValidations).AddValidations(validations)
+				p.doneB[key(block, ectx)]["validation"] = struct{}{}
+			}
+		}
+
 		// mark all targeted properties as done
 		for _, a := range content.Attributes {
 			p.doneB[key(block, ectx)][a.Name] = struct{}{}
@@ -632,57 +691,88 @@ func (p *parser) validateVariables(vars map[string]*variable, ectx *hcl.EvalCont
 	var diags hcl.Diagnostics
 	for _, v := range vars {
 		for _, rule := range v.Validations {
-			resultVal, condDiags := rule.Condition.Value(ectx)
-			if condDiags.HasErrors() {
-				diags = append(diags, condDiags...)
-				continue
-			}
-
-			if resultVal.IsNull() {
-				diags = append(diags, &hcl.Diagnostic{
-					Severity:   hcl.DiagError,
-					Summary:    "Invalid condition result",
-					Detail:     "Condition expression must return either true or false, not null.",
-					Subject:    rule.Condition.Range().Ptr(),
-					Expression: rule.Condition,
-				})
-				continue
-			}
+			diags = append(diags, checkValidation(rule.Condition, rule.ErrorMessage, ectx)...)
+		}
+	}
 
-			var err error
-			resultVal, err = convert.Convert(resultVal, cty.Bool)
-			if err != nil {
-				diags = append(diags, &hcl.Diagnostic{
-					Severity:   hcl.DiagError,
-					Summary:    "Invalid condition result",
-					Detail:     fmt.Sprintf("Invalid condition result value: %s", err),
-					Subject:    rule.Condition.Range().Ptr(),
-					Expression: rule.Condition,
-				})
-				continue
-			}
+	return diags
+}
 
-			if !resultVal.True() {
-				message, msgDiags := rule.ErrorMessage.Value(ectx)
-				if msgDiags.HasErrors() {
-					diags = append(diags, msgDiags...)
-					continue
-				}
-				errorMessage := "This check failed, but has an invalid error message."
-				if !message.IsNull() {
-					errorMessage = message.AsString()
-				}
-				diags = append(diags, &hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  "Validation failed",
-					Detail:   errorMessage,
-					Subject:  rule.Condition.Range().Ptr(),
-				})
+// resolveValidations decodes the validation blocks of a block and loads the
+// variables and functions they reference. The self variable is resolved when
+// the validation is evaluated.
+func (p *parser) resolveValidations(block *hcl.Block, ectx *hcl.EvalContext) ([]*Validation, hcl.Diagnostics) {
+	content, _, diags := block.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "validation"}},
+	})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	var validations []*Validation
+	for _, b := range content.Blocks {
+		var rule variableValidation
+		if diags := gohcl.DecodeBody(b.Body, nil, &rule); diags.HasErrors() {
+			return nil, diags
+		}
+		for _, expr := range []hcl.Expression{rule.Condition, rule.ErrorMessage} {
+			if diags := p.loadDeps(ectx, expr, map[string]struct{}{"self": {}}, true); diags.HasErrors() {
+				return nil, diags
 			}
 		}
+		validations = append(validations, &Validation{
+			Condition:    rule.Condition,
+			ErrorMessage: rule.ErrorMessage,
+			ectx:         ectx,
+		})
 	}
+	return validations, nil
+}
 
-	return diags
+func checkValidation(condition, errorMessage hcl.Expression, ectx *hcl.EvalContext) hcl.Diagnostics {
+	resultVal, condDiags := condition.Value(ectx)
+	if condDiags.HasErrors() {
+		return condDiags
+	}
+
+	if resultVal.IsNull() {
+		return hcl.Diagnostics{&hcl.Diagnostic{
+			Severity:   hcl.DiagError,
+			Summary:    "Invalid condition result",
+			Detail:     "Condition expression must return either true or false, not null.",
+			Subject:    condition.Range().Ptr(),
+			Expression: condition,
+		}}
+	}
+
+	var err error
+	resultVal, err = convert.Convert(resultVal, cty.Bool)
+	if err != nil {
+		return hcl.Diagnostics{&hcl.Diagnostic{
+			Severity:   hcl.DiagError,
+			Summary:    "Invalid condition result",
+			Detail:     fmt.Sprintf("Invalid condition result value: %s", err),
+			Subject:    condition.Range().Ptr(),
+			Expression: condition,
+		}}
+	}
+
+	if resultVal.True() {
+		return nil
+	}
+	message, msgDiags := errorMessage.Value(ectx)
+	if msgDiags.HasErrors() {
+		return msgDiags
+	}
+	detail := "This check failed, but has an invalid error message."
+	if !message.IsNull() {
+		detail = message.AsString()
+	}
+	return hcl.Diagnostics{&hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Validation failed",
+		Detail:   detail,
+		Subject:  condition.Range().Ptr(),
+	}}
 }
 
 type Variable struct {
@@ -692,9 +782,19 @@ type Variable struct {
 	Value       *string `json:"value,omitempty"`
 }
 
+// Output is the evaluated value of an output block. Value is the JSON
+// encoding of the value.
+type Output struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Type        string          `json:"type,omitempty"`
+	Value       json.RawMessage `json:"value"`
+}
+
 type ParseMeta struct {
 	Renamed      map[string]map[string][]string
 	AllVariables []*Variable
+	AllOutputs   []*Output
 }
 
 func Parse(b hcl.Body, opt Opt, val any) (*ParseMeta, hcl.Diagnostics) {
@@ -996,12 +1096,66 @@ func Parse(b hcl.Body, opt Opt, val any) (*ParseMeta, hcl.Diagnostics) {
 		}
 	}
 
+	outputs := make([]*Output, 0, len(defs.Outputs))
+	seen := map[string]int{}
+	for _, o := range defs.Outputs {
+		out, diags := p.resolveOutput(o)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		// later definitions override earlier ones, like for variables
+		if i, ok := seen[o.Name]; ok {
+			outputs[i] = out
+			continue
+		}
+		seen[o.Name] = len(outputs)
+		outputs = append(outputs, out)
+	}
+
 	return &ParseMeta{
 		Renamed:      renamed,
 		AllVariables: vars,
+		AllOutputs:   outputs,
 	}, nil
 }
 
+// resolveOutput evaluates the value of an output block once all variables,
+// functions and blocks have been resolved.
+func (p *parser) resolveOutput(o *outputDef) (*Output, hcl.Diagnostics) {
+	typ, diags := typeConstraint(o.Type)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	if diags := p.loadDeps(p.ectx, o.Value.Expr, nil, false); diags.HasErrors() {
+		return nil, diags
+	}
+	v, diags := o.Value.Expr.Value(p.ectx)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	r := o.Value.Range
+	v, err := convert.Convert(v, typ)
+	if err != nil {
+		return nil, wrapErrorDiagnostic("Invalid output value", errors.Wrapf(err, "invalid type %s for output %s", typ.FriendlyName(), o.Name), &r, &r)
+	}
+	if !v.IsWhollyKnown() {
+		return nil, wrapErrorDiagnostic("Invalid output value", errors.Errorf("value of output %s is not known", o.Name), &r, &r)
+	}
+	dt, err := ctyjson.Marshal(v, v.Type())
+	if err != nil {
+		return nil, wrapErrorDiagnostic("Invalid output value", err, &r, &r)
+	}
+	out := &Output{
+		Name:        o.Name,
+		Description: o.Description,
+		Value:       dt,
+	}
+	if !typ.Equals(cty.DynamicPseudoType) || hcl.ExprAsKeyword(o.Type) == "any" {
+		out.Type = typ.FriendlyNameForConstraint()
+	}
+	return out, nil
+}
+
 // typeConstraint wraps typeexpr.TypeConstraint to differentiate between errors in the
 // specification and errors due to being cty.NullVal (not provided).
 func typeConstraint(expr hcl.Expression) (cty.Type, hcl.Diagnostics) {
@@ -0,0 +1,634 @@
+package bake
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/images"
+	"github.com/distribution/reference"
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/buildx/util/progress"
+	"github.com/docker/go-units"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/moby/buildkit/frontend/dockerui"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// ociImportPrefix is the prefix of import sources fetched from an OCI
+	// artifact.
+	ociImportPrefix = "oci://"
+	// importSeparator separates the name of an import from the names it
+	// declares.
+	importSeparator = "_"
+)
+
+// defaultImportFilenames are the files loaded from an import source if the
+// import block doesn't set any.
+var defaultImportFilenames = []string{
+	"docker-bake.hcl",
+	"docker-bake.override.hcl",
+}
+
+// Import is an import block loading bake files from another source.
+type Import struct {
+	Name   string
+	Source string
+	Files  []string
+
+	// base is the directory local sources are relative to. It is empty if
+	// the file declaring the import is not local.
+	base    string
+	hasBase bool
+}
+
+type ImportOpt struct {
+	// Nodes returns the builder nodes used to fetch git sources.
+	Nodes func(context.Context) ([]builder.Node, error)
+	// Registry returns the resolver used to fetch OCI sources.
+	Registry func() (*imagetools.Resolver, error)
+	// Lock pins the remote sources. Imports are loaded at their pinned commit
+	// or digest, and the pins of the imports read are recorded in it. If
+	// nil, imports are always fetched from their source.
+	Lock *Lock
+	// Update resolves the remote sources again, ignoring the imports pinned
+	// in Lock.
+	Update bool
+	// Locked requires the remote sources to be pinned in Lock.
+	Locked   bool
+	Progress progress.Writer
+}
+
+// ReadImports fetches the files of the import blocks declared in files and
+// returns them appended to files. The targets, groups, variables, functions
+// and outputs declared by imported files are prefixed with the name of the
+// import.
+func ReadImports(ctx context.Context, files []File, opt ImportOpt) ([]File, error) {
+	imports, err := parseImports(files)
+	if err != nil {
+		return nil, err
+	}
+	if len(imports) == 0 {
+		if opt.Lock != nil && !opt.Locked {
+			opt.Lock.Imports = nil
+		}
+		return files, nil
+	}
+
+	locked := make(map[string]*LockedImport, len(imports))
+	for _, imp := range imports {
+		var pinned *LockedImport
+		if opt.Lock != nil && !opt.Update {
+			if li, ok := opt.Lock.Imports[imp.Name]; ok && li.Source == imp.Source {
+				pinned = li
+			}
+		}
+		if opt.Locked && pinned == nil && isRemoteImport(imp) {
+			return nil, errors.Errorf("import %s is not pinned in %s", imp.Name, LockFilename)
+		}
+		ifiles, li, err := readImport(ctx, imp, pinned, opt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read import %s", imp.Name)
+		}
+		if nested, err := parseImports(ifiles); err != nil {
+			return nil, err
+		} else if len(nested) > 0 {
+			return nil, errors.Errorf("import %s: nested imports are not supported", imp.Name)
+		}
+		for _, f := range ifiles {
+			f.namespace = imp.Name
+			files = append(files, f)
+		}
+		if li != nil {
+			locked[imp.Name] = li
+		}
+	}
+
+	if opt.Lock != nil && !opt.Locked {
+		opt.Lock.Imports = locked
+	}
+	return files, nil
+}
+
+// parseImports returns the import blocks declared in the HCL files.
+func parseImports(files []File) ([]*Import, error) {
+	var imports []*Import
+	seen := map[string]*Import{}
+	for _, f := range files {
+		hf, isHCL, err := ParseHCLFile(f.Data, f.Name)
+		if !isHCL || err != nil {
+			// not a bake HCL file, errors are reported when parsing
+			continue
+		}
+		content, _, _ := hf.Body.PartialContent(&hcl.BodySchema{
+			Blocks: []hcl.BlockHeaderSchema{{Type: "import", LabelNames: []string{"name"}}},
+		})
+		for _, block := range content.Blocks {
+			imp, err := decodeImport(block)
+			if err != nil {
+				return nil, err
+			}
+			imp.base, imp.hasBase = localFileDir(f.Name)
+			if prev, ok := seen[imp.Name]; ok {
+				if prev.Source != imp.Source || !slices.Equal(prev.Files, imp.Files) {
+					return nil, errors.Errorf("import %s is declared more than once with different sources", imp.Name)
+				}
+				continue
+			}
+			seen[imp.Name] = imp
+			imports = append(imports, imp)
+		}
+	}
+	return imports, nil
+}
+
+func decodeImport(block *hcl.Block) (*Import, error) {
+	imp := &Import{Name: block.Labels[0]}
+	if err := validateTargetName(imp.Name); err != nil {
+		return nil, errors.Wrapf(err, "invalid import name %s", imp.Name)
+	}
+	attrs, diags := block.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	for name, attr := range attrs {
+		// imports are fetched before variables and functions are evaluated
+		v, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, errors.Errorf("import %s: %s must be a literal value", imp.Name, name)
+		}
+		switch name {
+		case "source":
+			if v.IsNull() || !v.Type().Equals(cty.String) {
+				return nil, errors.Errorf("import %s: source must be a string", imp.Name)
+			}
+			imp.Source = v.AsString()
+		case "files":
+			if v.IsNull() || !(v.Type().IsTupleType() || v.Type().IsListType()) {
+				return nil, errors.Errorf("import %s: files must be a list of strings", imp.Name)
+			}
+			for _, f := range v.AsValueSlice() {
+				if f.IsNull() || !f.Type().Equals(cty.String) {
+					return nil, errors.Errorf("import %s: files must be a list of strings", imp.Name)
+				}
+				imp.Files = append(imp.Files, f.AsString())
+			}
+		default:
+			return nil, errors.Errorf("import %s: unsupported attribute %s", imp.Name, name)
+		}
+	}
+	if imp.Source == "" {
+		return nil, errors.Errorf("import %s: source is required", imp.Name)
+	}
+	return imp, nil
+}
+
+func readImport(ctx context.Context, imp *Import, pinned *LockedImport, opt ImportOpt) ([]File, *LockedImport, error) {
+	if ref, ok := strings.CutPrefix(imp.Source, ociImportPrefix); ok {
+		return readOCIImport(ctx, imp, ref, pinned, opt)
+	}
+	keepGitDir := false
+	if _, ok, _ := dockerui.DetectGitContext(imp.Source, &keepGitDir); ok {
+		return readGitImport(ctx, imp, pinned, opt)
+	}
+	if strings.Contains(imp.Source, "://") {
+		return nil, nil, errors.Errorf("unsupported import source %s", imp.Source)
+	}
+	files, err := readLocalImport(imp, opt)
+	return files, nil, err
+}
+
+// isRemoteImport returns true if the import source is pinned in the lock
+// file.
+func isRemoteImport(imp *Import) bool {
+	if strings.HasPrefix(imp.Source, ociImportPrefix) {
+		return true
+	}
+	keepGitDir := false
+	_, ok, _ := dockerui.DetectGitContext(imp.Source, &keepGitDir)
+	return ok
+}
+
+func readLocalImport(imp *Import, opt ImportOpt) (files []File, err error) {
+	if !imp.hasBase {
+		return nil, errors.Errorf("local import source %s is not supported in remote bake definitions", imp.Source)
+	}
+	p := imp.Source
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(imp.base, filepath.FromSlash(p))
+	}
+	err = wrapImport(imp, opt.Progress, func() error {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		var names []string
+		isDefault := false
+		switch {
+		case !fi.IsDir():
+			if len(imp.Files) > 0 {
+				return errors.Errorf("files can only be set for directory sources")
+			}
+			names = []string{p}
+		case len(imp.Files) > 0:
+			for _, f := range imp.Files {
+				names = append(names, filepath.Join(p, filepath.FromSlash(f)))
+			}
+		default:
+			isDefault = true
+			for _, f := range defaultImportFilenames {
+				names = append(names, filepath.Join(p, f))
+			}
+		}
+		for _, name := range names {
+			dt, err := os.ReadFile(name)
+			if err != nil {
+				if isDefault && errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return err
+			}
+			files = append(files, File{Name: name, Data: dt})
+		}
+		if len(files) == 0 {
+			return errors.Errorf("no bake files found in %s", imp.Source)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func readGitImport(ctx context.Context, imp *Import, pinned *LockedImport, opt ImportOpt) ([]File, *LockedImport, error) {
+	if opt.Nodes == nil {
+		return nil, nil, errors.New("git imports require a builder")
+	}
+	nodes, err := opt.Nodes(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	url := imp.Source
+	if pinned != nil && pinned.Commit != "" {
+		url = pinGitURL(url, pinned.Commit)
+	}
+	files, inp, err := readRemoteFiles(ctx, nodes, url, imp.Files, opt.Progress, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if inp == nil {
+		return nil, nil, errors.New("no builder node available to fetch git import")
+	}
+	if len(imp.Files) == 0 {
+		files = slices.DeleteFunc(files, func(f File) bool {
+			return !slices.Contains(defaultImportFilenames, f.Name)
+		})
+	}
+	if len(files) == 0 {
+		return nil, nil, errors.Errorf("no bake files found in %s", imp.Source)
+	}
+	for i := range files {
+		files[i].Name = strings.TrimSuffix(imp.Source, "/") + "/" + files[i].Name
+	}
+	return files, &LockedImport{Source: imp.Source, Commit: inp.Commit}, nil
+}
+
+func wrapImport(imp *Import, pw progress.Writer, fn func() error) error {
+	if pw == nil {
+		return fn()
+	}
+	return progress.Wrap("[internal] load import "+imp.Name, pw.Write, func(progress.SubLogger) error {
+		return fn()
+	})
+}
+
+// pinGitURL replaces the ref of a git url with the commit, keeping the
+// subdirectory if any.
+func pinGitURL(url, commit string) string {
+	base, fragment, _ := strings.Cut(url, "#")
+	_, subdir, ok := strings.Cut(fragment, ":")
+	if ok && subdir != "" {
+		return base + "#" + commit + ":" + subdir
+	}
+	return base + "#" + commit
+}
+
+func readOCIImport(ctx context.Context, imp *Import, ref string, pinned *LockedImport, opt ImportOpt) (files []File, li *LockedImport, err error) {
+	if opt.Registry == nil {
+		return nil, nil, errors.New("OCI imports require a registry resolver")
+	}
+	r, err := opt.Registry()
+	if err != nil {
+		return nil, nil, err
+	}
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid import source %s", imp.Source)
+	}
+	named = reference.TagNameOnly(named)
+	if pinned != nil && pinned.Digest != "" {
+		dgst, err := digest.Parse(pinned.Digest)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid digest %s in lock file", pinned.Digest)
+		}
+		named, err = reference.WithDigest(reference.TrimNamed(named), dgst)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	err = wrapImport(imp, opt.Progress, func() error {
+		dt, desc, err := r.Get(ctx, named.String())
+		if err != nil {
+			return err
+		}
+		switch desc.MediaType {
+		case ocispecs.MediaTypeImageManifest, images.MediaTypeDockerSchema2Manifest:
+		default:
+			return errors.Errorf("unsupported media type %s for import %s, expected an image manifest", desc.MediaType, imp.Source)
+		}
+		var mfst ocispecs.Manifest
+		if err := json.Unmarshal(dt, &mfst); err != nil {
+			return err
+		}
+		loc, err := imagetools.ParseLocation(named.String())
+		if err != nil {
+			return err
+		}
+		found := map[string]struct{}{}
+		for _, layer := range mfst.Layers {
+			name := layer.Annotations[ocispecs.AnnotationTitle]
+			if name == "" {
+				continue
+			}
+			if len(imp.Files) > 0 {
+				if !slices.Contains(imp.Files, name) {
+					continue
+				}
+			} else if path.Ext(name) != ".hcl" {
+				continue
+			}
+			if layer.Size > maxBakeDefinitionSize {
+				return errors.Errorf("file %s of import %s bigger than maximum allowed size (%s)", name, imp.Name, units.HumanSize(maxBakeDefinitionSize))
+			}
+			dt, err := r.GetDescriptor(ctx, loc, layer)
+			if err != nil {
+				return err
+			}
+			found[name] = struct{}{}
+			files = append(files, File{Name: ociImportPrefix + reference.FamiliarString(named) + "/" + name, Data: dt})
+		}
+		for _, name := range imp.Files {
+			if _, ok := found[name]; !ok {
+				return errors.Errorf("file %s not found in %s", name, imp.Source)
+			}
+		}
+		if len(files) == 0 {
+			return errors.Errorf("no bake files found in %s", imp.Source)
+		}
+		li = &LockedImport{Source: imp.Source, Digest: desc.Digest.String()}
+		return nil
+	})
+	return files, li, err
+}
+
+// namespaceHCLFile prefixes the names declared in an imported file with the
+// name of the import, and rewrites the references to them within the file.
+// References from targets and groups to other targets in inherits,
+// depends_on, contexts and targets are only rewritten if they are string
+// literals.
+func namespaceHCLFile(f *hcl.File, ns string) error {
+	body, ok := f.Body.(*hclsyntax.Body)
+	if !ok {
+		return errors.Errorf("imported file %s must use the HCL native syntax", f.Body.MissingItemRange().Filename)
+	}
+	prefix := func(name string) string {
+		return ns + importSeparator + name
+	}
+
+	w := &namespaceWalker{
+		prefix: prefix,
+		vars:   map[string]struct{}{},
+		funcs:  map[string]struct{}{},
+		blocks: map[string]map[string]struct{}{
+			"target": {},
+			"group":  {},
+		},
+		locals: map[string]int{},
+	}
+	for name := range body.Attributes {
+		w.vars[name] = struct{}{}
+	}
+	for _, b := range body.Blocks {
+		if len(b.Labels) != 1 {
+			continue
+		}
+		switch b.Type {
+		case "variable":
+			w.vars[b.Labels[0]] = struct{}{}
+		case "function":
+			w.funcs[b.Labels[0]] = struct{}{}
+		case "target", "group":
+			w.blocks[b.Type][b.Labels[0]] = struct{}{}
+		}
+	}
+	isTargetOrGroup := func(name string) bool {
+		_, isTarget := w.blocks["target"][name]
+		_, isGroup := w.blocks["group"][name]
+		return isTarget || isGroup
+	}
+
+	attrs := make(hclsyntax.Attributes, len(body.Attributes))
+	for name, attr := range body.Attributes {
+		if diags := hclsyntax.Walk(attr.Expr, w); diags.HasErrors() {
+			return diags
+		}
+		attr.Name = prefix(name)
+		attrs[attr.Name] = attr
+	}
+	body.Attributes = attrs
+
+	for _, b := range body.Blocks {
+		if len(b.Labels) != 1 {
+			continue
+		}
+		locals := blockLocals(b)
+		for _, l := range locals {
+			w.locals[l]++
+		}
+		diags := hclsyntax.Walk(b.Body, w)
+		for _, l := range locals {
+			w.locals[l]--
+		}
+		if diags.HasErrors() {
+			return diags
+		}
+
+		switch b.Type {
+		case "target", "group":
+			for name, attr := range b.Body.Attributes {
+				switch name {
+				case "inherits", "depends_on", "targets":
+					rewriteStringLiterals(attr.Expr, func(s string) string {
+						if isTargetOrGroup(s) {
+							return prefix(s)
+						}
+						return s
+					})
+				case "contexts":
+					rewriteStringLiterals(attr.Expr, func(s string) string {
+						if t, ok := strings.CutPrefix(s, "target:"); ok && isTargetOrGroup(t) {
+							return "target:" + prefix(t)
+						}
+						return s
+					})
+				case "name":
+					attr.Expr = &hclsyntax.TemplateExpr{
+						Parts: []hclsyntax.Expression{
+							&hclsyntax.LiteralValueExpr{Val: cty.StringVal(prefix("")), SrcRange: attr.Expr.Range()},
+							attr.Expr,
+						},
+						SrcRange: attr.Expr.Range(),
+					}
+				}
+			}
+		case "variable", "function", "output":
+		default:
+			continue
+		}
+		b.Labels[0] = prefix(b.Labels[0])
+	}
+	return nil
+}
+
+// blockLocals returns the names local to a block that shadow the ones
+// declared at the top level: function parameters and matrix keys.
+func blockLocals(b *hclsyntax.Block) []string {
+	var locals []string
+	switch b.Type {
+	case "function":
+		for _, name := range []string{"params", "variadic_params"} {
+			attr, ok := b.Body.Attributes[name]
+			if !ok {
+				continue
+			}
+			if tuple, ok := attr.Expr.(*hclsyntax.TupleConsExpr); ok {
+				for _, e := range tuple.Exprs {
+					if kw := hcl.ExprAsKeyword(e); kw != "" {
+						locals = append(locals, kw)
+					}
+				}
+			} else if kw := hcl.ExprAsKeyword(attr.Expr); kw != "" {
+				locals = append(locals, kw)
+			}
+		}
+	case "target", "group":
+		if attr, ok := b.Body.Attributes["matrix"]; ok {
+			if obj, ok := attr.Expr.(*hclsyntax.ObjectConsExpr); ok {
+				for _, item := range obj.Items {
+					if kw := hcl.ExprAsKeyword(item.KeyExpr); kw != "" {
+						locals = append(locals, kw)
+					}
+				}
+			}
+		}
+	}
+	return locals
+}
+
+// rewriteStringLiterals calls fn for the string literals of a string, a list
+// of strings or the values of a map of strings.
+func rewriteStringLiterals(expr hclsyntax.Expression, fn func(string) string) {
+	switch e := expr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		if !e.Val.IsNull() && e.Val.IsKnown() && e.Val.Type().Equals(cty.String) {
+			e.Val = cty.StringVal(fn(e.Val.AsString()))
+		}
+	case *hclsyntax.TemplateExpr:
+		if len(e.Parts) == 1 {
+			rewriteStringLiterals(e.Parts[0], fn)
+		}
+	case *hclsyntax.TupleConsExpr:
+		for _, ex := range e.Exprs {
+			rewriteStringLiterals(ex, fn)
+		}
+	case *hclsyntax.ObjectConsExpr:
+		for _, item := range e.Items {
+			rewriteStringLiterals(item.ValueExpr, fn)
+		}
+	}
+}
+
+// namespaceWalker prefixes the references to top-level names declared in an
+// imported file.
+type namespaceWalker struct {
+	prefix func(string) string
+	vars   map[string]struct{}
+	funcs  map[string]struct{}
+	blocks map[string]map[string]struct{}
+	// locals counts the names shadowing top-level ones in the current scope
+	locals map[string]int
+	// keys counts the object keys being walked, which are not references
+	keys int
+}
+
+func (w *namespaceWalker) Enter(node hclsyntax.Node) hcl.Diagnostics {
+	switch e := node.(type) {
+	case *hclsyntax.ObjectConsKeyExpr:
+		if !e.ForceNonLiteral && hcl.ExprAsKeyword(e.Wrapped) != "" {
+			w.keys++
+		}
+	case *hclsyntax.ForExpr:
+		w.locals[e.KeyVar]++
+		w.locals[e.ValVar]++
+	case *hclsyntax.FunctionCallExpr:
+		if _, ok := w.funcs[e.Name]; ok && w.locals[e.Name] == 0 {
+			e.Name = w.prefix(e.Name)
+		}
+	case *hclsyntax.ScopeTraversalExpr:
+		if w.keys > 0 {
+			break
+		}
+		root := e.Traversal.RootName()
+		if w.locals[root] > 0 {
+			break
+		}
+		if names, ok := w.blocks[root]; ok {
+			if len(e.Traversal) > 1 {
+				if attr, ok := e.Traversal[1].(hcl.TraverseAttr); ok {
+					if _, ok := names[attr.Name]; ok {
+						attr.Name = w.prefix(attr.Name)
+						e.Traversal[1] = attr
+					}
+				}
+			}
+			break
+		}
+		if _, ok := w.vars[root]; ok {
+			r := e.Traversal[0].(hcl.TraverseRoot)
+			r.Name = w.prefix(r.Name)
+			e.Traversal[0] = r
+		}
+	}
+	return nil
+}
+
+func (w *namespaceWalker) Exit(node hclsyntax.Node) hcl.Diagnostics {
+	switch e := node.(type) {
+	case *hclsyntax.ObjectConsKeyExpr:
+		if !e.ForceNonLiteral && hcl.ExprAsKeyword(e.Wrapped) != "" {
+			w.keys--
+		}
+	case *hclsyntax.ForExpr:
+		w.locals[e.KeyVar]--
+		w.locals[e.ValVar]--
+	}
+	return nil
+}
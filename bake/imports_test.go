@@ -0,0 +1,312 @@
+package bake
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/moby/buildkit/session/auth/authprovider"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadImportsLocal(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "common"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "common", "docker-bake.hcl"), []byte(`
+variable "GO_VERSION" {
+  default = "1.26"
+}
+REPO = "docker/buildx"
+function "tag" {
+  params = [GO_VERSION]
+  result = "${REPO}:go${GO_VERSION}"
+}
+target "base" {
+  args = {
+    GO_VERSION = GO_VERSION
+  }
+}
+target "lint" {
+  inherits = ["base"]
+  name = "lint-${linter}"
+  matrix = {
+    linter = ["golangci", "shfmt"]
+  }
+  tags = [tag(GO_VERSION)]
+}
+target "app" {
+  inherits = ["base"]
+  contexts = {
+    base = "target:base"
+  }
+  labels = {
+    version = target.base.args.GO_VERSION
+  }
+}
+group "validate" {
+  targets = ["lint"]
+}
+output "version" {
+  value = GO_VERSION
+}
+`), 0644))
+
+	fp := File{
+		Name: filepath.Join(dir, "docker-bake.hcl"),
+		Data: []byte(`
+import "common" {
+  source = "./common"
+}
+target "default" {
+  inherits = ["common_base"]
+  tags = [common_tag("1.27")]
+}
+group "all" {
+  targets = ["default", "common_validate"]
+}
+`),
+	}
+
+	ctx := context.TODO()
+	files, err := ReadImports(ctx, []File{fp}, ImportOpt{})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	m, g, err := ReadTargets(ctx, files, []string{"all", "common_app"}, nil, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Contains(t, g, "common_validate")
+	require.Contains(t, g, "common_lint")
+	require.ElementsMatch(t, []string{"common_lint-golangci", "common_lint-shfmt"}, g["common_lint"].Targets)
+
+	require.Equal(t, ptrstr("1.26"), m["default"].Args["GO_VERSION"])
+	require.Equal(t, []string{"docker/buildx:go1.27"}, m["default"].Tags)
+	require.Equal(t, []string{"docker/buildx:go1.26"}, m["common_lint-golangci"].Tags)
+	require.Equal(t, ptrstr("1.26"), m["common_lint-shfmt"].Args["GO_VERSION"])
+	require.Equal(t, "target:common_base", m["common_app"].Contexts["base"])
+	require.Equal(t, ptrstr("1.26"), m["common_app"].Labels["version"])
+
+	// namespaced variables can be overridden
+	t.Setenv("common_GO_VERSION", "1.25")
+	m, _, err = ReadTargets(ctx, files, []string{"default"}, nil, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Equal(t, ptrstr("1.25"), m["default"].Args["GO_VERSION"])
+
+	_, pm, err := ParseFiles(files, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, pm.AllOutputs, 1)
+	require.Equal(t, "common_version", pm.AllOutputs[0].Name)
+}
+
+func TestReadImportsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.TODO()
+
+	for _, tt := range []struct {
+		name string
+		dt   string
+		err  string
+	}{
+		{
+			name: "missing source",
+			dt:   `import "common" {}`,
+			err:  "import common: source is required",
+		},
+		{
+			name: "variable source",
+			dt:   `import "common" { source = SRC }`,
+			err:  "import common: source must be a literal value",
+		},
+		{
+			name: "unknown attribute",
+			dt:   `import "common" { ` + "\n" + `source = "./common"` + "\n" + `ref = "main"` + "\n" + `}`,
+			err:  "import common: unsupported attribute ref",
+		},
+		{
+			name: "unsupported scheme",
+			dt:   `import "common" { source = "s3://bucket/common" }`,
+			err:  "unsupported import source s3://bucket/common",
+		},
+		{
+			name: "not found",
+			dt:   `import "common" { source = "./notfound" }`,
+			err:  "failed to read import common",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadImports(ctx, []File{{Name: filepath.Join(dir, "docker-bake.hcl"), Data: []byte(tt.dt)}}, ImportOpt{})
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "docker-bake.hcl"), []byte(`
+import "other" {
+  source = "../other"
+}
+`), 0644))
+	_, err := ReadImports(ctx, []File{{Name: filepath.Join(dir, "docker-bake.hcl"), Data: []byte(`import "nested" { source = "./nested" }`)}}, ImportOpt{})
+	require.ErrorContains(t, err, "nested imports are not supported")
+}
+
+func TestReadImportsLock(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "common.hcl"), []byte(`target "base" {}`), 0644))
+
+	// stale entries are removed, local imports are not pinned
+	lock := &Lock{Version: lockVersion, Imports: map[string]*LockedImport{
+		"old": {Source: "https://github.com/docker/buildx.git", Commit: "deadbeef"},
+	}}
+
+	files, err := ReadImports(context.TODO(), []File{{
+		Name: filepath.Join(dir, "docker-bake.hcl"),
+		Data: []byte(`import "common" { source = "./common.hcl" }`),
+	}}, ImportOpt{Lock: lock})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Empty(t, lock.Imports)
+
+	// the lock file is only written by the caller
+	_, err = os.Stat(filepath.Join(dir, LockFilename))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestReadImportsLockUpdate(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.push(t, "bake/common", "v1", `target "base" { args = { VERSION = "1" } }`)
+
+	ctx := context.TODO()
+	fp := File{
+		Name: filepath.Join(t.TempDir(), "docker-bake.hcl"),
+		Data: []byte(`import "common" { source = "oci://` + reg.host + `/bake/common:v1" }`),
+	}
+	lock := &Lock{Version: lockVersion}
+	read := func(update, locked bool) string {
+		files, err := ReadImports(ctx, []File{fp}, ImportOpt{
+			Registry: func() (*imagetools.Resolver, error) {
+				return imagetools.New(imagetools.Opt{
+					Auth: func(context.Context, string, []string, authprovider.ExpireCachedAuthCheck) (types.AuthConfig, error) {
+						return types.AuthConfig{}, nil
+					},
+				}), nil
+			},
+			Lock:   lock,
+			Update: update,
+			Locked: locked,
+		})
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+		return string(files[1].Data)
+	}
+
+	require.Contains(t, read(true, false), `VERSION = "1"`)
+	pinned := *lock.Imports["common"]
+	require.NotEmpty(t, pinned.Digest)
+
+	// the tag is moved to another manifest
+	reg.push(t, "bake/common", "v1", `target "base" { args = { VERSION = "2" } }`)
+
+	require.Contains(t, read(false, false), `VERSION = "1"`)
+	require.Contains(t, read(false, true), `VERSION = "1"`)
+	require.Equal(t, pinned, *lock.Imports["common"])
+
+	require.Contains(t, read(true, false), `VERSION = "2"`)
+	require.NotEqual(t, pinned.Digest, lock.Imports["common"].Digest)
+}
+
+func TestLockPath(t *testing.T) {
+	require.Equal(t, LockFilename, LockPath(nil))
+	require.Equal(t, LockFilename, LockPath([]File{{Name: "-"}}))
+	require.Equal(t, LockFilename, LockPath([]File{{Name: "docker-bake.hcl"}}))
+	require.Equal(t, filepath.Join("build", LockFilename), LockPath([]File{{Name: "-"}, {Name: filepath.Join("build", "docker-bake.hcl")}}))
+	require.Equal(t, LockFilename, LockPath([]File{{Name: "https://github.com/docker/buildx.git/docker-bake.hcl"}}))
+}
+
+func TestPinGitURL(t *testing.T) {
+	const commit = "4c5f6e5e4f0e8b2b7a6f1d2c3b4a5968778695a4"
+	require.Equal(t, "https://github.com/docker/buildx.git#"+commit, pinGitURL("https://github.com/docker/buildx.git", commit))
+	require.Equal(t, "https://github.com/docker/buildx.git#"+commit, pinGitURL("https://github.com/docker/buildx.git#v0.20.0", commit))
+	require.Equal(t, "https://github.com/docker/buildx.git#"+commit+":bake", pinGitURL("https://github.com/docker/buildx.git#main:bake", commit))
+}
+
+// testRegistry is a minimal read-only registry serving the manifests and
+// blobs pushed by the test.
+type testRegistry struct {
+	host  string
+	mu    sync.Mutex
+	tags  map[string]digest.Digest
+	blobs map[digest.Digest][]byte
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	reg := &testRegistry{
+		tags:  map[string]digest.Digest{},
+		blobs: map[digest.Digest][]byte{},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(reg.serveHTTP))
+	t.Cleanup(srv.Close)
+	reg.host = strings.TrimPrefix(srv.URL, "http://")
+	return reg
+}
+
+// push stores an import artifact with a single file and tags it.
+func (reg *testRegistry) push(t *testing.T, repo, tag, data string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	add := func(mediaType string, dt []byte, annotations map[string]string) ocispecs.Descriptor {
+		dgst := digest.FromBytes(dt)
+		reg.blobs[dgst] = dt
+		return ocispecs.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(dt)), Annotations: annotations}
+	}
+	mfst := ocispecs.Manifest{
+		MediaType: ocispecs.MediaTypeImageManifest,
+		Config:    add(ocispecs.MediaTypeEmptyJSON, []byte("{}"), nil),
+		Layers: []ocispecs.Descriptor{
+			add("application/vnd.buildx.bake.hcl", []byte(data), map[string]string{ocispecs.AnnotationTitle: "docker-bake.hcl"}),
+		},
+	}
+	mfst.SchemaVersion = 2
+	dt, err := json.Marshal(mfst)
+	require.NoError(t, err)
+	reg.tags[repo+":"+tag] = add(ocispecs.MediaTypeImageManifest, dt, nil).Digest
+}
+
+func (reg *testRegistry) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v2/" {
+		return
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	p := strings.TrimPrefix(r.URL.Path, "/v2/")
+	mediaType := "application/octet-stream"
+	var dgst digest.Digest
+	if repo, ref, ok := strings.Cut(p, "/manifests/"); ok {
+		mediaType = ocispecs.MediaTypeImageManifest
+		if dgst, ok = reg.tags[repo+":"+ref]; !ok {
+			dgst = digest.Digest(ref)
+		}
+	} else if _, ref, ok := strings.Cut(p, "/blobs/"); ok {
+		dgst = digest.Digest(ref)
+	}
+	dt, ok := reg.blobs[dgst]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.Header().Set("Content-Length", strconv.Itoa(len(dt)))
+	if r.Method != http.MethodHead {
+		w.Write(dt)
+	}
+}
@@ -0,0 +1,501 @@
+package bake
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/docker/buildx/bake/hclparser"
+	"github.com/docker/buildx/bake/hclparser/gohcl"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/tonistiigi/go-csvvalue"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Lint rules reported by Lint.
+const (
+	LintUnusedVariable    = "unused-variable"
+	LintUnusedFunction    = "unused-function"
+	LintUnreachableTarget = "unreachable-target"
+	LintUnknownAttribute  = "unknown-attribute"
+	LintShadowedInherit   = "shadowed-inherit"
+	LintDeprecated        = "deprecated"
+)
+
+// LintIssue is a problem found in a bake definition.
+type LintIssue struct {
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+	Filename string `json:"filename,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+func (i LintIssue) String() string {
+	if i.Filename == "" {
+		return fmt.Sprintf("%s (%s)", i.Message, i.Rule)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", i.Filename, i.Line, i.Column, i.Message, i.Rule)
+}
+
+// lintSchemas are the attributes and nested blocks accepted by each block
+// type of a bake definition.
+var lintSchemas = map[string]struct {
+	attrs  []string
+	blocks []string
+}{
+	"target":     {attrs: append(schemaAttributes(&Target{}), "name", "matrix"), blocks: []string{"validation"}},
+	"group":      {attrs: schemaAttributes(&Group{}), blocks: []string{"validation"}},
+	"variable":   {attrs: []string{"type", "default", "description"}, blocks: []string{"validation"}},
+	"function":   {attrs: []string{"params", "variadic_params", "result"}},
+	"output":     {attrs: []string{"value", "type", "description"}},
+	"import":     {attrs: []string{"source", "files"}},
+	"validation": {attrs: []string{"condition", "error_message"}},
+}
+
+// lintDeprecated lists the deprecated attributes of each block type, and the
+// deprecated keys of the comma-separated values of their attributes as
+// "attribute.key", with the replacement to use.
+var lintDeprecated = map[string]map[string]string{
+	"target": {
+		"output.buildinfo":       `use attest = ["type=provenance"] instead`,
+		"output.buildinfo-attrs": `use attest = ["type=provenance"] instead`,
+	},
+}
+
+func schemaAttributes(val any) []string {
+	schema, _ := gohcl.ImpliedBodySchema(val)
+	attrs := make([]string, 0, len(schema.Attributes))
+	for _, a := range schema.Attributes {
+		attrs = append(attrs, a.Name)
+	}
+	return attrs
+}
+
+var lintDeclSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "target", LabelNames: []string{"name"}},
+		{Type: "group", LabelNames: []string{"name"}},
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "function", LabelNames: []string{"name"}},
+	},
+}
+
+type linter struct {
+	issues []LintIssue
+
+	// declarations of the local files, by name
+	variables map[string]hcl.Range
+	functions map[string]hcl.Range
+	targets   map[string]hcl.Range
+	groups    map[string]struct{}
+	inherits  map[string]hcl.Range
+
+	// partial is set if some expressions could not be analyzed statically so
+	// unused variables and functions can't be detected
+	partial bool
+	used    map[string]struct{}
+	called  map[string]struct{}
+	calls   []*hclsyntax.FunctionCallExpr
+}
+
+// Lint reports the unused variables and functions, the targets unreachable
+// from any group, the unknown attributes, the fields of inherited targets
+// that shadow each other and the deprecated functions and attributes of the
+// HCL files.
+// Imported files are only used to resolve the definition and are not
+// reported.
+func Lint(files []File, defaults, vars map[string]string) ([]LintIssue, error) {
+	l := &linter{
+		variables: map[string]hcl.Range{},
+		functions: map[string]hcl.Range{},
+		targets:   map[string]hcl.Range{},
+		groups:    map[string]struct{}{},
+		inherits:  map[string]hcl.Range{},
+		used:      map[string]struct{}{},
+		called:    map[string]struct{}{},
+	}
+	for _, f := range files {
+		if f.namespace != "" {
+			continue
+		}
+		if isCompose, _ := validateComposeFile(f.Data, f.Name, vars); isCompose {
+			continue
+		}
+		hf, _, err := ParseHCLFile(f.Data, f.Name)
+		if err != nil {
+			return nil, formatHCLError(err, files)
+		}
+		l.addFile(hf)
+	}
+
+	c, pm, err := ParseFiles(files, defaults, vars)
+	if err != nil {
+		// unknown attributes fail the parsing, report them instead
+		if slices.ContainsFunc(l.issues, func(i LintIssue) bool { return i.Rule == LintUnknownAttribute }) {
+			return l.sorted(), nil
+		}
+		return nil, err
+	}
+
+	if !l.partial {
+		for _, v := range pm.AllVariables {
+			if _, ok := l.used[v.Name]; ok {
+				continue
+			}
+			if rng, ok := l.variables[v.Name]; ok {
+				l.report(LintUnusedVariable, &rng, "variable %q is not used", v.Name)
+			}
+		}
+		for name, rng := range l.functions {
+			if _, ok := l.called[name]; !ok {
+				l.report(LintUnusedFunction, &rng, "function %q is not used", name)
+			}
+		}
+	}
+	for _, call := range l.calls {
+		if _, ok := l.functions[call.Name]; ok {
+			continue
+		}
+		if msg, ok := strings.CutPrefix(hclparser.StdlibFuncDescription(call.Name), "Deprecated: "); ok {
+			msg, _, _ = strings.Cut(msg, ". ")
+			l.report(LintDeprecated, &call.NameRange, "function %q is deprecated: %s", call.Name, strings.TrimSuffix(msg, "."))
+		}
+	}
+
+	l.checkReachable(c, pm)
+	l.checkInherits(c, pm)
+	return l.sorted(), nil
+}
+
+func (l *linter) report(rule string, rng *hcl.Range, format string, args ...any) {
+	issue := LintIssue{
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	}
+	if rng != nil {
+		issue.Filename = rng.Filename
+		issue.Line = rng.Start.Line
+		issue.Column = rng.Start.Column
+	}
+	l.issues = append(l.issues, issue)
+}
+
+func (l *linter) sorted() []LintIssue {
+	slices.SortStableFunc(l.issues, func(a, b LintIssue) int {
+		return cmp.Or(
+			cmp.Compare(a.Filename, b.Filename),
+			cmp.Compare(a.Line, b.Line),
+			cmp.Compare(a.Column, b.Column),
+		)
+	})
+	return l.issues
+}
+
+func (l *linter) addFile(f *hcl.File) {
+	content, _, _ := f.Body.PartialContent(lintDeclSchema)
+	for _, b := range content.Blocks {
+		if len(b.Labels) != 1 {
+			continue
+		}
+		name := b.Labels[0]
+		switch b.Type {
+		case "target":
+			l.targets[name] = b.DefRange
+		case "group":
+			l.groups[name] = struct{}{}
+		case "variable":
+			l.variables[name] = b.DefRange
+		case "function":
+			l.functions[name] = b.DefRange
+		}
+	}
+
+	body, ok := f.Body.(*hclsyntax.Body)
+	if !ok {
+		// JSON files are not analyzed
+		l.partial = true
+		return
+	}
+	for _, attr := range body.Attributes {
+		l.addRefs(attr, "")
+	}
+	for _, b := range body.Blocks {
+		l.checkBlock(b, "")
+		if b.Type == "target" && len(b.Labels) == 1 {
+			if attr, ok := b.Body.Attributes["inherits"]; ok {
+				l.inherits[b.Labels[0]] = attr.NameRange
+			}
+		}
+		var self string
+		if (b.Type == "variable" || b.Type == "function") && len(b.Labels) == 1 {
+			// references of a block to itself don't count as usage
+			self = b.Labels[0]
+		}
+		l.addRefs(b, self)
+	}
+}
+
+func (l *linter) checkBlock(b *hclsyntax.Block, parent string) {
+	schema, ok := lintSchemas[b.Type]
+	if !ok || (parent == "" && b.Type == "validation") {
+		if parent == "" {
+			l.report(LintUnknownAttribute, b.TypeRange.Ptr(), "unknown block type %q", b.Type)
+		} else {
+			l.report(LintUnknownAttribute, b.TypeRange.Ptr(), "unknown block type %q in %s", b.Type, parent)
+		}
+		return
+	}
+	name := b.Type
+	if len(b.Labels) > 0 {
+		name = fmt.Sprintf("%s %q", b.Type, b.Labels[0])
+	}
+	for _, attr := range sortedAttributes(b.Body) {
+		if !slices.Contains(schema.attrs, attr.Name) {
+			l.report(LintUnknownAttribute, attr.NameRange.Ptr(), "unknown attribute %q in %s", attr.Name, name)
+			continue
+		}
+		l.checkDeprecated(lintDeprecated[b.Type], name, attr)
+	}
+	for _, nested := range b.Body.Blocks {
+		if !slices.Contains(schema.blocks, nested.Type) {
+			l.report(LintUnknownAttribute, nested.TypeRange.Ptr(), "unknown block type %q in %s", nested.Type, name)
+			continue
+		}
+		l.checkBlock(nested, name)
+	}
+}
+
+// checkDeprecated reports a deprecated attribute, and the deprecated keys of
+// its values. Only the values that are string literals are checked.
+func (l *linter) checkDeprecated(deprecated map[string]string, name string, attr *hclsyntax.Attribute) {
+	if len(deprecated) == 0 {
+		return
+	}
+	if msg, ok := deprecated[attr.Name]; ok {
+		l.report(LintDeprecated, attr.NameRange.Ptr(), "attribute %q in %s is deprecated: %s", attr.Name, name, msg)
+	}
+	tuple, ok := attr.Expr.(*hclsyntax.TupleConsExpr)
+	if !ok {
+		return
+	}
+	for _, expr := range tuple.Exprs {
+		v, diags := expr.Value(nil)
+		if diags.HasErrors() || v.IsNull() || !v.Type().Equals(cty.String) {
+			continue
+		}
+		fields, err := csvvalue.Fields(v.AsString(), nil)
+		if err != nil {
+			continue
+		}
+		for _, field := range fields {
+			key, _, _ := strings.Cut(field, "=")
+			key = strings.TrimSpace(key)
+			if msg, ok := deprecated[attr.Name+"."+key]; ok {
+				l.report(LintDeprecated, expr.Range().Ptr(), "key %q of %s in %s is deprecated: %s", key, attr.Name, name, msg)
+			}
+		}
+	}
+}
+
+func sortedAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	return slices.SortedFunc(maps.Values(body.Attributes), func(a, b *hclsyntax.Attribute) int {
+		return cmp.Compare(a.SrcRange.Start.Byte, b.SrcRange.Start.Byte)
+	})
+}
+
+func (l *linter) addRefs(node hclsyntax.Node, self string) {
+	hclsyntax.VisitAll(node, func(n hclsyntax.Node) hcl.Diagnostics {
+		switch n := n.(type) {
+		case *hclsyntax.ScopeTraversalExpr:
+			if name := n.Traversal.RootName(); name != self {
+				l.used[name] = struct{}{}
+			}
+		case *hclsyntax.FunctionCallExpr:
+			if n.Name != self {
+				l.called[n.Name] = struct{}{}
+			}
+			l.calls = append(l.calls, n)
+		}
+		return nil
+	})
+}
+
+// checkReachable reports the targets that are not part of any group, either
+// directly or through the targets they are used by. Nothing is reported if
+// the definition has no groups since all targets are then entrypoints.
+func (l *linter) checkReachable(c *Config, pm *hclparser.ParseMeta) {
+	if len(l.groups) == 0 {
+		return
+	}
+	targets := make(map[string]*Target, len(c.Targets))
+	for _, t := range c.Targets {
+		targets[t.Name] = t
+	}
+
+	reachable := map[string]struct{}{}
+	var visit func(name string)
+	visit = func(name string) {
+		if _, ok := reachable[name]; ok {
+			return
+		}
+		reachable[name] = struct{}{}
+		t, ok := targets[name]
+		if !ok {
+			return
+		}
+		for _, dep := range t.Inherits {
+			visit(dep)
+		}
+		for _, dep := range t.DependsOn {
+			visit(dep)
+		}
+		for _, v := range t.Contexts {
+			if dep, ok := strings.CutPrefix(v, "target:"); ok {
+				visit(dep)
+			}
+		}
+	}
+	for name := range l.groups {
+		names, _ := c.ResolveGroup(name)
+		for _, n := range names {
+			visit(n)
+		}
+	}
+
+	for name, rng := range l.targets {
+		names := []string{name}
+		if renamed, ok := pm.Renamed["target"][name]; ok {
+			names = renamed
+		}
+		if !slices.ContainsFunc(names, func(n string) bool {
+			_, ok := reachable[n]
+			return ok
+		}) {
+			l.report(LintUnreachableTarget, &rng, "target %q is not reachable from any group", name)
+		}
+	}
+}
+
+// checkInherits reports the fields set by several parents of a target where
+// the value of a parent is silently replaced by the one of a later parent.
+// Fields set by the target itself are not reported.
+func (l *linter) checkInherits(c *Config, pm *hclparser.ParseMeta) {
+	targets := make(map[string]*Target, len(c.Targets))
+	for _, t := range c.Targets {
+		targets[t.Name] = t
+	}
+	blocks := map[string]string{}
+	for name, renamed := range pm.Renamed["target"] {
+		for _, n := range renamed {
+			blocks[n] = name
+		}
+	}
+
+	seen := map[string]struct{}{}
+	for _, t := range c.Targets {
+		if len(t.Inherits) < 2 {
+			continue
+		}
+		block := t.Name
+		if name, ok := blocks[t.Name]; ok {
+			block = name
+		}
+		rng, ok := l.inherits[block]
+		if !ok {
+			continue
+		}
+		parents := make([]*Target, len(t.Inherits))
+		for i, p := range t.Inherits {
+			parents[i] = inheritedTarget(targets, p, map[string]struct{}{})
+		}
+		for i := range parents {
+			for j := i + 1; j < len(parents); j++ {
+				for _, field := range shadowedFields(parents[i], parents[j], t) {
+					msg := fmt.Sprintf("%s of %q is overridden by %q", field, t.Inherits[i], t.Inherits[j])
+					if _, ok := seen[block+msg]; ok {
+						continue
+					}
+					seen[block+msg] = struct{}{}
+					l.report(LintShadowedInherit, &rng, "target %q inherits %s", block, msg)
+				}
+			}
+		}
+	}
+}
+
+// inheritedTarget returns the target with the fields of its parents merged
+// in, without the overrides and defaults applied when resolving targets.
+func inheritedTarget(targets map[string]*Target, name string, visited map[string]struct{}) *Target {
+	t, ok := targets[name]
+	if !ok {
+		return &Target{}
+	}
+	if _, ok := visited[name]; ok {
+		return &Target{}
+	}
+	visited[name] = struct{}{}
+	defer delete(visited, name)
+
+	tt := &Target{}
+	for _, p := range t.Inherits {
+		tt.Merge(inheritedTarget(targets, p, visited))
+	}
+	tt.Merge(t)
+	return tt
+}
+
+// shadowedFields returns the fields, or map keys, set by both a and b whose
+// value from a is lost when b is merged after it and which are not set by
+// child.
+func shadowedFields(a, b, child *Target) []string {
+	merged := &Target{}
+	merged.Merge(a)
+	merged.Merge(b)
+
+	va, vb, vm, vc := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem(), reflect.ValueOf(merged).Elem(), reflect.ValueOf(child).Elem()
+	var fields []string
+	for i := range va.NumField() {
+		name, _, _ := strings.Cut(va.Type().Field(i).Tag.Get("hcl"), ",")
+		if name == "" || name == "name" || name == "inherits" {
+			continue
+		}
+		fa, fb, fc := va.Field(i), vb.Field(i), vc.Field(i)
+		if isEmptyValue(fa) || isEmptyValue(fb) {
+			continue
+		}
+		if fa.Kind() == reflect.Map {
+			for _, k := range fa.MapKeys() {
+				vbk := fb.MapIndex(k)
+				if !vbk.IsValid() || (fc.Kind() == reflect.Map && fc.MapIndex(k).IsValid()) {
+					continue
+				}
+				if !reflect.DeepEqual(fa.MapIndex(k).Interface(), vbk.Interface()) {
+					fields = append(fields, fmt.Sprintf("%s.%v", name, k.Interface()))
+				}
+			}
+			continue
+		}
+		if !isEmptyValue(fc) {
+			continue
+		}
+		if reflect.DeepEqual(vm.Field(i).Interface(), fb.Interface()) && !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
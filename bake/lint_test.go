@@ -0,0 +1,164 @@
+package bake
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+variable "TAG" {
+  default = "latest"
+}
+variable "UNUSED" {
+  default = "foo"
+  validation {
+    condition = UNUSED != ""
+  }
+}
+function "tag" {
+  params = [name]
+  result = "${name}:${TAG}"
+}
+function "unused" {
+  params = []
+  result = "foo"
+}
+group "default" {
+  targets = ["app"]
+}
+target "base" {
+  args = {
+    FOO = "base"
+    BAR = "base"
+  }
+  platforms = ["linux/amd64"]
+}
+target "release" {
+  args = {
+    FOO = "release"
+  }
+  platforms = ["linux/arm64"]
+  labels = {
+    date = formatdate("YYYY", "2025-01-01T00:00:00Z")
+  }
+}
+target "app" {
+  inherits = ["base", "release"]
+  tags = [tag("app")]
+  platforms = ["linux/amd64", "linux/arm64"]
+}
+target "test" {
+  contexts = {
+    app = "target:app"
+  }
+}
+`),
+	}
+	issues, err := Lint([]File{fp}, nil, nil)
+	require.NoError(t, err)
+
+	type issue struct {
+		Rule string
+		Line int
+		Msg  string
+	}
+	var got []issue
+	for _, i := range issues {
+		require.Equal(t, "docker-bake.hcl", i.Filename)
+		got = append(got, issue{i.Rule, i.Line, i.Message})
+	}
+	require.Equal(t, []issue{
+		{LintUnusedVariable, 5, `variable "UNUSED" is not used`},
+		{LintUnusedFunction, 15, `function "unused" is not used`},
+		{LintDeprecated, 35, `function "formatdate" is deprecated: use formattimestamp instead`},
+		{LintShadowedInherit, 39, `target "app" inherits args.FOO of "base" is overridden by "release"`},
+		{LintUnreachableTarget, 43, `target "test" is not reachable from any group`},
+	}, got)
+}
+
+func TestLintUnknownAttribute(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+target "app" {
+  dockerfile = "Dockerfile"
+  platform = ["linux/amd64"]
+  validation {
+    condition = true
+    message = "foo"
+  }
+}
+targets "other" {
+}
+`),
+	}
+	issues, err := Lint([]File{fp}, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []LintIssue{
+		{Rule: LintUnknownAttribute, Message: `unknown attribute "platform" in target "app"`, Filename: "docker-bake.hcl", Line: 4, Column: 3},
+		{Rule: LintUnknownAttribute, Message: `unknown attribute "message" in validation`, Filename: "docker-bake.hcl", Line: 7, Column: 5},
+		{Rule: LintUnknownAttribute, Message: `unknown block type "targets"`, Filename: "docker-bake.hcl", Line: 10, Column: 1},
+	}, issues)
+}
+
+func TestLintDeprecatedAttribute(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+variable "OUTPUT" {
+  default = "type=image,buildinfo=true"
+}
+target "app" {
+  output = [
+    "type=image,name=app,buildinfo-attrs=true",
+    "type=local,dest=out",
+    OUTPUT,
+  ]
+}
+`),
+	}
+	issues, err := Lint([]File{fp}, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []LintIssue{
+		{Rule: LintDeprecated, Message: `key "buildinfo-attrs" of output in target "app" is deprecated: use attest = ["type=provenance"] instead`, Filename: "docker-bake.hcl", Line: 7, Column: 5},
+	}, issues)
+}
+
+func TestLintCheckDeprecated(t *testing.T) {
+	f, diags := hclsyntax.ParseConfig([]byte(`network = "host"`), "docker-bake.hcl", hcl.InitialPos)
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	l := &linter{}
+	l.checkDeprecated(map[string]string{"network": "use RUN --network instead"}, `target "app"`, f.Body.(*hclsyntax.Body).Attributes["network"])
+	require.Equal(t, []LintIssue{
+		{Rule: LintDeprecated, Message: `attribute "network" in target "app" is deprecated: use RUN --network instead`, Filename: "docker-bake.hcl", Line: 1, Column: 1},
+	}, l.issues)
+}
+
+func TestLintNoGroups(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+target "app" {
+  matrix = {
+    v = ["1", "2"]
+  }
+  name = "app-${v}"
+  args = {
+    V = v
+  }
+}
+target "other" {
+}
+`),
+	}
+	issues, err := Lint([]File{fp}, nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, issues)
+}
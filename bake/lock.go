@@ -0,0 +1,350 @@
+package bake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/buildx/build"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/buildx/util/urlutil"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+	spb "github.com/moby/buildkit/sourcepolicy/pb"
+	"github.com/pkg/errors"
+)
+
+// LockFilename is the name of the lock file written next to the bake
+// definition.
+const LockFilename = "docker-bake.lock"
+
+const lockVersion = 1
+
+const (
+	dockerImagePrefix = "docker-image://"
+	// buildkitSyntaxArg overrides the syntax directive of the Dockerfile.
+	buildkitSyntaxArg = "BUILDKIT_SYNTAX"
+)
+
+// Lock pins the remote sources of a bake definition so they can be fetched
+// again in the same state.
+type Lock struct {
+	Version    int                      `json:"version"`
+	Definition *LockedDefinition        `json:"definition,omitempty"`
+	Imports    map[string]*LockedImport `json:"imports,omitempty"`
+	// Images maps the normalized references of the images used by the
+	// targets to their digest.
+	Images map[string]string `json:"images,omitempty"`
+}
+
+// LockedDefinition pins a remote bake definition.
+type LockedDefinition struct {
+	Source string `json:"source"`
+	// Commit is the checksum of the commit of the git repository.
+	Commit string `json:"commit"`
+}
+
+// LockedImport pins the source of an import block.
+type LockedImport struct {
+	Source string `json:"source"`
+	// Commit is the checksum of the commit of a git source.
+	Commit string `json:"commit,omitempty"`
+	// Digest is the manifest digest of an OCI source.
+	Digest string `json:"digest,omitempty"`
+}
+
+// ReadLock reads the lock file at path. It returns an empty lock if the file
+// does not exist.
+func ReadLock(path string) (*Lock, error) {
+	dt, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Lock{Version: lockVersion}, nil
+		}
+		return nil, err
+	}
+	var l Lock
+	if err := json.Unmarshal(dt, &l); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse lock file %s", path)
+	}
+	if l.Version > lockVersion {
+		return nil, errors.Errorf("unsupported lock file version %d", l.Version)
+	}
+	l.Version = lockVersion
+	return &l, nil
+}
+
+// LockPath returns the path of the lock file of a bake definition, in the
+// directory of its first local file. It is in the working directory for
+// remote definitions and definitions read from stdin.
+func LockPath(files []File) string {
+	for _, f := range files {
+		if dir, ok := localFileDir(f.Name); ok {
+			return filepath.Join(dir, LockFilename)
+		}
+	}
+	return LockFilename
+}
+
+// Save writes the lock file to path.
+func (l *Lock) Save(path string) error {
+	dt, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(dt, '\n'), 0644)
+}
+
+// LockDefinition pins the remote bake definition read from inp. It removes
+// the pinned definition if inp is nil.
+func (l *Lock) LockDefinition(inp *Input) error {
+	if inp == nil {
+		l.Definition = nil
+		return nil
+	}
+	if inp.Commit == "" {
+		return errors.Errorf("cannot lock remote definition %s: only git repositories can be locked", inp.URL)
+	}
+	l.Definition = &LockedDefinition{Source: inp.URL, Commit: inp.Commit}
+	return nil
+}
+
+// PinDefinition returns the url of the remote bake definition pinned to the
+// locked commit.
+func (l *Lock) PinDefinition(url string) (string, error) {
+	if l.Definition == nil || l.Definition.Source != url {
+		return "", errors.Errorf("remote definition %s is not pinned in %s", url, LockFilename)
+	}
+	return pinGitURL(url, l.Definition.Commit), nil
+}
+
+// LockImages resolves the images used by the build options to their digest
+// and replaces the pinned images of the lock.
+func (l *Lock) LockImages(ctx context.Context, bo map[string]build.Options, r *imagetools.Resolver) error {
+	refs, err := LockImageRefs(bo)
+	if err != nil {
+		return err
+	}
+	images := make(map[string]string, len(refs))
+	for _, ref := range refs {
+		_, desc, err := r.Resolve(ctx, ref)
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve %s", ref)
+		}
+		images[ref] = desc.Digest.String()
+	}
+	l.Images = images
+	return nil
+}
+
+// CheckImages returns an error if an image used by the build options is not
+// pinned in the lock.
+func (l *Lock) CheckImages(bo map[string]build.Options) error {
+	refs, err := LockImageRefs(bo)
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for _, ref := range refs {
+		if _, ok := l.Images[ref]; !ok {
+			missing = append(missing, ref)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("images not pinned in %s: %s", LockFilename, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// SourcePolicy returns the rules of p followed by rules converting the
+// pinned images to their digest and denying any other image that is not
+// referenced by digest.
+func (l *Lock) SourcePolicy(p *spb.Policy) *spb.Policy {
+	out := &spb.Policy{Version: 1}
+	if p != nil {
+		out.Version = p.Version
+		out.Rules = slices.Clone(p.Rules)
+	}
+	for _, ref := range slices.Sorted(maps.Keys(l.Images)) {
+		out.Rules = append(out.Rules, &spb.Rule{
+			Action: spb.PolicyAction_CONVERT,
+			Selector: &spb.Selector{
+				Identifier: dockerImagePrefix + ref,
+				MatchType:  spb.MatchType_EXACT,
+			},
+			Updates: &spb.Update{
+				Identifier: dockerImagePrefix + ref + "@" + l.Images[ref],
+			},
+		})
+	}
+	// The last matching allow or deny rule wins, so images referenced by
+	// digest, including the converted ones, are allowed while any other
+	// image is denied.
+	out.Rules = append(out.Rules,
+		&spb.Rule{
+			Action: spb.PolicyAction_DENY,
+			Selector: &spb.Selector{
+				Identifier: dockerImagePrefix + "*",
+			},
+		},
+		&spb.Rule{
+			Action: spb.PolicyAction_ALLOW,
+			Selector: &spb.Selector{
+				Identifier: dockerImagePrefix + "*@sha256:*",
+			},
+		},
+	)
+	return out
+}
+
+// LockImageRefs returns the normalized references of the images used by the
+// build options that are not already pinned by digest: the named contexts
+// set to an image, the syntax frontend and the base images of the local
+// Dockerfiles.
+func LockImageRefs(bo map[string]build.Options) ([]string, error) {
+	refs := map[string]struct{}{}
+	add := func(name, ref string) error {
+		named, err := reference.ParseNormalizedNamed(ref)
+		if err != nil {
+			return errors.Wrapf(err, "target %s: invalid image reference %q", name, ref)
+		}
+		if _, ok := named.(reference.Digested); ok {
+			return nil
+		}
+		refs[reference.TagNameOnly(named).String()] = struct{}{}
+		return nil
+	}
+	for _, name := range slices.Sorted(maps.Keys(bo)) {
+		opt := bo[name]
+		for _, nc := range opt.Inputs.NamedContexts {
+			if ref, ok := strings.CutPrefix(nc.Path, dockerImagePrefix); ok {
+				if err := add(name, ref); err != nil {
+					return nil, err
+				}
+			}
+		}
+		dt, ok, err := readLockDockerfile(opt.Inputs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "target %s", name)
+		} else if !ok {
+			continue
+		}
+		images, err := dockerfileImages(dt, opt.BuildArgs, opt.Inputs.NamedContexts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "target %s", name)
+		}
+		for _, ref := range images {
+			if err := add(name, ref); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(refs)), nil
+}
+
+// readLockDockerfile reads the Dockerfile of the build inputs if it is
+// available locally.
+func readLockDockerfile(inp build.Inputs) ([]byte, bool, error) {
+	if inp.DockerfileInline != "" {
+		return []byte(inp.DockerfileInline), true, nil
+	}
+	if urlutil.IsRemoteURL(inp.DockerfilePath) || urlutil.IsRemoteURL(inp.ContextPath) {
+		return nil, false, nil
+	}
+	if inp.ContextState != nil && !filepath.IsAbs(inp.DockerfilePath) {
+		// Dockerfile is read from the remote bake definition
+		return nil, false, nil
+	}
+	dt, err := os.ReadFile(inp.DockerfilePath)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to read dockerfile")
+	}
+	return dt, true, nil
+}
+
+// dockerfileImages returns the images referenced by the syntax directive and
+// the FROM instructions of the Dockerfile, skipping the stages and named
+// contexts.
+func dockerfileImages(dt []byte, args map[string]string, contexts map[string]build.NamedContext) ([]string, error) {
+	var images []string
+	if v, ok := args[buildkitSyntaxArg]; ok && v != "" {
+		images = append(images, v)
+	} else if v, _, _, ok := parser.DetectSyntax(dt); ok {
+		images = append(images, v)
+	}
+
+	res, err := parser.Parse(bytes.NewReader(dt))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse dockerfile")
+	}
+	lex := shell.NewLex(res.EscapeToken)
+
+	// only the args declared before the first stage can be used in FROM
+	env := envGetter{}
+	stages := map[string]struct{}{}
+	for _, node := range res.AST.Children {
+		switch strings.ToLower(node.Value) {
+		case "arg":
+			if len(stages) > 0 {
+				continue
+			}
+			for n := node.Next; n != nil; n = n.Next {
+				k, v, hasDefault := strings.Cut(n.Value, "=")
+				if override, ok := args[k]; ok {
+					env[k] = override
+					continue
+				}
+				if !hasDefault {
+					continue
+				}
+				v, _, err := lex.ProcessWord(v, env)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to expand %s", n.Value)
+				}
+				env[k] = v
+			}
+		case "from":
+			if node.Next == nil {
+				continue
+			}
+			image, _, err := lex.ProcessWord(node.Next.Value, env)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to expand %s", node.Next.Value)
+			}
+			// unnamed stages are tracked by index so the args of the
+			// following stages are still ignored
+			stage := strconv.Itoa(len(stages))
+			if n := node.Next.Next; n != nil && strings.EqualFold(n.Value, "as") && n.Next != nil {
+				stage = strings.ToLower(n.Next.Value)
+			}
+			if image == "" {
+				return nil, errors.Errorf("base image %s expands to an empty name", node.Next.Value)
+			}
+			_, isStage := stages[strings.ToLower(image)]
+			_, isContext := contexts[image]
+			if !isStage && !isContext && image != "scratch" {
+				images = append(images, image)
+			}
+			stages[stage] = struct{}{}
+		}
+	}
+	return images, nil
+}
+
+type envGetter map[string]string
+
+func (e envGetter) Get(key string) (string, bool) {
+	v, ok := e[key]
+	return v, ok
+}
+
+func (e envGetter) Keys() []string {
+	return slices.Collect(maps.Keys(e))
+}
@@ -0,0 +1,126 @@
+package bake
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/buildx/build"
+	spb "github.com/moby/buildkit/sourcepolicy/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockImageRefs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(`# syntax=docker/dockerfile:1
+ARG GO_VERSION=1.24
+ARG ALPINE_VERSION=3.20
+ARG BASE=alpine:${ALPINE_VERSION}
+FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build
+FROM build AS test
+FROM ${BASE}
+ARG IGNORED=busybox
+FROM scratch
+FROM deps
+FROM debian@sha256:d1ae1a2a1b10f8c63e32b1e3c0c8b6a1e6f5e1f4ae1e0b0b0d1f0f2d2a1b3c4d
+`), 0644))
+
+	refs, err := LockImageRefs(map[string]build.Options{
+		"app": {
+			Inputs: build.Inputs{
+				ContextPath:    dir,
+				DockerfilePath: filepath.Join(dir, "Dockerfile"),
+				NamedContexts: map[string]build.NamedContext{
+					"deps":  {Path: "target:deps"},
+					"tools": {Path: "docker-image://docker/buildx-bin"},
+				},
+			},
+			BuildArgs: map[string]string{"ALPINE_VERSION": "3.22"},
+		},
+		"inline": {
+			Inputs: build.Inputs{
+				ContextPath:      "https://github.com/docker/buildx.git",
+				DockerfileInline: "FROM busybox:1.37\n",
+			},
+			BuildArgs: map[string]string{"BUILDKIT_SYNTAX": "docker/dockerfile-upstream:master"},
+		},
+		"remote": {
+			Inputs: build.Inputs{
+				ContextPath:    "https://github.com/docker/buildx.git",
+				DockerfilePath: "Dockerfile",
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"docker.io/docker/buildx-bin:latest",
+		"docker.io/docker/dockerfile-upstream:master",
+		"docker.io/docker/dockerfile:1",
+		"docker.io/library/alpine:3.22",
+		"docker.io/library/busybox:1.37",
+		"docker.io/library/golang:1.24",
+	}, refs)
+}
+
+func TestLockImageRefsInvalid(t *testing.T) {
+	_, err := LockImageRefs(map[string]build.Options{
+		"app": {
+			Inputs: build.Inputs{DockerfileInline: "FROM ${BASE}\n"},
+		},
+	})
+	require.ErrorContains(t, err, "expands to an empty name")
+}
+
+func TestLockCheckImages(t *testing.T) {
+	bo := map[string]build.Options{
+		"app": {
+			Inputs: build.Inputs{DockerfileInline: "FROM alpine:3.22\nFROM busybox\n"},
+		},
+	}
+	lock := &Lock{Version: lockVersion, Images: map[string]string{
+		"docker.io/library/alpine:3.22": "sha256:4bcff63911fcb4448bd4fdacec207030997caf25e9bea4045fa6c8c44de311d1",
+	}}
+	require.ErrorContains(t, lock.CheckImages(bo), "images not pinned in docker-bake.lock: docker.io/library/busybox:latest")
+
+	lock.Images["docker.io/library/busybox:latest"] = "sha256:f85340bf132ae937d2c2a763b8335c9bab35d6e8293f70f606b9c6178d84f42b"
+	require.NoError(t, lock.CheckImages(bo))
+}
+
+func TestLockSourcePolicy(t *testing.T) {
+	lock := &Lock{Version: lockVersion, Images: map[string]string{
+		"docker.io/library/alpine:3.22": "sha256:4bcff63911fcb4448bd4fdacec207030997caf25e9bea4045fa6c8c44de311d1",
+	}}
+	existing := &spb.Policy{Version: 1, Rules: []*spb.Rule{{
+		Action:   spb.PolicyAction_DENY,
+		Selector: &spb.Selector{Identifier: "https://*"},
+	}}}
+	p := lock.SourcePolicy(existing)
+	require.Len(t, existing.Rules, 1)
+	require.Len(t, p.Rules, 4)
+	require.Equal(t, "https://*", p.Rules[0].Selector.Identifier)
+	require.Equal(t, spb.PolicyAction_CONVERT, p.Rules[1].Action)
+	require.Equal(t, spb.MatchType_EXACT, p.Rules[1].Selector.MatchType)
+	require.Equal(t, "docker-image://docker.io/library/alpine:3.22", p.Rules[1].Selector.Identifier)
+	require.Equal(t, "docker-image://docker.io/library/alpine:3.22@sha256:4bcff63911fcb4448bd4fdacec207030997caf25e9bea4045fa6c8c44de311d1", p.Rules[1].Updates.Identifier)
+	require.Equal(t, spb.PolicyAction_DENY, p.Rules[2].Action)
+	require.Equal(t, "docker-image://*", p.Rules[2].Selector.Identifier)
+	require.Equal(t, spb.PolicyAction_ALLOW, p.Rules[3].Action)
+	require.Equal(t, "docker-image://*@sha256:*", p.Rules[3].Selector.Identifier)
+}
+
+func TestLockDefinition(t *testing.T) {
+	lock := &Lock{Version: lockVersion}
+	_, err := lock.PinDefinition("https://github.com/docker/buildx.git#main")
+	require.ErrorContains(t, err, "is not pinned")
+
+	require.ErrorContains(t, lock.LockDefinition(&Input{URL: "https://example.com/bake.tar.gz"}), "only git repositories can be locked")
+
+	const commit = "4c5f6e5e4f0e8b2b7a6f1d2c3b4a5968778695a4"
+	require.NoError(t, lock.LockDefinition(&Input{URL: "https://github.com/docker/buildx.git#main", Commit: commit}))
+	url, err := lock.PinDefinition("https://github.com/docker/buildx.git#main")
+	require.NoError(t, err)
+	require.Equal(t, "https://github.com/docker/buildx.git#"+commit, url)
+
+	_, err = lock.PinDefinition("https://github.com/docker/buildx.git#v0.20.0")
+	require.ErrorContains(t, err, "is not pinned")
+}
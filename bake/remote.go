@@ -15,9 +15,11 @@ import (
 	"github.com/docker/go-units"
 	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
 	"github.com/moby/buildkit/frontend/dockerui"
 	gwclient "github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/solver/pb"
 	"github.com/pkg/errors"
 )
 
@@ -26,15 +28,31 @@ const maxBakeDefinitionSize = 2 * 1024 * 1024 // 2 MB
 type Input struct {
 	State *llb.State
 	URL   string
+	// Commit is the checksum of the commit checked out from a git
+	// repository, if resolved.
+	Commit string
 }
 
 func ReadRemoteFiles(ctx context.Context, nodes []builder.Node, url string, names []string, pw progress.Writer) ([]File, *Input, error) {
+	return readRemoteFiles(ctx, nodes, url, names, pw, false)
+}
+
+// ReadRemoteFilesWithCommit is like ReadRemoteFiles but also resolves the
+// commit checked out if url is a git repository.
+func ReadRemoteFilesWithCommit(ctx context.Context, nodes []builder.Node, url string, names []string, pw progress.Writer) ([]File, *Input, error) {
+	return readRemoteFiles(ctx, nodes, url, names, pw, true)
+}
+
+// readRemoteFiles reads the bake files from a remote url. If resolveCommit
+// is set and url is a git repository, the checksum of the checked out commit
+// is set in the Commit field of the returned input.
+func readRemoteFiles(ctx context.Context, nodes []builder.Node, url string, names []string, pw progress.Writer, resolveCommit bool) ([]File, *Input, error) {
 	var sessions []session.Attachable
 	var filename string
 
 	keepGitDir := false
-	st, ok, err := dockerui.DetectGitContext(url, &keepGitDir)
-	if ok {
+	st, isGit, err := dockerui.DetectGitContext(url, &keepGitDir)
+	if isGit {
 		if err != nil {
 			return nil, nil, err
 		}
@@ -50,6 +68,7 @@ func ReadRemoteFiles(ctx context.Context, nodes []builder.Node, url string, name
 			}
 		}
 	} else {
+		var ok bool
 		st, filename, ok = dockerui.DetectHTTPContext(url)
 		if !ok {
 			return nil, nil, errors.Errorf("not url context")
@@ -75,6 +94,7 @@ func ReadRemoteFiles(ctx context.Context, nodes []builder.Node, url string, name
 		return nil, nil, err
 	}
 
+	var commit string
 	ch, done := progress.NewChannel(pw)
 	defer func() { <-done }()
 	_, err = c.Build(ctx, client.SolveOpt{Session: sessions, Internal: true}, "buildx", func(ctx context.Context, c gwclient.Client) (*gwclient.Result, error) {
@@ -89,6 +109,13 @@ func ReadRemoteFiles(ctx context.Context, nodes []builder.Node, url string, name
 			return nil, err
 		}
 
+		if isGit && resolveCommit {
+			commit, err = resolveGitCommit(ctx, c, def)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		ref, err := res.SingleRef()
 		if err != nil {
 			return nil, err
@@ -105,9 +132,39 @@ func ReadRemoteFiles(ctx context.Context, nodes []builder.Node, url string, name
 		return nil, nil, err
 	}
 
+	inp.Commit = commit
 	return files, inp, nil
 }
 
+// resolveGitCommit returns the checksum of the commit checked out by the git
+// source of the definition.
+func resolveGitCommit(ctx context.Context, c gwclient.Client, def *llb.Definition) (string, error) {
+	for _, dt := range def.Def {
+		var op pb.Op
+		if err := op.UnmarshalVT(dt); err != nil {
+			return "", err
+		}
+		src := op.GetSource()
+		if src == nil || !strings.HasPrefix(src.Identifier, "git://") {
+			continue
+		}
+		res, err := c.ResolveSourceMetadata(ctx, src, sourceresolver.Opt{
+			GitOpt: &sourceresolver.ResolveGitOpt{},
+		})
+		if err != nil {
+			return "", errors.Wrap(err, "failed to resolve git commit")
+		}
+		if res.Git == nil {
+			break
+		}
+		if res.Git.CommitChecksum != "" {
+			return res.Git.CommitChecksum, nil
+		}
+		return res.Git.Checksum, nil
+	}
+	return "", errors.New("failed to resolve git commit")
+}
+
 func isArchive(header []byte) bool {
 	for _, m := range [][]byte{
 		{0x42, 0x5A, 0x68},                   // bzip2
@@ -97,6 +97,9 @@ type Options struct {
 	GroupRef               string
 	Annotations            map[exptypes.AnnotationKey]string // Not used during build, annotations are already set in Exports. Just used to check for support with drivers.
 	Policy                 []buildflags.PolicyConfig
+	DependsOn              []string // DependsOn lists the targets that must complete successfully before this one starts.
+	KeepGoing              bool     // KeepGoing does not cancel the other targets if this one fails.
+	Retry                  *RetryPolicy
 }
 
 // ResourceLimits holds the cgroup resource constraints applied to individual
@@ -437,6 +440,14 @@ type (
 	}
 )
 
+// fail unblocks the targets linked to key after it failed before completing.
+// Children waiting for its result fail while parents are released.
+func (s *linkedTargetState) fail(key string, err error) {
+	s.results.Set(key, err)
+	s.evaluated.Set(key, err)
+	s.completed.Set(key, err)
+}
+
 func newLinkedTargetState(parents, children map[string][]string) *linkedTargetState {
 	return &linkedTargetState{
 		results:   waitmap.New(),
@@ -461,9 +472,15 @@ func (s *linkedTargetState) run(ctx context.Context, key string, result any, eva
 	}
 	// Evaluation follows dependency order so the target's own session is attached
 	// to shared solver vertices before a dependent can evaluate them.
-	if _, err := s.evaluated.Get(ctx, s.parents[key]...); err != nil {
+	evaluated, err := s.evaluated.Get(ctx, s.parents[key]...)
+	if err != nil {
 		return err
 	}
+	for _, parent := range s.parents[key] {
+		if err, ok := evaluated[parent].(error); ok && err != nil {
+			return errors.Errorf("linked target %s failed", parent)
+		}
+	}
 	if err := evaluate(); err != nil {
 		return err
 	}
@@ -492,6 +509,10 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 	}
 	warnOnNoOutput(ctx, nodes, opts)
 
+	if err := validateDependsOn(opts); err != nil {
+		return nil, err
+	}
+
 	optPlatforms := make(map[string][]ocispecs.Platform, len(opts))
 	for k, opt := range opts {
 		optPlatforms[k] = opt.Platforms
@@ -523,12 +544,16 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 
 	resp = map[string]*client.SolveResponse{}
 	var respMu sync.Mutex
+	targetErrs := map[string]error{}
 
 	multiTarget := len(opts) > 1
 	linkedTargets := newLinkedTargetState(calculateTargetLinks(reqForNodes, opts))
 	// linkedClients is only accessed from the synchronous part of the target
 	// loop below, before any goroutines are spawned; no mutex needed.
 	linkedClients := make(map[string]*client.Client)
+	// dependencies holds the result of each target for the ones depending on
+	// it: nil on success or the error that made it fail.
+	dependencies := waitmap.New()
 
 	for k, opt := range opts {
 		err := func(k string) (err error) {
@@ -637,6 +662,9 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 
 					pw = progress.ResetTime(pw)
 
+					if err := waitDependencies(ctx, dependencies, opt.DependsOn); err != nil {
+						return err
+					}
 					if err := waitContextDeps(ctx, dp, linkedTargets.results, so); err != nil {
 						return err
 					}
@@ -659,9 +687,6 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 					so.Frontend = ""
 					so.FrontendInputs = nil
 
-					ch, done := progress.NewChannel(pw)
-					defer func() { <-done }()
-
 					var (
 						callRes     map[string][]byte
 						frontendErr error
@@ -705,14 +730,49 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 						return res, nil
 					}
 
-					buildRef := fmt.Sprintf("%s/%s/%s", node.Builder, node.Name, so.Ref)
+					// linked targets share their results with other targets and
+					// stdin can only be read once, so these are never retried
+					retry := opt.Retry
+					if linkedTargets.isLinked(rKey) || opt.Inputs.ContextPath == "-" || opt.Inputs.DockerfilePath == "-" {
+						retry = nil
+					}
 
-					span, ctx := tracing.StartSpan(ctx, "build")
-					rr, err := c.Build(ctx, *so, "buildx", buildFunc, ch)
-					if errors.Is(frontendErr, ErrRestart) {
-						err = ErrRestart
+					var (
+						rr       *client.SolveResponse
+						buildRef string
+						err      error
+					)
+					for attempt := 1; ; attempt++ {
+						if attempt > 1 {
+							// each attempt is recorded as its own build in history
+							so.Ref = identity.NewID()
+							if err := saveLocalState(so, k, opt, node, cfg); err != nil {
+								return err
+							}
+						}
+						buildRef = fmt.Sprintf("%s/%s/%s", node.Builder, node.Name, so.Ref)
+
+						callRes, frontendErr = nil, nil
+						ch, done := progress.NewChannel(pw)
+						span, ctx := tracing.StartSpan(ctx, "build")
+						rr, err = c.Build(ctx, *so, "buildx", buildFunc, ch)
+						if errors.Is(frontendErr, ErrRestart) {
+							err = ErrRestart
+						}
+						tracing.FinishWithError(span, err)
+						<-done
+
+						class, ok := retry.retryable(err, attempt)
+						if !ok {
+							break
+						}
+						if !so.Internal && desktop.BuildBackendEnabled() && node.Driver.HistoryAPISupported(ctx) {
+							progress.WriteBuildRef(w, k, buildRef)
+						}
+						if err := retry.wait(ctx, pw, class, attempt, err); err != nil {
+							return err
+						}
 					}
-					tracing.FinishWithError(span, err)
 
 					if !so.Internal && desktop.BuildBackendEnabled() && node.Driver.HistoryAPISupported(ctx) {
 						if err != nil {
@@ -791,6 +851,25 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 
 			eg.Go(func() (err error) {
 				ctx := baseCtx
+				defer func() {
+					depErr := err
+					if depErr == nil {
+						depErr = callResultError(opt.CallFunc, res)
+					}
+					dependencies.Set(k, depErr)
+					if err == nil {
+						return
+					}
+					for _, dp := range dps {
+						linkedTargets.fail(resultKey(dp, k), err)
+					}
+					if opt.KeepGoing {
+						respMu.Lock()
+						targetErrs[k] = err
+						respMu.Unlock()
+						err = nil
+					}
+				}()
 				defer func() {
 					if span != nil {
 						tracing.FinishWithError(span, err)
@@ -933,10 +1012,35 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 	if err := eg.Wait(); err != nil {
 		return nil, err
 	}
+	if len(targetErrs) > 0 {
+		return resp, &TargetsError{Errors: targetErrs}
+	}
 
 	return resp, nil
 }
 
+// TargetsError is returned when targets built with KeepGoing failed. The
+// response of the targets that succeeded is returned along with it.
+type TargetsError struct {
+	Errors map[string]error
+}
+
+func (e *TargetsError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, k := range slices.Sorted(maps.Keys(e.Errors)) {
+		msgs = append(msgs, e.Errors[k].Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e *TargetsError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, k := range slices.Sorted(maps.Keys(e.Errors)) {
+		errs = append(errs, e.Errors[k])
+	}
+	return errs
+}
+
 func extractIndexAnnotations(exports []client.ExportEntry) (map[exptypes.AnnotationKey]string, error) {
 	annotations := map[exptypes.AnnotationKey]string{}
 	for _, exp := range exports {
@@ -1210,6 +1314,69 @@ func calculateTargetLinks(reqs map[string][]*reqForNode, opt map[string]Options)
 	return parents, children
 }
 
+func validateDependsOn(opts map[string]Options) error {
+	visited := map[string]bool{}
+	var walk func(name string, stack []string) error
+	walk = func(name string, stack []string) error {
+		if slices.Contains(stack, name) {
+			return errors.Errorf("infinite loop from %s to %s", stack[len(stack)-1], name)
+		}
+		if visited[name] {
+			return nil
+		}
+		stack = append(stack, name)
+		for _, dep := range opts[name].DependsOn {
+			if _, ok := opts[dep]; !ok {
+				return errors.Errorf("target %s depends on unknown target %s", name, dep)
+			}
+			if err := walk(dep, stack); err != nil {
+				return err
+			}
+		}
+		visited[name] = true
+		return nil
+	}
+	for name := range opts {
+		if err := walk(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func waitDependencies(ctx context.Context, results *waitmap.Map, deps []string) error {
+	res, err := results.Get(ctx, deps...)
+	if err != nil {
+		return err
+	}
+	for _, dep := range deps {
+		if err, ok := res[dep].(error); ok && err != nil {
+			return errors.Errorf("dependency %s failed", dep)
+		}
+	}
+	return nil
+}
+
+// callResultError returns an error if a call subrequest, like a check, ended
+// with a non-zero status. The build itself succeeds in that case, but targets
+// depending on it must not start.
+func callResultError(f *CallFunc, res []*client.SolveResponse) error {
+	if f == nil || f.IgnoreStatus {
+		return nil
+	}
+	for _, r := range res {
+		if r == nil {
+			continue
+		}
+		if v, ok := r.ExporterResponse["result.statuscode"]; ok {
+			if n, err := strconv.Atoi(v); err == nil && n != 0 {
+				return errors.Errorf("%s returned status code %d", f.Name, n)
+			}
+		}
+	}
+	return nil
+}
+
 func waitContextDeps(ctx context.Context, node *noderesolver.ResolvedNode, results *waitmap.Map, so *client.SolveOpt) error {
 	m := map[string][]string{}
 	for k, v := range so.FrontendAttrs {
@@ -1235,6 +1402,9 @@ func waitContextDeps(ctx context.Context, node *noderesolver.ResolvedNode, resul
 		if !ok {
 			continue
 		}
+		if err, ok := r.(error); ok && err != nil {
+			return errors.Errorf("linked target %s failed", k)
+		}
 		rr, ok := r.(*gateway.Result)
 		if !ok {
 			return errors.Errorf("invalid result type %T", rr)
@@ -0,0 +1,17 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetsError(t *testing.T) {
+	errA := errors.New("target a: exit code 1")
+	errB := errors.New("target b: dependency a failed")
+	err := &TargetsError{Errors: map[string]error{"b": errB, "a": errA}}
+	require.EqualError(t, err, "target a: exit code 1\ntarget b: dependency a failed")
+	require.ErrorIs(t, err, errA)
+	require.ErrorIs(t, err, errB)
+}
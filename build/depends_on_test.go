@@ -0,0 +1,53 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/docker/buildx/util/waitmap"
+	"github.com/moby/buildkit/client"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDependsOn(t *testing.T) {
+	require.NoError(t, validateDependsOn(map[string]Options{
+		"app":  {DependsOn: []string{"test", "lint"}},
+		"test": {DependsOn: []string{"lint"}},
+		"lint": {},
+	}))
+
+	err := validateDependsOn(map[string]Options{
+		"app": {DependsOn: []string{"test"}},
+	})
+	require.ErrorContains(t, err, "target app depends on unknown target test")
+
+	err = validateDependsOn(map[string]Options{
+		"a": {DependsOn: []string{"b"}},
+		"b": {DependsOn: []string{"c"}},
+		"c": {DependsOn: []string{"a"}},
+	})
+	require.ErrorContains(t, err, "infinite loop")
+}
+
+func TestWaitDependencies(t *testing.T) {
+	results := waitmap.New()
+	results.Set("lint", nil)
+	require.NoError(t, waitDependencies(t.Context(), results, []string{"lint"}))
+
+	results.Set("test", errors.New("exit code 1"))
+	require.EqualError(t, waitDependencies(t.Context(), results, []string{"lint", "test"}), "dependency test failed")
+
+	require.NoError(t, waitDependencies(t.Context(), results, nil))
+}
+
+func TestCallResultError(t *testing.T) {
+	check := &CallFunc{Name: "lint"}
+	passed := []*client.SolveResponse{{ExporterResponse: map[string]string{"result.statuscode": "0"}}}
+	failed := []*client.SolveResponse{{ExporterResponse: map[string]string{"result.statuscode": "1"}}}
+
+	require.NoError(t, callResultError(nil, failed))
+	require.NoError(t, callResultError(check, passed))
+	require.NoError(t, callResultError(check, []*client.SolveResponse{nil}))
+	require.NoError(t, callResultError(&CallFunc{Name: "lint", IgnoreStatus: true}, failed))
+	require.EqualError(t, callResultError(check, append(passed, failed...)), "lint returned status code 1")
+}
@@ -19,6 +19,23 @@ func TestLinkedTargetStateIsLinked(t *testing.T) {
 	require.False(t, state.isLinked("standalone"))
 }
 
+func TestLinkedTargetStateFail(t *testing.T) {
+	state := newLinkedTargetState(
+		map[string][]string{"child": {"parent"}},
+		map[string][]string{"parent": {"child"}},
+	)
+
+	state.fail("parent", errors.New("parent failed"))
+
+	var evaluated bool
+	err := state.run(t.Context(), "child", struct{}{}, func() error {
+		evaluated = true
+		return nil
+	})
+	require.EqualError(t, err, "linked target parent failed")
+	require.False(t, evaluated)
+}
+
 func TestLinkedTargetStateChainRetainsParents(t *testing.T) {
 	parents := map[string][]string{
 		"middle": {"root"},
@@ -454,7 +454,16 @@ func toSolveOpt(ctx context.Context, np *noderesolver.ResolvedNode, multiDriver
 			return nil, nil, notSupported(driver.OCIExporter, nodeDriver, "https://docs.docker.com/go/build-exporters/")
 		}
 		if e.Type == "docker" {
-			features := docker.Features(ctx, e.Attrs["context"])
+			// drivers of other container engines load the image into their
+			// own engine, unless a Docker context is set explicitly
+			loader, _ := nodeDriver.Driver.(driver.ImageLoader)
+			if e.Attrs["context"] != "" {
+				loader = nil
+			}
+			features := map[dockerutil.Feature]bool{}
+			if loader == nil {
+				features = docker.Features(ctx, e.Attrs["context"])
+			}
 			if features[dockerutil.OCIImporter] && e.Output == nil {
 				// rely on oci importer if available (which supports
 				// multi-platform images), otherwise fall back to docker
@@ -469,7 +478,13 @@ func toSolveOpt(ctx context.Context, np *noderesolver.ResolvedNode, multiDriver
 				if nodeDriver.IsMobyDriver() {
 					e.Type = "image"
 				} else {
-					w, cancel, err := docker.LoadImage(ctx, e.Attrs["context"], pw)
+					var w io.WriteCloser
+					var cancel func()
+					if loader != nil {
+						w, cancel, err = loader.LoadImage(ctx, pw)
+					} else {
+						w, cancel, err = docker.LoadImage(ctx, e.Attrs["context"], pw)
+					}
 					if err != nil {
 						return nil, nil, err
 					}
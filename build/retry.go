@@ -0,0 +1,173 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/buildx/util/buildflags"
+	"github.com/docker/buildx/util/progress"
+	"github.com/pkg/errors"
+)
+
+// Failure classes that can be retried with a RetryPolicy.
+const (
+	RetryOnPush        = "push"
+	RetryOnCacheImport = "cache-import"
+	RetryOnContext     = "context"
+)
+
+const (
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 5 * time.Second
+	maxRetryBackoff      = 5 * time.Minute
+)
+
+// RetryPolicy configures how a target is re-solved after a transient failure.
+type RetryPolicy struct {
+	// Attempts is the total number of attempts, including the first one.
+	Attempts int
+	// Backoff is the delay before the first retry. It is doubled for each
+	// subsequent attempt.
+	Backoff time.Duration
+	// On lists the failure classes that are retried.
+	On []string
+}
+
+// ParseRetryPolicy validates the retry config and fills in defaults for the
+// unset fields. It returns nil if cfg is nil.
+func ParseRetryPolicy(cfg *buildflags.RetryConfig) (*RetryPolicy, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	rp := &RetryPolicy{
+		Attempts: defaultRetryAttempts,
+		Backoff:  defaultRetryBackoff,
+		On:       []string{RetryOnPush, RetryOnCacheImport, RetryOnContext},
+	}
+	if cfg.Attempts != nil {
+		if *cfg.Attempts < 1 {
+			return nil, errors.Errorf("invalid retry attempts %d, must be at least 1", *cfg.Attempts)
+		}
+		rp.Attempts = int(*cfg.Attempts)
+	}
+	if cfg.Backoff != nil {
+		d, err := time.ParseDuration(*cfg.Backoff)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid retry backoff %q", *cfg.Backoff)
+		}
+		if d < 0 {
+			return nil, errors.Errorf("invalid retry backoff %q, must not be negative", *cfg.Backoff)
+		}
+		rp.Backoff = d
+	}
+	if cfg.On != nil {
+		rp.On = nil
+		for _, v := range cfg.On {
+			switch v {
+			case RetryOnPush, RetryOnCacheImport, RetryOnContext:
+				if !slices.Contains(rp.On, v) {
+					rp.On = append(rp.On, v)
+				}
+			default:
+				return nil, errors.Errorf("invalid retry class %q, must be one of %s, %s or %s", v, RetryOnPush, RetryOnCacheImport, RetryOnContext)
+			}
+		}
+	}
+	return rp, nil
+}
+
+// retryable returns the failure class of err if it should be retried after
+// the given attempt.
+func (rp *RetryPolicy) retryable(err error, attempt int) (string, bool) {
+	if rp == nil || err == nil || attempt >= rp.Attempts {
+		return "", false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrRestart) {
+		return "", false
+	}
+	class := retryClass(err)
+	if class == "" || !slices.Contains(rp.On, class) {
+		return "", false
+	}
+	return class, true
+}
+
+// backoff returns the delay before the attempt following the given one.
+func (rp *RetryPolicy) backoff(attempt int) time.Duration {
+	d := rp.Backoff
+	for i := 1; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryBackoff)
+}
+
+// wait logs the failed attempt to the progress output and waits for the
+// backoff delay.
+func (rp *RetryPolicy) wait(ctx context.Context, pw progress.Writer, class string, attempt int, err error) error {
+	d := rp.backoff(attempt)
+	name := fmt.Sprintf("[retry] %s failure on attempt %d/%d, retrying in %s", class, attempt, rp.Attempts, d)
+	return progress.Wrap(name, pw.Write, func(l progress.SubLogger) error {
+		l.Log(2, []byte(err.Error()+"\n"))
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-t.C:
+			return nil
+		}
+	})
+}
+
+// Error messages returned by BuildKit that are known to be transient for
+// each retry class. BuildKit doesn't return typed errors for these so they
+// are matched on the error message.
+var retryClassMessages = map[string][]string{
+	RetryOnPush: {
+		"failed to push",
+		"failed to export",
+		"error exporting",
+	},
+	RetryOnCacheImport: {
+		"cache importer",
+		"importing cache manifest",
+		"failed to load cache",
+	},
+	RetryOnContext: {
+		"failed to fetch remote",
+		"failed to resolve source metadata",
+		"invalid response status",
+	},
+}
+
+// Error messages that are never fixed by retrying.
+var nonRetryableMessages = []string{
+	"unauthorized",
+	"authentication required",
+	"access denied",
+	"insufficient_scope",
+	"403 forbidden",
+	"manifest unknown",
+	"repository does not exist",
+	": not found",
+}
+
+func retryClass(err error) string {
+	msg := strings.ToLower(err.Error())
+	for _, m := range nonRetryableMessages {
+		if strings.Contains(msg, m) {
+			return ""
+		}
+	}
+	for _, class := range []string{RetryOnPush, RetryOnCacheImport, RetryOnContext} {
+		for _, m := range retryClassMessages[class] {
+			if strings.Contains(msg, m) {
+				return class
+			}
+		}
+	}
+	return ""
+}
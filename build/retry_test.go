@@ -0,0 +1,76 @@
+package build
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/buildx/util/buildflags"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryPolicy(t *testing.T) {
+	rp, err := ParseRetryPolicy(nil)
+	require.NoError(t, err)
+	require.Nil(t, rp)
+
+	rp, err = ParseRetryPolicy(&buildflags.RetryConfig{})
+	require.NoError(t, err)
+	require.Equal(t, &RetryPolicy{
+		Attempts: defaultRetryAttempts,
+		Backoff:  defaultRetryBackoff,
+		On:       []string{RetryOnPush, RetryOnCacheImport, RetryOnContext},
+	}, rp)
+
+	attempts := int64(0)
+	_, err = ParseRetryPolicy(&buildflags.RetryConfig{Attempts: &attempts})
+	require.ErrorContains(t, err, "invalid retry attempts 0")
+
+	backoff := "soon"
+	_, err = ParseRetryPolicy(&buildflags.RetryConfig{Backoff: &backoff})
+	require.ErrorContains(t, err, `invalid retry backoff "soon"`)
+
+	_, err = ParseRetryPolicy(&buildflags.RetryConfig{On: []string{"push", "build"}})
+	require.ErrorContains(t, err, `invalid retry class "build"`)
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	rp := &RetryPolicy{Attempts: 3, Backoff: time.Second, On: []string{RetryOnPush, RetryOnContext}}
+
+	pushErr := errors.New("failed to solve: failed to push docker.io/foo/bar:latest: unexpected status from PUT request: 502 Bad Gateway")
+	class, ok := rp.retryable(pushErr, 1)
+	require.True(t, ok)
+	require.Equal(t, RetryOnPush, class)
+	_, ok = rp.retryable(pushErr, 3)
+	require.False(t, ok, "no attempts left")
+
+	_, ok = rp.retryable(errors.New("failed to push docker.io/foo/bar:latest: unauthorized: authentication required"), 1)
+	require.False(t, ok, "auth errors are not transient")
+
+	_, ok = rp.retryable(errors.New("failed to configure registry cache importer: connection reset by peer"), 1)
+	require.False(t, ok, "cache import is not enabled")
+
+	class, ok = rp.retryable(errors.New("failed to fetch remote https://github.com/docker/buildx.git: exit status 128"), 1)
+	require.True(t, ok)
+	require.Equal(t, RetryOnContext, class)
+
+	_, ok = rp.retryable(errors.New(`process "/bin/sh -c make" did not complete successfully: exit code: 2`), 1)
+	require.False(t, ok)
+
+	_, ok = rp.retryable(errors.Wrap(context.Canceled, "failed to push"), 1)
+	require.False(t, ok)
+
+	var nilPolicy *RetryPolicy
+	_, ok = nilPolicy.retryable(pushErr, 1)
+	require.False(t, ok)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	rp := &RetryPolicy{Attempts: 10, Backoff: time.Minute}
+	require.Equal(t, time.Minute, rp.backoff(1))
+	require.Equal(t, 2*time.Minute, rp.backoff(2))
+	require.Equal(t, 4*time.Minute, rp.backoff(3))
+	require.Equal(t, maxRetryBackoff, rp.backoff(4))
+	require.Equal(t, maxRetryBackoff, rp.backoff(9))
+}
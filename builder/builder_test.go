@@ -84,6 +84,17 @@ foo = "bar"
 			},
 			false,
 		},
+		{
+			"podman no flags",
+			"",
+			"podman",
+			nil,
+			"",
+			[]string{
+				"--allow-insecure-entitlement=network.host",
+			},
+			false,
+		},
 		{
 			"remote no flags",
 			"",
@@ -158,6 +158,7 @@ func (b *Builder) LoadNodes(ctx context.Context, opts ...LoadNodesOption) (_ []N
 					Platforms:       n.Platforms,
 					ContextPathHash: b.opts.contextPathHash,
 					DialMeta:        lno.dialMeta,
+					ConfigDir:       confutil.NewConfig(b.opts.dockerCli).Dir(),
 				})
 				if err != nil {
 					node.Err = err
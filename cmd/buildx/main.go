@@ -28,6 +28,8 @@ import (
 	_ "github.com/docker/buildx/driver/docker"
 	_ "github.com/docker/buildx/driver/docker-container"
 	_ "github.com/docker/buildx/driver/kubernetes"
+	_ "github.com/docker/buildx/driver/local-process"
+	_ "github.com/docker/buildx/driver/podman"
 	_ "github.com/docker/buildx/driver/remote"
 
 	// Use custom grpc codec to utilize vtprotobuf
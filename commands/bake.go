@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"slices"
 	"sort"
@@ -32,20 +33,27 @@ import (
 	"github.com/docker/buildx/util/desktop"
 	"github.com/docker/buildx/util/dockerutil"
 	"github.com/docker/buildx/util/dockerutil/dockerconfig"
+	"github.com/docker/buildx/util/imagetools"
 	"github.com/docker/buildx/util/osutil"
 	"github.com/docker/buildx/util/progress"
 	"github.com/docker/buildx/util/tracing"
 	"github.com/docker/buildx/util/urlutil"
 	"github.com/docker/cli/cli/command"
+	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/identity"
 	"github.com/moby/buildkit/session/auth/authprovider"
 	"github.com/moby/buildkit/util/progress/progressui"
+	"github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/tonistiigi/go-csvvalue"
 	"go.opentelemetry.io/otel/attribute"
 )
 
+// bakeExitCodePartialFailure is the exit code when some targets failed while
+// others succeeded with --keep-going.
+const bakeExitCodePartialFailure = 2
+
 const (
 	bakeEnvFileSeparator = "BUILDX_BAKE_PATH_SEPARATOR"
 	bakeEnvFilePath      = "BUILDX_BAKE_FILE"
@@ -67,9 +75,16 @@ type bakeOptions struct {
 	exportPush   bool
 	exportLoad   bool
 	callFunc     string
+	keepGoing    bool
+	failFast     bool
 
-	print bool
-	list  string
+	print        bool
+	graph        string
+	list         string
+	printOutputs string
+	changedOnly  bool
+	lock         bool
+	locked       bool
 
 	// TODO: remove deprecated flags
 	listTargets bool
@@ -111,6 +126,13 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 	if callFunc != nil {
 		overrides = append(overrides, fmt.Sprintf("*.call=%s", callFunc.Name))
 	}
+	if in.keepGoing && in.failFast {
+		return errors.New("--keep-going and --fail-fast are mutually exclusive")
+	} else if in.keepGoing {
+		overrides = append(overrides, "*.keep-going=true")
+	} else if in.failFast {
+		overrides = append(overrides, "*.keep-going=false")
+	}
 	if cFlags.noCache != nil {
 		overrides = append(overrides, fmt.Sprintf("*.no-cache=%t", *cFlags.noCache))
 	}
@@ -149,19 +171,62 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 	defer cancel(errors.WithStack(context.Canceled))
 
 	var nodes []builder.Node
+	var builderName string
 	var progressConsoleDesc, progressTextDesc string
 
 	if in.print && in.list != "" {
 		return errors.New("--print and --list are mutually exclusive")
 	}
+	if in.printOutputs != "" && (in.print || in.list != "") {
+		return errors.New("--print-outputs cannot be used with --print or --list")
+	}
+	if in.printOutputs != "" && in.printOutputs != "json" {
+		return errors.Errorf("invalid outputs format %q", in.printOutputs)
+	}
+	if in.graph != "" && !in.print {
+		return errors.New("--graph requires --print")
+	}
+	if in.changedOnly && url != "" {
+		return errors.New("--changed-only is not supported with remote bake definitions")
+	}
+	if in.lock && in.locked {
+		return errors.New("--lock and --locked are mutually exclusive")
+	}
+	if in.lock && (in.print || in.list != "" || in.printOutputs != "") {
+		return errors.New("--lock cannot be used with --print, --list or --print-outputs")
+	}
 
-	// instance only needed for reading remote bake files or building
-	var driverType string
-	if url != "" || (!in.print && in.list == "") {
-		b, err := builder.New(dockerCli,
+	// the lock file of a remote definition is in the working directory
+	lockFile := bake.LockFilename
+	var lock *bake.Lock
+	if in.locked && url != "" {
+		lock, err = bake.ReadLock(lockFile)
+		if err != nil {
+			return err
+		}
+		url, err = lock.PinDefinition(url)
+		if err != nil {
+			return err
+		}
+	}
+
+	var b *builder.Builder
+	newBuilder := func() (*builder.Builder, error) {
+		if b != nil {
+			return b, nil
+		}
+		var err error
+		b, err = builder.New(dockerCli,
 			builder.WithName(in.builder),
 			builder.WithContextPathHash(contextPathHash),
 		)
+		return b, err
+	}
+
+	// instance only needed for reading remote bake files or building
+	var driverType string
+	if url != "" || in.lock || (!in.print && in.list == "" && in.printOutputs == "") {
+		b, err := newBuilder()
 		if err != nil {
 			return err
 		}
@@ -172,6 +237,7 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		if err != nil {
 			return err
 		}
+		builderName = b.Name
 		progressConsoleDesc = fmt.Sprintf("%s:%s", b.Driver, b.Name)
 		progressTextDesc = fmt.Sprintf("building with %q instance using %s driver", b.Name, b.Driver)
 		driverType = b.Driver
@@ -208,7 +274,7 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		return err
 	}
 
-	files, inp, err := readBakeFiles(ctx, nodes, url, in.files, dockerCli.In(), printer, filesFromEnv)
+	files, inp, err := readBakeFiles(ctx, nodes, url, in.files, dockerCli.In(), printer, filesFromEnv, in.lock)
 	if err != nil {
 		return err
 	}
@@ -217,6 +283,48 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		return errors.New("couldn't find a bake definition")
 	}
 
+	if url == "" {
+		lockFile = bake.LockPath(files)
+	}
+	if lock == nil && (url == "" || in.lock || in.locked) {
+		// pinned imports of a local definition are used even without
+		// --locked, but the lock file is only written with --lock
+		lock, err = bake.ReadLock(lockFile)
+		if err != nil {
+			return err
+		}
+	}
+	files, err = bake.ReadImports(ctx, files, bake.ImportOpt{
+		Nodes: func(ctx context.Context) ([]builder.Node, error) {
+			if nodes != nil {
+				return nodes, nil
+			}
+			b, err := newBuilder()
+			if err != nil {
+				return nil, err
+			}
+			return b.LoadNodes(ctx)
+		},
+		Registry: func() (*imagetools.Resolver, error) {
+			b, err := newBuilder()
+			if err != nil {
+				return nil, err
+			}
+			imageopt, err := b.ImageOpt()
+			if err != nil {
+				return nil, err
+			}
+			return imagetools.New(imageopt), nil
+		},
+		Lock:     lock,
+		Update:   in.lock,
+		Locked:   in.locked,
+		Progress: printer,
+	})
+	if err != nil {
+		return err
+	}
+
 	defaults := map[string]string{
 		// don't forget to update documentation if you add a new
 		// built-in variable: docs/bake-reference.md#built-in-variables
@@ -252,7 +360,20 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 			return printTargetList(dockerCli.Out(), list.Format, cfg)
 		case "variables":
 			return printVars(dockerCli.Out(), list.Format, pm.AllVariables)
+		case "outputs":
+			return printOutputs(dockerCli.Out(), list.Format, pm.AllOutputs)
+		}
+	}
+
+	if in.printOutputs != "" {
+		_, pm, err := bake.ParseFiles(files, defaults, vars, parseOpt)
+		if err != nil {
+			return err
 		}
+		if err = printer.Wait(); err != nil {
+			return err
+		}
+		return printOutputValues(dockerCli.Out(), pm.AllOutputs)
 	}
 
 	tgts, grps, err := bake.ReadTargets(ctx, files, targets, overrides, defaults, vars, &ent, parseOpt)
@@ -299,6 +420,19 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		bo[k] = opt
 	}
 
+	if in.lock {
+		return writeBakeLock(ctx, newBuilder, printer, lock, lockFile, inp, bo)
+	}
+	if in.locked {
+		if err := lock.CheckImages(bo); err != nil {
+			return err
+		}
+		for k, opt := range bo {
+			opt.SourcePolicy = lock.SourcePolicy(opt.SourcePolicy)
+			bo[k] = opt
+		}
+	}
+
 	def := struct {
 		Group  map[string]*bake.Group  `json:"group,omitempty"`
 		Target map[string]*bake.Target `json:"target"`
@@ -311,6 +445,13 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		if err = printer.Wait(); err != nil {
 			return err
 		}
+		if in.graph != "" {
+			cfg, pm, err := bake.ParseFiles(files, defaults, vars, parseOpt)
+			if err != nil {
+				return err
+			}
+			return bake.NewGraph(cfg, pm, tgts, grps).Write(dockerCli.Out(), in.graph)
+		}
 		dtdef, err := json.MarshalIndent(def, "", "  ")
 		if err != nil {
 			return err
@@ -334,6 +475,14 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		}
 	}
 
+	var upToDate *upToDateTargets
+	if in.changedOnly {
+		upToDate, err = skipUpToDateTargets(ctx, dockerCli, builderName, contextPathHash, tgts, bo)
+		if err != nil {
+			return err
+		}
+	}
+
 	exp, err := ent.Validate(bo)
 	if err != nil {
 		return err
@@ -354,12 +503,20 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		}
 	}
 
+	if upToDate != nil {
+		upToDate.log(printer)
+	}
+
 	if err := saveLocalStateGroup(dockerCli, in, targets, bo); err != nil {
 		return err
 	}
 
 	done := timeBuildCommand(mp, attributes)
-	resp, retErr := build.Build(ctx, nodes, bo, dockerutil.NewClient(dockerCli), confutil.NewConfig(dockerCli), printer)
+	var resp map[string]*client.SolveResponse
+	var retErr error
+	if len(bo) > 0 {
+		resp, retErr = build.Build(ctx, nodes, bo, dockerutil.NewClient(dockerCli), confutil.NewConfig(dockerCli), printer)
+	}
 	if err := printer.Wait(); retErr == nil {
 		retErr = err
 	}
@@ -368,10 +525,35 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 	}
 	done(err)
 
-	if err != nil {
+	var failures map[string]error
+	var targetsErr *build.TargetsError
+	if errors.As(err, &targetsErr) {
+		// targets built with keep-going failed, report the ones that
+		// succeeded before returning
+		failures = targetsErr.Errors
+		if progressMode != progressui.QuietMode && progressMode != progressui.RawJSONMode {
+			printBakeSummary(dockerCli.Err(), bo, failures)
+		}
+		var succeeded bool
+		for name := range resp {
+			if !bo[name].Linked {
+				succeeded = true
+				break
+			}
+		}
+		if !succeeded {
+			return err
+		}
+	} else if err != nil {
 		return err
 	}
 
+	if upToDate != nil {
+		if err := upToDate.save(bo, resp); err != nil {
+			return err
+		}
+	}
+
 	if progressMode != progressui.QuietMode && progressMode != progressui.RawJSONMode {
 		desktop.PrintBuildDetails(os.Stderr, printer.BuildRefs(), term)
 	}
@@ -380,6 +562,16 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		for t, r := range resp {
 			dt[t] = decodeExporterResponse(r.ExporterResponse)
 		}
+		if upToDate != nil {
+			for t, st := range upToDate.skipped {
+				dt[t] = decodeExporterResponse(st.ExporterResponse)
+			}
+		}
+		for t, err := range failures {
+			dt[t] = map[string]any{
+				"buildx.build.error": err.Error(),
+			}
+		}
 		if callFunc == nil {
 			if warnings := printer.Warnings(); len(warnings) > 0 && confutil.MetadataWarningsEnabled() {
 				dt["buildx.build.warnings"] = warnings
@@ -409,6 +601,9 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		if req.CallFunc == nil {
 			continue
 		}
+		if _, ok := failures[name]; ok {
+			continue
+		}
 
 		pf := &buildflags.CallFunc{
 			Name:         req.CallFunc.Name,
@@ -499,6 +694,9 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		}
 	}
 
+	if len(failures) > 0 {
+		return cobrautil.ExitCodeError(bakeExitCodePartialFailure)
+	}
 	if exitCode != 0 {
 		return cobrautil.ExitCodeError(exitCode)
 	}
@@ -506,6 +704,24 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 	return nil
 }
 
+func printBakeSummary(w io.Writer, bo map[string]build.Options, failures map[string]error) {
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "TARGET\tSTATUS")
+	for _, name := range slices.Sorted(maps.Keys(bo)) {
+		if bo[name].Linked {
+			continue
+		}
+		if err, ok := failures[name]; ok {
+			msg, _, _ := strings.Cut(err.Error(), "\n")
+			fmt.Fprintf(tw, "%s\tfailed: %s\n", name, msg)
+		} else {
+			fmt.Fprintf(tw, "%s\tdone\n", name)
+		}
+	}
+}
+
 func bakeCmd(dockerCli command.Cli, rootOpts *rootOptions) *cobra.Command {
 	var options bakeOptions
 	var cFlags commonFlags
@@ -559,12 +775,19 @@ func bakeCmd(dockerCli command.Cli, rootOpts *rootOptions) *cobra.Command {
 	flags.StringArrayVar(&options.vars, "var", nil, `Set a variable value (e.g., "name=value")`)
 	flags.StringVar(&options.callFunc, "call", "build", `Set method for evaluating build ("check", "outline", "targets")`)
 	flags.StringArrayVar(&options.allow, "allow", nil, "Allow build to access specified resources")
+	flags.BoolVar(&options.keepGoing, "keep-going", false, `Keep building other targets when a target fails. Shorthand for "--set=*.keep-going=true"`)
+	flags.BoolVar(&options.failFast, "fail-fast", false, `Cancel all targets when a target fails. Shorthand for "--set=*.keep-going=false"`)
 
 	flags.VarPF(callAlias(&options.callFunc, "check"), "check", "", `Shorthand for "--call=check"`)
 	flags.Lookup("check").NoOptDefVal = "true"
 
 	flags.BoolVar(&options.print, "print", false, "Print the options without building")
-	flags.StringVar(&options.list, "list", "", "List targets or variables")
+	flags.StringVar(&options.graph, "graph", "", `Print the resolved target graph with "--print" ("dot", "mermaid", "json")`)
+	flags.StringVar(&options.list, "list", "", "List targets, variables or outputs")
+	flags.StringVar(&options.printOutputs, "print-outputs", "", `Print the values of the outputs without building ("json")`)
+	flags.BoolVar(&options.changedOnly, "changed-only", false, "Skip targets whose inputs did not change since the last successful build")
+	flags.BoolVar(&options.lock, "lock", false, "Write the lock file pinning remote definitions and images without building")
+	flags.BoolVar(&options.locked, "locked", false, "Build with the sources pinned in the lock file")
 
 	// TODO: remove deprecated flags
 	flags.BoolVar(&options.listTargets, "list-targets", false, "List available targets")
@@ -576,9 +799,25 @@ func bakeCmd(dockerCli command.Cli, rootOpts *rootOptions) *cobra.Command {
 
 	commonBuildFlags(&cFlags, flags)
 
+	cmd.AddCommand(
+		bakeFmtCmd(dockerCli),
+		bakeLintCmd(dockerCli, rootOpts),
+	)
+
 	return cmd
 }
 
+// warnShadowedTarget warns that a target or group of the definition named
+// like a bake subcommand is not built, as the subcommand takes precedence
+// over the targets. Arguments after "--" are never read as a subcommand.
+func warnShadowedTarget(w io.Writer, files []bake.File, name string) {
+	targets, err := bake.ListTargets(files)
+	if err != nil || !slices.Contains(targets, name) {
+		return
+	}
+	fmt.Fprintf(w, "Running the %q command instead of building the target %q of the definition, use \"docker buildx bake -- %s\" to build it\n", "bake "+name, name, name)
+}
+
 func bakePolicyOverrides(in []string) ([]string, bool, error) {
 	configs, err := buildflags.ParsePolicyConfigs(in)
 	if err != nil {
@@ -668,6 +907,121 @@ func saveLocalStateGroup(dockerCli command.Cli, in bakeOptions, targets []string
 	})
 }
 
+type upToDateTargets struct {
+	ls           *localstate.LocalState
+	builderName  string
+	key          string
+	fingerprints map[string]digest.Digest
+	skipped      map[string]*localstate.TargetState
+}
+
+// skipUpToDateTargets removes from bo the targets whose fingerprint matches
+// the one recorded for their last successful build. Targets still required
+// as linked context by a target that needs to be built are kept.
+func skipUpToDateTargets(ctx context.Context, dockerCli command.Cli, builderName, wd string, tgts map[string]*bake.Target, bo map[string]build.Options) (*upToDateTargets, error) {
+	l, err := localstate.New(confutil.NewConfig(dockerCli))
+	if err != nil {
+		return nil, err
+	}
+	fingerprints, err := bake.Fingerprints(ctx, tgts)
+	if err != nil {
+		return nil, err
+	}
+	u := &upToDateTargets{
+		ls:           l,
+		builderName:  builderName,
+		key:          digest.FromString(wd).Encoded(),
+		fingerprints: fingerprints,
+		skipped:      map[string]*localstate.TargetState{},
+	}
+
+	for name, opt := range bo {
+		if opt.Linked || opt.CallFunc != nil || fingerprints[name] == "" {
+			continue
+		}
+		st, err := l.ReadTarget(u.builderName, u.key, name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if st.Fingerprint == fingerprints[name].String() {
+			u.skipped[name] = st
+		}
+	}
+
+	required := map[string]struct{}{}
+	var walk func(name string)
+	walk = func(name string) {
+		if _, ok := required[name]; ok {
+			return
+		}
+		required[name] = struct{}{}
+		for _, nc := range bo[name].Inputs.NamedContexts {
+			if target, ok := strings.CutPrefix(nc.Path, "target:"); ok {
+				walk(target)
+			}
+		}
+	}
+	for name, opt := range bo {
+		if _, ok := u.skipped[name]; !ok && !opt.Linked {
+			walk(name)
+		}
+	}
+	for name, opt := range bo {
+		if _, ok := required[name]; ok {
+			delete(u.skipped, name)
+			continue
+		}
+		if _, ok := u.skipped[name]; ok || opt.Linked {
+			delete(bo, name)
+		}
+	}
+	// skipped targets are up to date so they satisfy the dependencies of the
+	// ones that still need to be built
+	for name, opt := range bo {
+		if len(opt.DependsOn) == 0 {
+			continue
+		}
+		opt.DependsOn = slices.DeleteFunc(slices.Clone(opt.DependsOn), func(dep string) bool {
+			_, ok := bo[dep]
+			return !ok
+		})
+		bo[name] = opt
+	}
+	return u, nil
+}
+
+func (u *upToDateTargets) log(pw progress.Writer) {
+	for _, name := range slices.Sorted(maps.Keys(u.skipped)) {
+		progress.Wrap(fmt.Sprintf("[%s] up to date", name), pw.Write, func(progress.SubLogger) error {
+			return nil
+		})
+	}
+}
+
+// save records the fingerprints of the targets that have been built
+// successfully so they can be skipped by subsequent runs.
+func (u *upToDateTargets) save(bo map[string]build.Options, resp map[string]*client.SolveResponse) error {
+	for name, opt := range bo {
+		if opt.Linked || opt.CallFunc != nil || u.fingerprints[name] == "" {
+			continue
+		}
+		r, ok := resp[name]
+		if !ok {
+			continue
+		}
+		if err := u.ls.SaveTarget(u.builderName, u.key, name, localstate.TargetState{
+			Fingerprint:      u.fingerprints[name].String(),
+			ExporterResponse: r.ExporterResponse,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // bakeArgs will retrieve the remote url, command context, and targets
 // from the command line arguments.
 func bakeArgs(args []string) (url, cmdContext string, targets []string) {
@@ -695,7 +1049,7 @@ func bakeFileRelativePaths() (bool, error) {
 	return enabled, nil
 }
 
-func readBakeFiles(ctx context.Context, nodes []builder.Node, url string, names []string, stdin io.Reader, pw progress.Writer, filesFromEnv bool) (files []bake.File, inp *bake.Input, err error) {
+func readBakeFiles(ctx context.Context, nodes []builder.Node, url string, names []string, stdin io.Reader, pw progress.Writer, filesFromEnv, resolveCommit bool) (files []bake.File, inp *bake.Input, err error) {
 	var lnames []string // local
 	var rnames []string // remote
 	var anames []string // both
@@ -711,7 +1065,11 @@ func readBakeFiles(ctx context.Context, nodes []builder.Node, url string, names
 
 	if url != "" {
 		var rfiles []bake.File
-		rfiles, inp, err = bake.ReadRemoteFiles(ctx, nodes, url, rnames, pw)
+		if resolveCommit {
+			rfiles, inp, err = bake.ReadRemoteFilesWithCommit(ctx, nodes, url, rnames, pw)
+		} else {
+			rfiles, inp, err = bake.ReadRemoteFiles(ctx, nodes, url, rnames, pw)
+		}
 		if err != nil {
 			return nil, nil, err
 		}
@@ -741,6 +1099,36 @@ func readBakeFiles(ctx context.Context, nodes []builder.Node, url string, names
 	return
 }
 
+// writeBakeLock pins the remote definition and the images used by the build
+// options and writes the lock file. Imports have already been pinned in lock
+// while reading the files.
+func writeBakeLock(ctx context.Context, newBuilder func() (*builder.Builder, error), printer *progress.Printer, lock *bake.Lock, lockFile string, inp *bake.Input, bo map[string]build.Options) error {
+	b, err := newBuilder()
+	if err != nil {
+		return err
+	}
+	imageopt, err := b.ImageOpt()
+	if err != nil {
+		return err
+	}
+	if err := lock.LockDefinition(inp); err != nil {
+		return err
+	}
+	err = progress.Wrap("[internal] resolve images to lock", printer.Write, func(progress.SubLogger) error {
+		return lock.LockImages(ctx, bo, imagetools.New(imageopt))
+	})
+	if err != nil {
+		return err
+	}
+	if err := printer.Wait(); err != nil {
+		return err
+	}
+	if err := lock.Save(lockFile); err != nil {
+		return errors.Wrap(err, "failed to write lock file")
+	}
+	return nil
+}
+
 type listEntry struct {
 	Type   string
 	Format string
@@ -780,7 +1168,7 @@ func parseList(input string) (listEntry, error) {
 	}
 
 	switch res.Type {
-	case "targets", "variables":
+	case "targets", "variables", "outputs":
 	default:
 		return res, errors.Errorf("invalid list type %q", res.Type)
 	}
@@ -843,6 +1231,46 @@ func printVars(w io.Writer, format string, vars []*hclparser.Variable) error {
 	return nil
 }
 
+func printOutputs(w io.Writer, format string, outputs []*hclparser.Output) error {
+	slices.SortFunc(outputs, func(a, b *hclparser.Output) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(outputs)
+	}
+
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	defer tw.Flush()
+
+	tw.Write([]byte("OUTPUT\tTYPE\tVALUE\tDESCRIPTION\n"))
+
+	for _, o := range outputs {
+		value := string(o.Value)
+		// strings are printed unquoted, like for variables
+		var s string
+		if err := json.Unmarshal(o.Value, &s); err == nil {
+			value = s
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", o.Name, o.Type, value, o.Description)
+	}
+	return nil
+}
+
+// printOutputValues prints the outputs as a single JSON object keyed by
+// output name so values can be consumed directly, e.g. with jq.
+func printOutputValues(w io.Writer, outputs []*hclparser.Output) error {
+	values := make(map[string]json.RawMessage, len(outputs))
+	for _, o := range outputs {
+		values[o.Name] = o.Value
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(values)
+}
+
 func printTargetList(w io.Writer, format string, cfg *bake.Config) error {
 	type targetOrGroup struct {
 		name   string
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/buildx/bake"
+	"github.com/docker/buildx/util/cobrautil"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/textdiff"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type bakeFmtOptions struct {
+	files []string
+	check bool
+	diff  bool
+}
+
+func runBakeFmt(dockerCli command.Cli, in bakeFmtOptions) error {
+	files, err := bake.ReadLocalFiles(in.files, dockerCli.In(), nil)
+	if err != nil {
+		return err
+	}
+	if len(in.files) == 0 {
+		// only the HCL files of the default definition can be formatted
+		hclFiles := files[:0]
+		for _, f := range files {
+			if filepath.Ext(f.Name) == ".hcl" {
+				hclFiles = append(hclFiles, f)
+			}
+		}
+		files = hclFiles
+	}
+	if len(files) == 0 {
+		return errors.New("couldn't find a bake definition")
+	}
+	warnShadowedTarget(dockerCli.Err(), files, "fmt")
+
+	var unformatted bool
+	for _, f := range files {
+		dt, err := bake.FormatFile(f)
+		if err != nil {
+			return err
+		}
+		if f.Name == "-" && !in.check && !in.diff {
+			fmt.Fprint(dockerCli.Out(), string(dt))
+			continue
+		}
+		if bytes.Equal(dt, f.Data) {
+			continue
+		}
+		unformatted = true
+		switch {
+		case in.diff:
+			fmt.Fprint(dockerCli.Out(), textdiff.Unified(f.Name+".orig", f.Name, string(f.Data), string(dt)))
+		case in.check:
+			fmt.Fprintln(dockerCli.Out(), f.Name)
+		default:
+			fi, err := os.Stat(f.Name)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(f.Name, dt, fi.Mode().Perm()); err != nil {
+				return err
+			}
+			fmt.Fprintln(dockerCli.Out(), f.Name)
+		}
+	}
+	if unformatted && in.check {
+		return cobrautil.ExitCodeError(1)
+	}
+	return nil
+}
+
+func bakeFmtCmd(dockerCli command.Cli) *cobra.Command {
+	var options bakeFmtOptions
+
+	cmd := &cobra.Command{
+		Use:   "fmt [OPTIONS]",
+		Short: "Format bake definition files",
+		Args:  cli.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBakeFmt(dockerCli, options)
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVarP(&options.files, "file", "f", []string{}, "Bake definition file to format")
+	flags.BoolVar(&options.check, "check", false, "Check that the files are formatted and list the ones that are not, without writing them")
+	flags.BoolVar(&options.diff, "diff", false, "Print the formatting changes as a diff, without writing the files")
+
+	return cmd
+}
@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/containerd/platforms"
+	"github.com/docker/buildx/bake"
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/cobrautil"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type bakeLintOptions struct {
+	files   []string
+	vars    []string
+	format  string
+	builder string
+}
+
+func runBakeLint(ctx context.Context, dockerCli command.Cli, in bakeLintOptions) error {
+	if in.format != "" && in.format != "json" {
+		return errors.Errorf("unsupported format %q", in.format)
+	}
+
+	files, err := bake.ReadLocalFiles(in.files, dockerCli.In(), nil)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("couldn't find a bake definition")
+	}
+
+	var b *builder.Builder
+	newBuilder := func() (*builder.Builder, error) {
+		if b != nil {
+			return b, nil
+		}
+		var err error
+		b, err = builder.New(dockerCli, builder.WithName(in.builder))
+		return b, err
+	}
+	// imports are fetched from their source, the lock file is left untouched
+	files, err = bake.ReadImports(ctx, files, bake.ImportOpt{
+		Nodes: func(ctx context.Context) ([]builder.Node, error) {
+			b, err := newBuilder()
+			if err != nil {
+				return nil, err
+			}
+			return b.LoadNodes(ctx)
+		},
+		Registry: func() (*imagetools.Resolver, error) {
+			b, err := newBuilder()
+			if err != nil {
+				return nil, err
+			}
+			imageopt, err := b.ImageOpt()
+			if err != nil {
+				return nil, err
+			}
+			return imagetools.New(imageopt), nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	warnShadowedTarget(dockerCli.Err(), files, "lint")
+
+	defaults := map[string]string{
+		"BAKE_CMD_CONTEXT":    "cwd://",
+		"BAKE_LOCAL_PLATFORM": platforms.Format(platforms.DefaultSpec()),
+	}
+	vars, err := parseBakeVars(in.vars)
+	if err != nil {
+		return err
+	}
+	issues, err := bake.Lint(files, defaults, vars)
+	if err != nil {
+		return err
+	}
+
+	if in.format == "json" {
+		if issues == nil {
+			issues = []bake.LintIssue{}
+		}
+		dt, err := json.MarshalIndent(issues, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(dockerCli.Out(), string(dt))
+	} else {
+		for _, issue := range issues {
+			fmt.Fprintln(dockerCli.Out(), issue.String())
+		}
+	}
+	if len(issues) > 0 {
+		return cobrautil.ExitCodeError(1)
+	}
+	return nil
+}
+
+func bakeLintCmd(dockerCli command.Cli, rootOpts *rootOptions) *cobra.Command {
+	var options bakeLintOptions
+
+	cmd := &cobra.Command{
+		Use:   "lint [OPTIONS]",
+		Short: "Check bake definition files for common mistakes",
+		Args:  cli.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = rootOpts.builder
+			return runBakeLint(cmd.Context(), dockerCli, options)
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVarP(&options.files, "file", "f", []string{}, "Build definition file")
+	flags.StringArrayVar(&options.vars, "var", nil, `Set a variable value (e.g., "name=value")`)
+	flags.StringVar(&options.format, "format", "", `Format the output ("json")`)
+
+	return cmd
+}
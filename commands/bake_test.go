@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/docker/buildx/bake"
+	"github.com/docker/cli/cli/command"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBakeSubcommandShadowsTarget(t *testing.T) {
+	dockerCli, err := command.NewDockerCli()
+	require.NoError(t, err)
+	rootCmd := NewRootCmd("buildx", true, dockerCli)
+
+	for _, tc := range []struct {
+		args     []string
+		expected string
+	}{
+		{args: []string{"bake", "lint"}, expected: "buildx bake lint"},
+		{args: []string{"bake", "--file", "docker-bake.hcl", "fmt", "--check"}, expected: "buildx bake fmt"},
+		{args: []string{"bake", "--", "lint"}, expected: "buildx bake"},
+		{args: []string{"bake", "app"}, expected: "buildx bake"},
+	} {
+		cmd, _, err := rootCmd.Find(tc.args)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, cmd.CommandPath())
+	}
+}
+
+func TestWarnShadowedTarget(t *testing.T) {
+	files := []bake.File{{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+group "default" {
+  targets = ["app"]
+}
+target "app" {}
+target "lint" {}
+`),
+	}}
+
+	var buf bytes.Buffer
+	warnShadowedTarget(&buf, files, "lint")
+	require.Equal(t, `Running the "bake lint" command instead of building the target "lint" of the definition, use "docker buildx bake -- lint" to build it`+"\n", buf.String())
+
+	buf.Reset()
+	warnShadowedTarget(&buf, files, "fmt")
+	require.Empty(t, buf.String())
+}
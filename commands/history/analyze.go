@@ -0,0 +1,471 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/containerd/platforms"
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/localstate"
+	historyutil "github.com/docker/buildx/util/history"
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+type analysisOutput struct {
+	// Compare is the ref of the previous record of the same build that the
+	// cache misses are compared to.
+	Compare string `json:",omitempty"`
+
+	CriticalPath         []criticalStepOutput
+	CriticalPathDuration time.Duration
+
+	Stages []stageOutput
+
+	CacheMisses []cacheMissOutput `json:",omitempty"`
+}
+
+type criticalStepOutput struct {
+	Name     string
+	Duration time.Duration
+	Cached   bool `json:",omitempty"`
+}
+
+type stageOutput struct {
+	Name string
+	// Duration is the wall time of the stage, steps running in parallel are
+	// only counted once.
+	Duration    time.Duration
+	Steps       int
+	CachedSteps int
+}
+
+type cacheMissOutput struct {
+	Name    string
+	Reasons []string
+	// Dependents is the number of steps that were not cached because of
+	// this step.
+	Dependents int `json:",omitempty"`
+}
+
+// analyzeRecord computes the critical path and stage timings of the build, and
+// explains the cache misses compared to the previous record of the same build.
+func analyzeRecord(ctx context.Context, ls *localstate.LocalState, rec *historyRecord, nodes []builder.Node) (*analysisOutput, error) {
+	c, err := rec.node.Driver.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vertexes, err := loadVertexes(ctx, c, rec.Ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load build steps")
+	}
+
+	out := &analysisOutput{}
+	out.CriticalPath, out.CriticalPathDuration = criticalPath(vertexes)
+	out.Stages = stageTimings(vertexes)
+
+	var cmp *cacheMissComparison
+	prev, err := previousRecord(ctx, ls, rec, nodes)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil {
+		cmp, err = loadCacheMissComparison(ctx, ls, rec, prev)
+		if err != nil {
+			return nil, err
+		}
+		out.Compare = prev.Ref
+	}
+	_, noCache := rec.FrontendAttrs["no-cache"]
+	out.CacheMisses = cacheMisses(vertexes, cmp, noCache)
+	return out, nil
+}
+
+// previousRecord returns the most recent completed record of the same build
+// created before rec, or nil if there is none.
+func previousRecord(ctx context.Context, ls *localstate.LocalState, rec *historyRecord, nodes []builder.Node) (*historyRecord, error) {
+	recs, err := queryRecords(ctx, "", nodes, &queryOptions{
+		CompletedOnly: true,
+		Limit:         -1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	st, _ := ls.ReadRef(rec.node.Builder, rec.node.Name, rec.Ref)
+	name := historyutil.BuildName(rec.FrontendAttrs, st)
+	created := rec.CreatedAt.AsTime()
+	for _, r := range recs {
+		if r.Ref == rec.Ref || !r.CreatedAt.AsTime().Before(created) {
+			continue
+		}
+		if r.FrontendAttrs["target"] != rec.FrontendAttrs["target"] {
+			continue
+		}
+		st, _ := ls.ReadRef(r.node.Builder, r.node.Name, r.Ref)
+		if historyutil.BuildName(r.FrontendAttrs, st) != name {
+			continue
+		}
+		return &r, nil
+	}
+	return nil, nil
+}
+
+// cacheMissComparison is the state of the previous record used to explain
+// the cache misses.
+type cacheMissComparison struct {
+	steps     map[string]struct{}
+	buildArgs []valueDiffOutput
+	materials []valueDiffOutput
+}
+
+func loadCacheMissComparison(ctx context.Context, ls *localstate.LocalState, rec, prev *historyRecord) (*cacheMissComparison, error) {
+	cur, err := loadDiffRecord(ctx, ls, rec)
+	if err != nil {
+		return nil, err
+	}
+	base, err := loadDiffRecord(ctx, ls, prev)
+	if err != nil {
+		return nil, err
+	}
+	c, err := prev.node.Driver.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vertexes, err := loadVertexes(ctx, c, prev.Ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load build steps of %s", prev.Ref)
+	}
+	steps := make(map[string]struct{}, len(vertexes))
+	for _, v := range vertexes {
+		steps[v.Name] = struct{}{}
+	}
+	return &cacheMissComparison{
+		steps:     steps,
+		buildArgs: diffValues(base.buildArgs, cur.buildArgs),
+		materials: diffValues(base.materials, cur.materials),
+	}, nil
+}
+
+// criticalPath returns the chain of steps that determined the duration of
+// the build. It starts from the step that completed last and follows the
+// input that completed last at each step.
+func criticalPath(vertexes []*client.Vertex) ([]criticalStepOutput, time.Duration) {
+	byDigest := make(map[digest.Digest]*client.Vertex, len(vertexes))
+	var last *client.Vertex
+	for _, v := range vertexes {
+		if v.Started == nil || v.Completed == nil {
+			continue
+		}
+		byDigest[v.Digest] = v
+		if last == nil || v.Completed.After(*last.Completed) {
+			last = v
+		}
+	}
+
+	var path []criticalStepOutput
+	var total time.Duration
+	visited := map[digest.Digest]struct{}{}
+	for v := last; v != nil; {
+		visited[v.Digest] = struct{}{}
+		d := v.Completed.Sub(*v.Started)
+		path = append(path, criticalStepOutput{
+			Name:     v.Name,
+			Duration: d,
+			Cached:   v.Cached,
+		})
+		total += d
+
+		var next *client.Vertex
+		for _, dgst := range v.Inputs {
+			in, ok := byDigest[dgst]
+			if !ok {
+				continue
+			}
+			if _, ok := visited[dgst]; ok {
+				continue
+			}
+			if next == nil || in.Completed.After(*next.Completed) {
+				next = in
+			}
+		}
+		v = next
+	}
+	slices.Reverse(path)
+	return path, total
+}
+
+// stageTimings returns the wall time of each stage of the build.
+func stageTimings(vertexes []*client.Vertex) []stageOutput {
+	type interval struct {
+		start, end time.Time
+	}
+	var order []string
+	stages := map[string]*stageOutput{}
+	intervals := map[string][]interval{}
+	for _, v := range vertexes {
+		if v.Started == nil || v.Completed == nil {
+			continue
+		}
+		name := vertexStage(v.Name)
+		st, ok := stages[name]
+		if !ok {
+			st = &stageOutput{Name: name}
+			stages[name] = st
+			order = append(order, name)
+		}
+		st.Steps++
+		if v.Cached {
+			st.CachedSteps++
+		}
+		intervals[name] = append(intervals[name], interval{*v.Started, *v.Completed})
+	}
+
+	out := make([]stageOutput, 0, len(order))
+	for _, name := range order {
+		st := stages[name]
+		ivs := intervals[name]
+		slices.SortFunc(ivs, func(a, b interval) int {
+			return a.start.Compare(b.start)
+		})
+		var end time.Time
+		for _, iv := range ivs {
+			if iv.start.After(end) {
+				st.Duration += iv.end.Sub(iv.start)
+				end = iv.end
+			} else if iv.end.After(end) {
+				st.Duration += iv.end.Sub(end)
+				end = iv.end
+			}
+		}
+		out = append(out, *st)
+	}
+	return out
+}
+
+// vertexStage returns the stage of a step from its name, e.g. "build" for
+// "[linux/amd64 build 3/5] RUN make". Steps without a stage return "-".
+func vertexStage(name string) string {
+	if !strings.HasPrefix(name, "[") {
+		return statsNone
+	}
+	prefix, _, ok := strings.Cut(name[1:], "]")
+	if !ok {
+		return statsNone
+	}
+	fields := strings.Fields(prefix)
+	if len(fields) > 0 && isStepIndex(fields[len(fields)-1]) {
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) > 1 {
+		if _, err := platforms.Parse(fields[0]); err == nil && strings.Contains(fields[0], "/") {
+			fields = fields[1:]
+		}
+	}
+	if len(fields) == 0 {
+		return statsNone
+	}
+	return strings.Join(fields, " ")
+}
+
+// isStepIndex returns true for the "3/5" step index of a Dockerfile step.
+func isStepIndex(s string) bool {
+	a, b, ok := strings.Cut(s, "/")
+	return ok && a != "" && b != "" && strings.Trim(a+b, "0123456789") == ""
+}
+
+// isSourceStep returns true for the steps that load sources and metadata.
+// They run on every build, and are not reported as cache misses.
+func isSourceStep(name string) bool {
+	return strings.HasPrefix(name, "[internal] ") || strings.HasPrefix(name, "[context ")
+}
+
+// isContextStep returns true for the steps that transfer a local context.
+func isContextStep(name string) bool {
+	return strings.HasPrefix(name, "[internal] load build context") || (strings.HasPrefix(name, "[context ") && strings.HasSuffix(name, "load from client"))
+}
+
+// isFromStep returns true for the steps that resolve a base image.
+func isFromStep(name string) bool {
+	_, cmd, ok := strings.Cut(name, "] ")
+	return ok && strings.HasPrefix(cmd, "FROM ")
+}
+
+// cacheMisses returns the steps that were not cached and whose inputs were
+// all cached, with the possible reasons of the miss. The steps that missed
+// the cache because one of their inputs did are counted as dependents.
+func cacheMisses(vertexes []*client.Vertex, cmp *cacheMissComparison, noCache bool) []cacheMissOutput {
+	byDigest := make(map[digest.Digest]*client.Vertex, len(vertexes))
+	for _, v := range vertexes {
+		byDigest[v.Digest] = v
+	}
+
+	var baseImages []valueDiffOutput
+	if cmp != nil {
+		for _, m := range cmp.materials {
+			if strings.HasPrefix(m.Name, "pkg:docker/") && m.Base != nil && m.Compare != nil {
+				baseImages = append(baseImages, m)
+			}
+		}
+	}
+
+	missed := func(v *client.Vertex) bool {
+		if v.Cached || v.Started == nil || v.Completed == nil || v.Error != "" || isSourceStep(v.Name) {
+			return false
+		}
+		if isFromStep(v.Name) {
+			// base images are resolved on every build
+			return len(baseImages) > 0
+		}
+		return true
+	}
+
+	// roots maps each missed step to the root misses it depends on
+	roots := map[digest.Digest][]digest.Digest{}
+	var rootOrder []digest.Digest
+	var resolve func(v *client.Vertex) []digest.Digest
+	resolve = func(v *client.Vertex) []digest.Digest {
+		if r, ok := roots[v.Digest]; ok {
+			return r
+		}
+		roots[v.Digest] = nil // guard against cycles
+		var r []digest.Digest
+		for _, dgst := range v.Inputs {
+			in, ok := byDigest[dgst]
+			if !ok || !missed(in) {
+				continue
+			}
+			for _, dgst := range resolve(in) {
+				if !slices.Contains(r, dgst) {
+					r = append(r, dgst)
+				}
+			}
+		}
+		if len(r) == 0 {
+			r = []digest.Digest{v.Digest}
+			rootOrder = append(rootOrder, v.Digest)
+		}
+		roots[v.Digest] = r
+		return r
+	}
+
+	dependents := map[digest.Digest]int{}
+	for _, v := range vertexes {
+		if !missed(v) {
+			continue
+		}
+		for _, r := range resolve(v) {
+			if r != v.Digest {
+				dependents[r]++
+			}
+		}
+	}
+
+	out := make([]cacheMissOutput, 0, len(rootOrder))
+	for _, dgst := range rootOrder {
+		v := byDigest[dgst]
+		out = append(out, cacheMissOutput{
+			Name:       v.Name,
+			Reasons:    cacheMissReasons(v, byDigest, cmp, baseImages, noCache),
+			Dependents: dependents[dgst],
+		})
+	}
+	return out
+}
+
+func cacheMissReasons(v *client.Vertex, byDigest map[digest.Digest]*client.Vertex, cmp *cacheMissComparison, baseImages []valueDiffOutput, noCache bool) []string {
+	if noCache {
+		return []string{"cache disabled with --no-cache"}
+	}
+	if cmp == nil {
+		return []string{"no previous build of the same target to compare with"}
+	}
+
+	var reasons []string
+	if isFromStep(v.Name) {
+		for _, m := range baseImages {
+			reasons = append(reasons, fmt.Sprintf("base image changed: %s (%s -> %s)", m.Name, *m.Base, *m.Compare))
+		}
+		return reasons
+	}
+	if _, ok := cmp.steps[v.Name]; !ok {
+		reasons = append(reasons, "step is new or its definition changed")
+	}
+	for _, dgst := range v.Inputs {
+		if in, ok := byDigest[dgst]; ok && isContextStep(in.Name) {
+			reasons = append(reasons, "build context files changed")
+			break
+		}
+	}
+	// build args are not recorded per step, a changed arg is only a possible
+	// cause as the step may not use it
+	for _, arg := range cmp.buildArgs {
+		reasons = append(reasons, "possible cause: build arg changed: "+formatValueDiff(arg))
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, "no cache found, it may have been pruned")
+	}
+	return reasons
+}
+
+func formatValueDiff(v valueDiffOutput) string {
+	b, c := "<unset>", "<unset>"
+	if v.Base != nil {
+		b = *v.Base
+	}
+	if v.Compare != nil {
+		c = *v.Compare
+	}
+	return fmt.Sprintf("%s (%s -> %s)", v.Name, b, c)
+}
+
+func printAnalysis(w io.Writer, a *analysisOutput) {
+	fmt.Fprintf(w, "Critical Path (%s):\n", formatDuration(a.CriticalPathDuration))
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "STEP\tDURATION\n")
+	for _, s := range a.CriticalPath {
+		d := formatDuration(s.Duration)
+		if s.Cached {
+			d = "CACHED"
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", s.Name, d)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Stages:")
+	tw = tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "STAGE\tDURATION\tSTEPS\tCACHED\n")
+	for _, st := range a.Stages {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\n", st.Name, formatDuration(st.Duration), st.Steps, st.CachedSteps)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+
+	if len(a.CacheMisses) == 0 {
+		return
+	}
+	if a.Compare != "" {
+		fmt.Fprintf(w, "Cache Misses (compared to %s):\n", a.Compare)
+	} else {
+		fmt.Fprintln(w, "Cache Misses:")
+	}
+	tw = tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "STEP\tDEPENDENTS\tREASON\n")
+	for _, m := range a.CacheMisses {
+		for i, r := range m.Reasons {
+			if i == 0 {
+				fmt.Fprintf(tw, "%s\t%d\t%s\n", m.Name, m.Dependents, r)
+			} else {
+				fmt.Fprintf(tw, "\t\t%s\n", r)
+			}
+		}
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,261 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+var analyzeStart = time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+// testVertex returns a vertex that started and completed at the given
+// offsets in seconds from analyzeStart. A negative start leaves the vertex
+// not started.
+func testVertex(name string, start, end int, cached bool, inputs ...*client.Vertex) *client.Vertex {
+	v := &client.Vertex{
+		Digest: digest.FromString(name),
+		Name:   name,
+		Cached: cached,
+	}
+	if start >= 0 {
+		started := analyzeStart.Add(time.Duration(start) * time.Second)
+		completed := analyzeStart.Add(time.Duration(end) * time.Second)
+		v.Started = &started
+		v.Completed = &completed
+	}
+	for _, in := range inputs {
+		v.Inputs = append(v.Inputs, in.Digest)
+	}
+	return v
+}
+
+func TestCriticalPath(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		path, total := criticalPath(nil)
+		require.Empty(t, path)
+		require.Zero(t, total)
+	})
+
+	t.Run("follows the input completed last", func(t *testing.T) {
+		from := testVertex("[base 1/1] FROM alpine", 0, 1, true)
+		deps := testVertex("[deps 1/1] RUN go mod download", 1, 4, false, from)
+		src := testVertex("[internal] load build context", 0, 2, false)
+		build := testVertex("[build 1/1] RUN go build", 4, 9, false, deps, src)
+		lint := testVertex("[lint 1/1] RUN golangci-lint", 1, 6, false, from)
+		pending := testVertex("[test 1/1] RUN go test", -1, -1, false, build)
+
+		path, total := criticalPath([]*client.Vertex{from, deps, src, build, lint, pending})
+		require.Equal(t, []criticalStepOutput{
+			{Name: from.Name, Duration: time.Second, Cached: true},
+			{Name: deps.Name, Duration: 3 * time.Second},
+			{Name: build.Name, Duration: 5 * time.Second},
+		}, path)
+		require.Equal(t, 9*time.Second, total)
+	})
+}
+
+func TestStageTimings(t *testing.T) {
+	vertexes := []*client.Vertex{
+		testVertex("[internal] load build definition from Dockerfile", 0, 1, false),
+		testVertex("[linux/amd64 base 1/1] FROM docker.io/library/alpine", 1, 1, true),
+		testVertex("[build 1/3] RUN a", 1, 3, false),
+		testVertex("[build 2/3] RUN b", 2, 4, false),
+		testVertex("[build 3/3] RUN c", 6, 7, false),
+		testVertex("[build 3/3] RUN d", 6, 6, true),
+		testVertex("[internal] load build context", 1, 2, false),
+		testVertex("exporting to image", 7, 8, false),
+		testVertex("[test 1/1] RUN test", -1, -1, false),
+	}
+	require.Equal(t, []stageOutput{
+		{Name: "internal", Duration: 2 * time.Second, Steps: 2},
+		{Name: "base", Duration: 0, Steps: 1, CachedSteps: 1},
+		{Name: "build", Duration: 4 * time.Second, Steps: 4, CachedSteps: 1},
+		{Name: statsNone, Duration: time.Second, Steps: 1},
+	}, stageTimings(vertexes))
+}
+
+func TestVertexStage(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "[build 3/5] RUN make", expected: "build"},
+		{name: "[linux/amd64 build 3/5] RUN make", expected: "build"},
+		{name: "[stage-1 2/2] COPY --from=build /out /", expected: "stage-1"},
+		{name: "[internal] load metadata for docker.io/library/alpine:latest", expected: "internal"},
+		{name: "[context base] load from client", expected: "context base"},
+		{name: "[2/3] RUN make", expected: statsNone},
+		{name: "exporting to image", expected: statsNone},
+		{name: "[build 3/5 RUN make", expected: statsNone},
+		{name: "", expected: statsNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, vertexStage(tt.name))
+		})
+	}
+}
+
+func TestCacheMisses(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	ctx := testVertex("[internal] load build context", 0, 1, false)
+	from := testVertex("[base 1/1] FROM docker.io/library/alpine", 0, 1, false)
+	copySrc := testVertex("[build 1/2] COPY . .", 1, 2, false, from, ctx)
+	build := testVertex("[build 2/2] RUN make", 2, 5, false, copySrc)
+	lint := testVertex("[lint 1/1] RUN lint", 1, 2, true, from)
+	test := testVertex("[test 1/1] RUN make test", 1, 3, false, from)
+	failed := testVertex("[fail 1/1] RUN false", 1, 2, false, from)
+	failed.Error = "exit code: 1"
+	vertexes := []*client.Vertex{ctx, from, copySrc, build, lint, test, failed}
+
+	cmp := &cacheMissComparison{
+		steps: map[string]struct{}{
+			ctx.Name:     {},
+			from.Name:    {},
+			copySrc.Name: {},
+			build.Name:   {},
+			lint.Name:    {},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		cmp      *cacheMissComparison
+		noCache  bool
+		expected []cacheMissOutput
+	}{
+		{
+			name: "no previous build",
+			expected: []cacheMissOutput{
+				{Name: copySrc.Name, Reasons: []string{"no previous build of the same target to compare with"}, Dependents: 1},
+				{Name: test.Name, Reasons: []string{"no previous build of the same target to compare with"}},
+			},
+		},
+		{
+			name:    "no cache",
+			cmp:     cmp,
+			noCache: true,
+			expected: []cacheMissOutput{
+				{Name: copySrc.Name, Reasons: []string{"cache disabled with --no-cache"}, Dependents: 1},
+				{Name: test.Name, Reasons: []string{"cache disabled with --no-cache"}},
+			},
+		},
+		{
+			name: "compared",
+			cmp:  cmp,
+			expected: []cacheMissOutput{
+				{Name: copySrc.Name, Reasons: []string{"build context files changed"}, Dependents: 1},
+				{Name: test.Name, Reasons: []string{"step is new or its definition changed"}},
+			},
+		},
+		{
+			name: "base image changed",
+			cmp: &cacheMissComparison{
+				steps: cmp.steps,
+				materials: []valueDiffOutput{
+					{Name: "pkg:docker/alpine@latest", Base: str("sha256:aaa"), Compare: str("sha256:bbb")},
+					{Name: "pkg:docker/golang@1.23", Compare: str("sha256:ccc")},
+				},
+			},
+			expected: []cacheMissOutput{
+				{Name: from.Name, Reasons: []string{"base image changed: pkg:docker/alpine@latest (sha256:aaa -> sha256:bbb)"}, Dependents: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, cacheMisses(vertexes, tt.cmp, tt.noCache))
+		})
+	}
+}
+
+func TestCacheMissReasons(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	ctx := testVertex("[internal] load build context", 0, 1, false)
+	named := testVertex("[context src] load from client", 0, 1, false)
+	from := testVertex("[base 1/1] FROM docker.io/library/alpine", 0, 1, true)
+	run := testVertex("[build 1/2] RUN make", 1, 2, false, from)
+	copyCtx := testVertex("[build 2/2] COPY . .", 1, 2, false, run, ctx)
+	copyNamed := testVertex("[build 2/2] COPY --from=src . .", 1, 2, false, run, named)
+	byDigest := map[digest.Digest]*client.Vertex{}
+	for _, v := range []*client.Vertex{ctx, named, from, run, copyCtx, copyNamed} {
+		byDigest[v.Digest] = v
+	}
+	steps := map[string]struct{}{
+		run.Name:       {},
+		copyCtx.Name:   {},
+		copyNamed.Name: {},
+	}
+
+	tests := []struct {
+		name       string
+		v          *client.Vertex
+		cmp        *cacheMissComparison
+		baseImages []valueDiffOutput
+		expected   []string
+	}{
+		{
+			name:     "pruned",
+			v:        run,
+			cmp:      &cacheMissComparison{steps: steps},
+			expected: []string{"no cache found, it may have been pruned"},
+		},
+		{
+			name:     "new step",
+			v:        run,
+			cmp:      &cacheMissComparison{},
+			expected: []string{"step is new or its definition changed"},
+		},
+		{
+			name:     "build context",
+			v:        copyCtx,
+			cmp:      &cacheMissComparison{steps: steps},
+			expected: []string{"build context files changed"},
+		},
+		{
+			name:     "named context",
+			v:        copyNamed,
+			cmp:      &cacheMissComparison{steps: steps},
+			expected: []string{"build context files changed"},
+		},
+		{
+			name: "build args are possible causes",
+			v:    copyCtx,
+			cmp: &cacheMissComparison{
+				steps: steps,
+				buildArgs: []valueDiffOutput{
+					{Name: "VERSION", Base: str("1"), Compare: str("2")},
+					{Name: "DEBUG", Compare: str("1")},
+				},
+			},
+			expected: []string{
+				"build context files changed",
+				"possible cause: build arg changed: VERSION (1 -> 2)",
+				"possible cause: build arg changed: DEBUG (<unset> -> 1)",
+			},
+		},
+		{
+			name: "base image",
+			v:    from,
+			cmp: &cacheMissComparison{
+				buildArgs: []valueDiffOutput{
+					{Name: "VERSION", Base: str("1"), Compare: str("2")},
+				},
+			},
+			baseImages: []valueDiffOutput{
+				{Name: "pkg:docker/alpine@latest", Base: str("sha256:aaa"), Compare: str("sha256:bbb")},
+			},
+			expected: []string{"base image changed: pkg:docker/alpine@latest (sha256:aaa -> sha256:bbb)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, cacheMissReasons(tt.v, byDigest, tt.cmp, tt.baseImages, false))
+		})
+	}
+}
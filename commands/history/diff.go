@@ -0,0 +1,431 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content/proxy"
+	"github.com/containerd/platforms"
+	"github.com/docker/buildx/localstate"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/confutil"
+	historyutil "github.com/docker/buildx/util/history"
+	"github.com/docker/cli/cli/command"
+	"github.com/docker/cli/cli/command/formatter"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// minStepDurationChange is the smallest change of the duration of a step that
+// is reported, shorter changes are not visible with formatDuration.
+const minStepDurationChange = 100 * time.Millisecond
+
+type diffOptions struct {
+	builder string
+	refs    [2]string
+	format  string
+}
+
+type diffOutput struct {
+	Base    diffRecordOutput
+	Compare diffRecordOutput
+
+	BuildArgs     []valueDiffOutput `json:",omitempty"`
+	FrontendAttrs []valueDiffOutput `json:",omitempty"`
+	Materials     []valueDiffOutput `json:",omitempty"`
+	Steps         []stepDiffOutput  `json:",omitempty"`
+	Exports       []valueDiffOutput `json:",omitempty"`
+	Attachments   []valueDiffOutput `json:",omitempty"`
+}
+
+type diffRecordOutput struct {
+	Name           string `json:",omitempty"`
+	Ref            string
+	Duration       time.Duration
+	NumTotalSteps  int32
+	NumCachedSteps int32
+}
+
+// valueDiffOutput is a value that is only set in one of the records or that
+// differs between them.
+type valueDiffOutput struct {
+	Name    string
+	Base    *string `json:",omitempty"`
+	Compare *string `json:",omitempty"`
+}
+
+type stepDiffOutput struct {
+	Name string
+	// Base is nil if the step was added.
+	Base *stepOutput `json:",omitempty"`
+	// Compare is nil if the step was removed.
+	Compare *stepOutput `json:",omitempty"`
+}
+
+type stepOutput struct {
+	Duration time.Duration
+	Cached   bool `json:",omitempty"`
+}
+
+// diffRecord is the comparable state of a build record.
+type diffRecord struct {
+	diffRecordOutput
+
+	buildArgs     map[string]string
+	frontendAttrs map[string]string
+	materials     map[string]string
+	steps         map[string]stepOutput
+	exports       map[string]string
+	attachments   map[string]string
+}
+
+func runDiff(ctx context.Context, dockerCli command.Cli, opts diffOptions) error {
+	if opts.format != formatter.PrettyFormatKey && opts.format != formatter.JSONFormatKey {
+		return errors.Errorf("unsupported format %q", opts.format)
+	}
+
+	nodes, err := loadNodes(ctx, dockerCli, opts.builder)
+	if err != nil {
+		return err
+	}
+
+	ls, err := localstate.New(confutil.NewConfig(dockerCli))
+	if err != nil {
+		return err
+	}
+
+	var recs [2]*diffRecord
+	for i, ref := range opts.refs {
+		found, err := queryRecords(ctx, ref, nodes, &queryOptions{CompletedOnly: true})
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			return errors.Errorf("no completed record found for ref %q", ref)
+		}
+		recs[i], err = loadDiffRecord(ctx, ls, &found[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	out := diffRecords(recs[0], recs[1])
+
+	if opts.format == formatter.JSONFormatKey {
+		enc := json.NewEncoder(dockerCli.Out())
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+	printDiff(dockerCli.Out(), out)
+	return nil
+}
+
+func loadDiffRecord(ctx context.Context, ls *localstate.LocalState, rec *historyRecord) (*diffRecord, error) {
+	c, err := rec.node.Driver.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	store := proxy.NewContentStore(c.ContentClient())
+
+	st, _ := ls.ReadRef(rec.node.Builder, rec.node.Name, rec.Ref)
+
+	out := &diffRecord{
+		diffRecordOutput: diffRecordOutput{
+			Name:           historyutil.BuildName(rec.FrontendAttrs, st),
+			Ref:            rec.Ref,
+			NumTotalSteps:  rec.NumTotalSteps,
+			NumCachedSteps: rec.NumCachedSteps,
+		},
+		buildArgs:     map[string]string{},
+		frontendAttrs: map[string]string{},
+		materials:     map[string]string{},
+		exports:       map[string]string{},
+		attachments:   map[string]string{},
+	}
+	if rec.CreatedAt != nil && rec.CompletedAt != nil {
+		out.Duration = rec.CompletedAt.AsTime().Sub(rec.CreatedAt.AsTime())
+	}
+
+	for k, v := range rec.FrontendAttrs {
+		if k == "frontend.caps" {
+			continue
+		}
+		if name, ok := strings.CutPrefix(k, "build-arg:"); ok {
+			out.buildArgs[name] = v
+		} else {
+			out.frontendAttrs[k] = v
+		}
+	}
+
+	attachments, err := allAttachments(ctx, store, *rec)
+	if err != nil {
+		return nil, err
+	}
+	materials, err := readMaterials(ctx, store, attachments)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range materials {
+		digests := slices.Sorted(slices.Values(m.Digests))
+		out.materials[m.URI] = strings.Join(digests, ", ")
+	}
+	for _, a := range attachments {
+		name := descrType(a.descr)
+		if a.platform != nil {
+			name = platforms.FormatAll(*a.platform) + " " + name
+		}
+		out.attachments[name] = a.descr.Digest.String()
+	}
+
+	for _, k := range []string{exptypes.ExporterImageDigestKey, exptypes.ExporterImageConfigDigestKey} {
+		if v, ok := rec.ExporterResponse[k]; ok {
+			out.exports[k] = v
+		}
+	}
+	if rec.Result != nil {
+		for i, r := range rec.Result.Results {
+			out.exports[fmt.Sprintf("result %d", i)] = r.Digest
+		}
+	}
+	for p, res := range rec.Results {
+		for i, r := range res.Results {
+			out.exports[fmt.Sprintf("%s result %d", p, i)] = r.Digest
+		}
+	}
+
+	out.steps, err = loadSteps(ctx, c, rec.Ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load steps of %s", rec.Ref)
+	}
+	return out, nil
+}
+
+// loadVertexes returns the vertexes of the build in the order they were
+// first reported.
+func loadVertexes(ctx context.Context, c *client.Client, ref string) ([]*client.Vertex, error) {
+	st, err := c.ControlClient().Status(ctx, &controlapi.StatusRequest{
+		Ref: ref,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer st.CloseSend()
+
+	vertexes := map[digest.Digest]*client.Vertex{}
+	var order []digest.Digest
+	for {
+		ev, err := st.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		for _, v := range client.NewSolveStatus(ev).Vertexes {
+			if _, ok := vertexes[v.Digest]; !ok {
+				order = append(order, v.Digest)
+			}
+			vertexes[v.Digest] = v
+		}
+	}
+
+	out := make([]*client.Vertex, 0, len(order))
+	for _, dgst := range order {
+		out = append(out, vertexes[dgst])
+	}
+	return out, nil
+}
+
+// loadSteps returns the duration and cache status of the vertexes of the
+// build by name. Vertexes are matched by name between builds as their digest
+// changes with their inputs.
+func loadSteps(ctx context.Context, c *client.Client, ref string) (map[string]stepOutput, error) {
+	vertexes, err := loadVertexes(ctx, c, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make(map[string]stepOutput, len(vertexes))
+	for _, v := range vertexes {
+		var step stepOutput
+		step.Cached = v.Cached
+		if v.Started != nil && v.Completed != nil {
+			step.Duration = v.Completed.Sub(*v.Started)
+		}
+		name := v.Name
+		for i := 2; ; i++ {
+			if _, ok := steps[name]; !ok {
+				break
+			}
+			name = fmt.Sprintf("%s #%d", v.Name, i)
+		}
+		steps[name] = step
+	}
+	return steps, nil
+}
+
+func diffRecords(base, compare *diffRecord) diffOutput {
+	out := diffOutput{
+		Base:          base.diffRecordOutput,
+		Compare:       compare.diffRecordOutput,
+		BuildArgs:     diffValues(base.buildArgs, compare.buildArgs),
+		FrontendAttrs: diffValues(base.frontendAttrs, compare.frontendAttrs),
+		Materials:     diffValues(base.materials, compare.materials),
+		Exports:       diffValues(base.exports, compare.exports),
+		Attachments:   diffValues(base.attachments, compare.attachments),
+	}
+	for _, name := range slices.Sorted(maps.Keys(mergedKeys(base.steps, compare.steps))) {
+		b, inBase := base.steps[name]
+		c, inCompare := compare.steps[name]
+		if inBase && inCompare && b.Cached == c.Cached && (b.Duration-c.Duration).Abs() < minStepDurationChange {
+			continue
+		}
+		sd := stepDiffOutput{Name: name}
+		if inBase {
+			sd.Base = &b
+		}
+		if inCompare {
+			sd.Compare = &c
+		}
+		out.Steps = append(out.Steps, sd)
+	}
+	return out
+}
+
+func diffValues(base, compare map[string]string) []valueDiffOutput {
+	var out []valueDiffOutput
+	for _, k := range slices.Sorted(maps.Keys(mergedKeys(base, compare))) {
+		b, inBase := base[k]
+		c, inCompare := compare[k]
+		if inBase && inCompare && b == c {
+			continue
+		}
+		vd := valueDiffOutput{Name: k}
+		if inBase {
+			vd.Base = &b
+		}
+		if inCompare {
+			vd.Compare = &c
+		}
+		out = append(out, vd)
+	}
+	return out
+}
+
+func mergedKeys[T any](a, b map[string]T) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+func printDiff(w io.Writer, out diffOutput) {
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "\tBASE\tCOMPARE\n")
+	fmt.Fprintf(tw, "Ref:\t%s\t%s\n", out.Base.Ref, out.Compare.Ref)
+	if out.Base.Name != "" || out.Compare.Name != "" {
+		fmt.Fprintf(tw, "Name:\t%s\t%s\n", out.Base.Name, out.Compare.Name)
+	}
+	fmt.Fprintf(tw, "Duration:\t%s\t%s (%s)\n", formatDuration(out.Base.Duration), formatDuration(out.Compare.Duration), formatDurationDelta(out.Compare.Duration-out.Base.Duration))
+	fmt.Fprintf(tw, "Build Steps:\t%d (%d cached)\t%d (%d cached)\n", out.Base.NumTotalSteps, out.Base.NumCachedSteps, out.Compare.NumTotalSteps, out.Compare.NumCachedSteps)
+	tw.Flush()
+	fmt.Fprintln(w)
+
+	printValueDiffs(w, out.BuildArgs, "Build Arg")
+	printValueDiffs(w, out.FrontendAttrs, "Frontend Attr")
+	printValueDiffs(w, out.Materials, "Material")
+
+	if len(out.Steps) > 0 {
+		tw = tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+		fmt.Fprintf(tw, "STEP\tBASE\tCOMPARE\tDELTA\n")
+		for _, s := range out.Steps {
+			delta := "-"
+			if s.Base != nil && s.Compare != nil {
+				delta = formatDurationDelta(s.Compare.Duration - s.Base.Duration)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Name, formatStep(s.Base), formatStep(s.Compare), delta)
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+	}
+
+	printValueDiffs(w, out.Exports, "Export")
+	printValueDiffs(w, out.Attachments, "Attachment")
+}
+
+func printValueDiffs(w io.Writer, diffs []valueDiffOutput, title string) {
+	if len(diffs) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "%s\tBASE\tCOMPARE\n", strings.ToUpper(title))
+	for _, d := range diffs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", d.Name, formatDiffValue(d.Base), formatDiffValue(d.Compare))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+func formatDiffValue(v *string) string {
+	if v == nil {
+		return "-"
+	}
+	if *v == "" {
+		return `""`
+	}
+	return *v
+}
+
+func formatStep(s *stepOutput) string {
+	if s == nil {
+		return "-"
+	}
+	if s.Cached {
+		return "CACHED"
+	}
+	return formatDuration(s.Duration)
+}
+
+func formatDurationDelta(d time.Duration) string {
+	if d < 0 {
+		return "-" + formatDuration(-d)
+	}
+	return "+" + formatDuration(d)
+}
+
+func diffCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
+	var options diffOptions
+
+	cmd := &cobra.Command{
+		Use:   "diff [OPTIONS] REF1 REF2",
+		Short: "Compare two build records",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.refs = [2]string{args[0], args[1]}
+			options.builder = *rootOpts.Builder
+			return runDiff(cmd.Context(), dockerCli, options)
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.format, "format", formatter.PrettyFormatKey, "Format the output")
+
+	return cmd
+}
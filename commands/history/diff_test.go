@@ -0,0 +1,156 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffValues(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name     string
+		base     map[string]string
+		compare  map[string]string
+		expected []valueDiffOutput
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "equal",
+			base:    map[string]string{"a": "1", "b": "2"},
+			compare: map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			name:    "added",
+			base:    map[string]string{"a": "1"},
+			compare: map[string]string{"a": "1", "b": "2"},
+			expected: []valueDiffOutput{
+				{Name: "b", Compare: str("2")},
+			},
+		},
+		{
+			name:    "removed",
+			base:    map[string]string{"a": "1", "b": "2"},
+			compare: map[string]string{"b": "2"},
+			expected: []valueDiffOutput{
+				{Name: "a", Base: str("1")},
+			},
+		},
+		{
+			name:    "changed",
+			base:    map[string]string{"a": "1"},
+			compare: map[string]string{"a": "2"},
+			expected: []valueDiffOutput{
+				{Name: "a", Base: str("1"), Compare: str("2")},
+			},
+		},
+		{
+			name:    "empty value",
+			base:    map[string]string{"a": ""},
+			compare: map[string]string{},
+			expected: []valueDiffOutput{
+				{Name: "a", Base: str("")},
+			},
+		},
+		{
+			name:    "sorted",
+			base:    map[string]string{"c": "1", "a": "1"},
+			compare: map[string]string{"b": "1", "c": "2"},
+			expected: []valueDiffOutput{
+				{Name: "a", Base: str("1")},
+				{Name: "b", Compare: str("1")},
+				{Name: "c", Base: str("1"), Compare: str("2")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, diffValues(tt.base, tt.compare))
+		})
+	}
+}
+
+func TestDiffRecords(t *testing.T) {
+	base := &diffRecord{
+		diffRecordOutput: diffRecordOutput{
+			Ref:            "base",
+			Duration:       10 * time.Second,
+			NumTotalSteps:  4,
+			NumCachedSteps: 1,
+		},
+		buildArgs: map[string]string{"VERSION": "1"},
+		steps: map[string]stepOutput{
+			"[1/4] FROM alpine":     {Duration: time.Second, Cached: true},
+			"[2/4] RUN apk add git": {Duration: 5 * time.Second},
+			"[3/4] COPY . .":        {Duration: time.Second},
+			"[4/4] RUN make":        {Duration: 3 * time.Second},
+		},
+		exports: map[string]string{"image.name": "foo"},
+	}
+	compare := &diffRecord{
+		diffRecordOutput: diffRecordOutput{
+			Ref:            "compare",
+			Duration:       5 * time.Second,
+			NumTotalSteps:  4,
+			NumCachedSteps: 2,
+		},
+		buildArgs: map[string]string{"VERSION": "2"},
+		steps: map[string]stepOutput{
+			"[1/4] FROM alpine":     {Duration: time.Second + 50*time.Millisecond, Cached: true},
+			"[2/4] RUN apk add git": {Duration: 5 * time.Second, Cached: true},
+			"[3/4] COPY . .":        {Duration: 2 * time.Second},
+			"[4/4] RUN make -j4":    {Duration: 2 * time.Second},
+		},
+		exports: map[string]string{"image.name": "foo"},
+	}
+
+	out := diffRecords(base, compare)
+	require.Equal(t, base.diffRecordOutput, out.Base)
+	require.Equal(t, compare.diffRecordOutput, out.Compare)
+
+	require.Len(t, out.BuildArgs, 1)
+	require.Equal(t, "VERSION", out.BuildArgs[0].Name)
+	require.Equal(t, "1", *out.BuildArgs[0].Base)
+	require.Equal(t, "2", *out.BuildArgs[0].Compare)
+	require.Empty(t, out.FrontendAttrs)
+	require.Empty(t, out.Materials)
+	require.Empty(t, out.Exports)
+	require.Empty(t, out.Attachments)
+
+	require.Equal(t, []stepDiffOutput{
+		{
+			Name:    "[2/4] RUN apk add git",
+			Base:    &stepOutput{Duration: 5 * time.Second},
+			Compare: &stepOutput{Duration: 5 * time.Second, Cached: true},
+		},
+		{
+			Name:    "[3/4] COPY . .",
+			Base:    &stepOutput{Duration: time.Second},
+			Compare: &stepOutput{Duration: 2 * time.Second},
+		},
+		{
+			Name: "[4/4] RUN make",
+			Base: &stepOutput{Duration: 3 * time.Second},
+		},
+		{
+			Name:    "[4/4] RUN make -j4",
+			Compare: &stepOutput{Duration: 2 * time.Second},
+		},
+	}, out.Steps)
+}
+
+func TestDiffRecordsEqual(t *testing.T) {
+	rec := &diffRecord{
+		buildArgs: map[string]string{"VERSION": "1"},
+		steps: map[string]stepOutput{
+			"[1/1] RUN make": {Duration: time.Second},
+		},
+	}
+	out := diffRecords(rec, rec)
+	require.Empty(t, out.BuildArgs)
+	require.Empty(t, out.Steps)
+}
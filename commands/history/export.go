@@ -12,22 +12,63 @@ import (
 	"github.com/docker/buildx/util/cobrautil/completion"
 	"github.com/docker/buildx/util/confutil"
 	"github.com/docker/buildx/util/desktop/bundle"
+	historyutil "github.com/docker/buildx/util/history"
 	"github.com/docker/cli/cli/command"
 	"github.com/moby/buildkit/client"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
+const (
+	exportFormatBundle = "bundle"
+	exportFormatOTLP   = "otlp"
+)
+
 type exportOptions struct {
-	builder  string
-	refs     []string
-	output   string
-	all      bool
-	finalize bool
+	builder     string
+	refs        []string
+	output      string
+	format      string
+	all         bool
+	allBuilders bool
+	finalize    bool
+	filters     []string
+	since       string
 }
 
 func runExport(ctx context.Context, dockerCli command.Cli, opts exportOptions) error {
-	nodes, err := loadNodes(ctx, dockerCli, opts.builder)
+	switch opts.format {
+	case exportFormatBundle:
+	case exportFormatOTLP:
+		// spans are read from the trace of the record that is only
+		// available once the record is finalized
+		opts.finalize = true
+	default:
+		return errors.Errorf("unsupported format %q", opts.format)
+	}
+
+	queryOptions := &queryOptions{
+		CompletedOnly: true,
+	}
+	if opts.since != "" {
+		opts.filters = append(opts.filters, "startedAt>"+opts.since)
+	}
+	if len(opts.filters) > 0 {
+		// filters select the records to export like --all
+		opts.all = true
+		queryOptions.Filters = opts.filters
+	}
+	if opts.all {
+		queryOptions.Limit = -1
+	}
+
+	var nodes []builder.Node
+	var err error
+	if opts.allBuilders {
+		nodes, err = loadAllNodes(ctx, dockerCli)
+	} else {
+		nodes, err = loadNodes(ctx, dockerCli, opts.builder)
+	}
 	if err != nil {
 		return err
 	}
@@ -38,9 +79,7 @@ func runExport(ctx context.Context, dockerCli command.Cli, opts exportOptions) e
 
 	var res []historyRecord
 	for _, ref := range opts.refs {
-		recs, err := queryRecords(ctx, ref, nodes, &queryOptions{
-			CompletedOnly: true,
-		})
+		recs, err := queryRecords(ctx, ref, nodes, queryOptions)
 		if err != nil {
 			return err
 		}
@@ -86,9 +125,7 @@ func runExport(ctx context.Context, dockerCli command.Cli, opts exportOptions) e
 				if !opts.all {
 					queryRef = toExport[0].Ref
 				}
-				recs, err = queryRecords(ctx, queryRef, nodes, &queryOptions{
-					CompletedOnly: true,
-				})
+				recs, err = queryRecords(ctx, queryRef, nodes, queryOptions)
 				if err != nil {
 					return err
 				}
@@ -101,6 +138,10 @@ func runExport(ctx context.Context, dockerCli command.Cli, opts exportOptions) e
 		}
 	}
 
+	if opts.format == exportFormatOTLP {
+		return exportOTLP(ctx, dockerCli.Out(), res, opts.output)
+	}
+
 	ls, err := localstate.New(confutil.NewConfig(dockerCli))
 	if err != nil {
 		return err
@@ -141,6 +182,12 @@ func runExport(ctx context.Context, dockerCli command.Cli, opts exportOptions) e
 			DefaultPlatform:    defaultPlatform,
 			LocalState:         st,
 			StateGroup:         stg,
+			Annotations: map[string]string{
+				bundle.AnnotationBuilder: rec.node.Builder,
+				bundle.AnnotationNode:    rec.node.Name,
+				bundle.AnnotationName:    historyutil.BuildName(rec.FrontendAttrs, st),
+				bundle.AnnotationStatus:  recordStatus(rec.BuildHistoryRecord),
+			},
 		})
 	}
 
@@ -166,11 +213,17 @@ func exportCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:   "export [OPTIONS] [REF...]",
-		Short: "Export build records into Docker Desktop bundle",
+		Short: "Export build records into Docker Desktop bundle or OpenTelemetry traces",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if options.all && len(args) > 0 {
 				return errors.New("cannot specify refs when using --all")
 			}
+			if (len(options.filters) > 0 || options.since != "") && len(args) > 0 {
+				return errors.New("cannot specify refs when using --filter or --since")
+			}
+			if options.allBuilders && cmd.Flags().Changed("builder") {
+				return errors.New("cannot specify --builder when using --all-builders")
+			}
 			options.refs = args
 			options.builder = *rootOpts.Builder
 			return runExport(cmd.Context(), dockerCli, options)
@@ -180,9 +233,13 @@ func exportCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
 	}
 
 	flags := cmd.Flags()
-	flags.StringVarP(&options.output, "output", "o", "", "Output file path")
+	flags.StringVarP(&options.output, "output", "o", "", "Output file path, or OTLP/HTTP endpoint URL for the otlp format")
+	flags.StringVar(&options.format, "format", exportFormatBundle, `Export format ("bundle", "otlp")`)
 	flags.BoolVar(&options.all, "all", false, "Export all build records for the builder")
+	flags.BoolVar(&options.allBuilders, "all-builders", false, "Export build records of all running builders")
 	flags.BoolVar(&options.finalize, "finalize", false, "Ensure build records are finalized before exporting")
+	flags.StringArrayVar(&options.filters, "filter", nil, `Export all build records matching the filter (e.g., "status=error")`)
+	flags.StringVar(&options.since, "since", "", `Export all build records created since a timestamp or relative time (e.g., "24h")`)
 
 	return cmd
 }
@@ -0,0 +1,219 @@
+package history
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	historyutil "github.com/docker/buildx/util/history"
+	"github.com/docker/buildx/util/otelutil"
+	"github.com/moby/buildkit/client"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// otlpTracesPath is the default path of the traces endpoint of an OTLP/HTTP
+// receiver.
+const otlpTracesPath = "/v1/traces"
+
+// exportOTLP exports the spans of the records to output, which is either a
+// file path or the URL of an OTLP/HTTP endpoint. Files are written in the
+// JSON encoding of the OTLP traces data. Without output, the spans are
+// written to w.
+func exportOTLP(ctx context.Context, w io.Writer, recs []historyRecord, output string) error {
+	var spans otelutil.Spans
+	for _, rec := range recs {
+		recSpans, err := recordSpans(ctx, &rec)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load spans of %s", rec.Ref)
+		}
+		res := recordResource(&rec)
+		for _, s := range recSpans {
+			s.Resource = slices.Concat(s.Resource, res)
+			spans = append(spans, s)
+		}
+	}
+
+	if strings.HasPrefix(output, "http://") || strings.HasPrefix(output, "https://") {
+		return sendOTLP(ctx, output, spans)
+	}
+
+	fc := &otlpFileClient{}
+	if err := otlptrace.NewUnstarted(fc).ExportSpans(ctx, spans.ReadOnlySpans()); err != nil {
+		return err
+	}
+	dt, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(&tracepb.TracesData{
+		ResourceSpans: fc.resourceSpans,
+	})
+	if err != nil {
+		return err
+	}
+	dt = append(dt, '\n')
+	if output == "" {
+		_, err := w.Write(dt)
+		return err
+	}
+	if err := os.WriteFile(output, dt, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write output file %q", output)
+	}
+	return nil
+}
+
+// sendOTLP sends the spans to the traces endpoint of an OTLP/HTTP receiver.
+// If the URL has no path, the default traces path is used.
+func sendOTLP(ctx context.Context, endpoint string, spans otelutil.Spans) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return errors.Wrapf(err, "invalid endpoint %q", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpTracesPath
+	}
+
+	exp, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(u.String()))
+	if err != nil {
+		return err
+	}
+	defer exp.Shutdown(context.WithoutCancel(ctx))
+
+	if err := exp.ExportSpans(ctx, spans.ReadOnlySpans()); err != nil {
+		return errors.Wrapf(err, "failed to send traces to %s", u)
+	}
+	return nil
+}
+
+// otlpFileClient is an OTLP client that keeps the uploaded spans in memory
+// so that they can be written to a file.
+type otlpFileClient struct {
+	mu            sync.Mutex
+	resourceSpans []*tracepb.ResourceSpans
+}
+
+func (c *otlpFileClient) Start(context.Context) error {
+	return nil
+}
+
+func (c *otlpFileClient) Stop(context.Context) error {
+	return nil
+}
+
+func (c *otlpFileClient) UploadTraces(_ context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resourceSpans = append(c.resourceSpans, protoSpans...)
+	return nil
+}
+
+// recordSpans returns the spans of the trace of the record. If the record has
+// no trace, spans are generated from the timings of its vertexes.
+func recordSpans(ctx context.Context, rec *historyRecord) (otelutil.Spans, error) {
+	if rec.Trace != nil {
+		return readTraceSpans(ctx, rec)
+	}
+	c, err := rec.node.Driver.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vertexes, err := loadVertexes(ctx, c, rec.Ref)
+	if err != nil {
+		return nil, err
+	}
+	return vertexSpans(rec, vertexes), nil
+}
+
+// vertexSpans returns a span for the build and a child span for each
+// vertex. Trace and span IDs are derived from the record ref and vertex
+// digests so that exporting the same record twice gives the same spans.
+func vertexSpans(rec *historyRecord, vertexes []*client.Vertex) otelutil.Spans {
+	sum := sha256.Sum256([]byte(rec.Ref))
+	var traceID trace.TraceID
+	copy(traceID[:], sum[:16])
+	var rootID trace.SpanID
+	copy(rootID[:], sum[16:24])
+
+	res := []attribute.KeyValue{
+		attribute.String("service.name", "buildkitd"),
+	}
+	root := otelutil.Span{
+		Name: historyutil.BuildName(rec.FrontendAttrs, nil),
+		SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID,
+			SpanID:  rootID,
+		}),
+		SpanKind:  trace.SpanKindInternal,
+		StartTime: rec.CreatedAt.AsTime(),
+		Resource:  res,
+	}
+	if rec.CompletedAt != nil {
+		root.EndTime = rec.CompletedAt.AsTime()
+	}
+	if rec.Error != nil {
+		root.Status = tracesdk.Status{Code: codes.Error, Description: rec.Error.Message}
+	}
+
+	spans := otelutil.Spans{root}
+	for _, v := range vertexes {
+		if v.Started == nil {
+			continue
+		}
+		sum := sha256.Sum256([]byte(rec.Ref + "\x00" + v.Digest.String()))
+		var spanID trace.SpanID
+		copy(spanID[:], sum[:8])
+
+		span := otelutil.Span{
+			Name: v.Name,
+			SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID: traceID,
+				SpanID:  spanID,
+			}),
+			Parent:    root.SpanContext,
+			SpanKind:  trace.SpanKindInternal,
+			StartTime: *v.Started,
+			EndTime:   root.EndTime,
+			Attributes: []attribute.KeyValue{
+				attribute.String("vertex", v.Digest.String()),
+				attribute.Bool("cached", v.Cached),
+			},
+			Resource: res,
+		}
+		if v.Completed != nil {
+			span.EndTime = *v.Completed
+		}
+		if v.Error != "" {
+			span.Status = tracesdk.Status{Code: codes.Error, Description: v.Error}
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// recordResource returns the resource attributes identifying the record.
+func recordResource(rec *historyRecord) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("buildx.builder", rec.node.Builder),
+		attribute.String("buildx.node", rec.node.Name),
+		attribute.String("buildx.ref", rec.Ref),
+	}
+	if v := rec.FrontendAttrs["target"]; v != "" {
+		attrs = append(attrs, attribute.String("buildx.target", v))
+	}
+	if v := recordRepository(rec.BuildHistoryRecord); v != "" {
+		attrs = append(attrs, attribute.String("vcs.repository.url.full", v))
+	}
+	if v := rec.FrontendAttrs["vcs:revision"]; v != "" {
+		attrs = append(attrs, attribute.String("vcs.ref.head.revision", v))
+	}
+	return attrs
+}
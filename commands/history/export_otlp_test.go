@@ -0,0 +1,213 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/store"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestVertexSpans(t *testing.T) {
+	created := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	completed := created.Add(10 * time.Second)
+	rec := &historyRecord{
+		BuildHistoryRecord: &controlapi.BuildHistoryRecord{
+			Ref: "qu2gsuo8ejqrwdfii23xkkckt",
+			FrontendAttrs: map[string]string{
+				"build-arg:BUILDKIT_BUILD_NAME": "app",
+			},
+			CreatedAt:   timestamppb.New(created),
+			CompletedAt: timestamppb.New(completed),
+			Error:       &spb.Status{Message: "failed to solve"},
+		},
+	}
+	at := func(d time.Duration) *time.Time {
+		tm := created.Add(d)
+		return &tm
+	}
+	vertexes := []*client.Vertex{
+		{
+			Digest:    digest.FromString("from"),
+			Name:      "[1/3] FROM alpine",
+			Started:   at(0),
+			Completed: at(time.Second),
+			Cached:    true,
+		},
+		{
+			Digest:    digest.FromString("run"),
+			Name:      "[2/3] RUN make",
+			Started:   at(time.Second),
+			Completed: at(9 * time.Second),
+			Error:     "exit code: 2",
+		},
+		{
+			Digest:  digest.FromString("interrupted"),
+			Name:    "[3/3] RUN make install",
+			Started: at(2 * time.Second),
+		},
+		{
+			Digest: digest.FromString("skipped"),
+			Name:   "[3/3] COPY . .",
+		},
+	}
+
+	spans := vertexSpans(rec, vertexes)
+	require.Len(t, spans, 4)
+
+	root := spans[0]
+	require.Equal(t, "app", root.Name)
+	require.True(t, root.SpanContext.IsValid())
+	require.False(t, root.Parent.IsValid())
+	require.Equal(t, created, root.StartTime)
+	require.Equal(t, completed, root.EndTime)
+	require.Equal(t, codes.Error, root.Status.Code)
+	require.Equal(t, "failed to solve", root.Status.Description)
+	require.Equal(t, []attribute.KeyValue{attribute.String("service.name", "buildkitd")}, root.Resource)
+
+	spanIDs := map[string]struct{}{root.SpanContext.SpanID().String(): {}}
+	for i, s := range spans[1:] {
+		v := vertexes[i]
+		require.Equal(t, v.Name, s.Name)
+		require.Equal(t, root.SpanContext.TraceID(), s.SpanContext.TraceID())
+		require.Equal(t, root.SpanContext, s.Parent)
+		require.Equal(t, *v.Started, s.StartTime)
+		require.Contains(t, s.Attributes, attribute.String("vertex", v.Digest.String()))
+		require.Contains(t, s.Attributes, attribute.Bool("cached", v.Cached))
+		spanIDs[s.SpanContext.SpanID().String()] = struct{}{}
+	}
+	require.Len(t, spanIDs, 4)
+
+	require.Equal(t, created.Add(time.Second), spans[1].EndTime)
+	require.Equal(t, codes.Unset, spans[1].Status.Code)
+	require.Equal(t, codes.Error, spans[2].Status.Code)
+	require.Equal(t, "exit code: 2", spans[2].Status.Description)
+	// vertexes that did not complete end with the build
+	require.Equal(t, completed, spans[3].EndTime)
+
+	// exporting the same record again gives the same spans
+	require.Equal(t, spans, vertexSpans(rec, vertexes))
+}
+
+func TestRecordResource(t *testing.T) {
+	node := &builder.Node{
+		Node:    store.Node{Name: "builder0"},
+		Builder: "builder",
+	}
+
+	tests := []struct {
+		name     string
+		attrs    map[string]string
+		expected []attribute.KeyValue
+	}{
+		{
+			name: "minimal",
+			expected: []attribute.KeyValue{
+				attribute.String("buildx.builder", "builder"),
+				attribute.String("buildx.node", "builder0"),
+				attribute.String("buildx.ref", "ref"),
+			},
+		},
+		{
+			name: "vcs",
+			attrs: map[string]string{
+				"target":       "binaries",
+				"vcs:source":   "https://github.com/docker/buildx.git",
+				"vcs:revision": "f3bcb2a5e4e3b04e6a3d11d1a0e0cd9f51de4c28",
+			},
+			expected: []attribute.KeyValue{
+				attribute.String("buildx.builder", "builder"),
+				attribute.String("buildx.node", "builder0"),
+				attribute.String("buildx.ref", "ref"),
+				attribute.String("buildx.target", "binaries"),
+				attribute.String("vcs.repository.url.full", "https://github.com/docker/buildx.git"),
+				attribute.String("vcs.ref.head.revision", "f3bcb2a5e4e3b04e6a3d11d1a0e0cd9f51de4c28"),
+			},
+		},
+		{
+			name: "git context",
+			attrs: map[string]string{
+				"context": "https://github.com/docker/buildx.git#main",
+			},
+			expected: []attribute.KeyValue{
+				attribute.String("buildx.builder", "builder"),
+				attribute.String("buildx.node", "builder0"),
+				attribute.String("buildx.ref", "ref"),
+				attribute.String("vcs.repository.url.full", "https://github.com/docker/buildx.git"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &historyRecord{
+				BuildHistoryRecord: &controlapi.BuildHistoryRecord{
+					Ref:           "ref",
+					FrontendAttrs: tt.attrs,
+				},
+				node: node,
+			}
+			require.Equal(t, tt.expected, recordResource(rec))
+		})
+	}
+}
+
+func TestSendOTLP(t *testing.T) {
+	created := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	rec := &historyRecord{
+		BuildHistoryRecord: &controlapi.BuildHistoryRecord{
+			Ref:         "ref",
+			CreatedAt:   timestamppb.New(created),
+			CompletedAt: timestamppb.New(created.Add(time.Second)),
+		},
+	}
+	spans := vertexSpans(rec, nil)
+
+	var paths []string
+	var received collectortracepb.ExportTraceServiceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/invalid" {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		dt, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, proto.Unmarshal(dt, &received))
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer srv.Close()
+
+	require.NoError(t, sendOTLP(context.TODO(), srv.URL, spans))
+	require.Len(t, received.ResourceSpans, 1)
+	require.Len(t, received.ResourceSpans[0].ScopeSpans, 1)
+	require.Len(t, received.ResourceSpans[0].ScopeSpans[0].Spans, 1)
+	require.Equal(t, spans[0].Name, received.ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+
+	require.NoError(t, sendOTLP(context.TODO(), srv.URL+"/otlp/v1/traces", spans))
+
+	err := sendOTLP(context.TODO(), srv.URL+"/invalid", spans)
+	require.ErrorContains(t, err, "failed to send traces")
+
+	require.Equal(t, []string{otlpTracesPath, "/otlp/v1/traces", "/invalid"}, paths)
+}
+
+func TestExportOTLPWriter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, exportOTLP(context.TODO(), &buf, nil, ""))
+	require.Equal(t, "{}\n", buf.String())
+}
@@ -8,11 +8,16 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
+	"text/tabwriter"
 
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/plugins/content/local"
 	remoteutil "github.com/docker/buildx/driver/remote/util"
 	"github.com/docker/buildx/util/cobrautil/completion"
 	"github.com/docker/buildx/util/desktop"
+	"github.com/docker/buildx/util/desktop/bundle"
 	"github.com/docker/cli/cli/command"
 	"github.com/pkg/browser"
 	"github.com/pkg/errors"
@@ -20,62 +25,178 @@ import (
 )
 
 type importOptions struct {
-	file []string
+	file    []string
+	refs    []string
+	filters []string
+	list    bool
 }
 
 func runImport(ctx context.Context, dockerCli command.Cli, opts importOptions) error {
-	sock, err := desktop.BuildServerAddr()
+	if len(opts.file) == 0 {
+		opts.file = []string{"-"}
+	}
+
+	if len(opts.refs) > 0 || len(opts.filters) > 0 || opts.list {
+		return runImportSelected(ctx, dockerCli, opts)
+	}
+
+	client, err := desktopClient()
 	if err != nil {
 		return err
 	}
 
-	tr := http.DefaultTransport.(*http.Transport).Clone()
-	tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
-		network, addr, ok := strings.Cut(sock, "://")
-		if !ok {
-			return nil, errors.Errorf("invalid endpoint address: %s", sock)
+	var urls []string
+	for _, fn := range opts.file {
+		var f *os.File
+		var rdr io.Reader = os.Stdin
+		if fn != "-" {
+			f, err = os.Open(fn)
+			if err != nil {
+				return errors.Wrapf(err, "failed to open file %s", fn)
+			}
+			rdr = f
+		}
+		u, err := importFrom(ctx, client, rdr)
+		if err != nil {
+			return err
+		}
+		urls = append(urls, u...)
+		if f != nil {
+			f.Close()
 		}
-		return remoteutil.DialContext(ctx, network, addr)
 	}
 
-	client := &http.Client{
-		Transport: tr,
+	return openImported(dockerCli, urls)
+}
+
+// readBundle reads the entries of a bundle file, or of stdin for "-", into
+// the store. The file is closed before returning.
+func readBundle(ctx context.Context, store content.Store, fn string) ([]bundle.Entry, error) {
+	var rdr io.Reader = os.Stdin
+	if fn != "-" {
+		f, err := os.Open(fn)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open file %s", fn)
+		}
+		defer f.Close()
+		rdr = f
 	}
+	es, err := bundle.Read(ctx, store, rdr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read bundle %s", fn)
+	}
+	return es, nil
+}
 
-	var urls []string
+// runImportSelected reads the bundles and imports or lists the build records
+// selected by ref or filter only.
+func runImportSelected(ctx context.Context, dockerCli command.Cli, opts importOptions) error {
+	_, matchers, err := dockerFiltersToBuildkit(opts.filters)
+	if err != nil {
+		return err
+	}
 
-	if len(opts.file) == 0 {
-		u, err := importFrom(ctx, client, os.Stdin)
+	dir, err := os.MkdirTemp("", "buildx-history-import")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	store, err := local.NewStore(dir)
+	if err != nil {
+		return err
+	}
+
+	var entries []bundle.Entry
+	for _, fn := range opts.file {
+		es, err := readBundle(ctx, store, fn)
 		if err != nil {
 			return err
 		}
-		urls = append(urls, u...)
-	} else {
-		for _, fn := range opts.file {
-			var f *os.File
-			var rdr io.Reader = os.Stdin
-			if fn != "-" {
-				f, err = os.Open(fn)
-				if err != nil {
-					return errors.Wrapf(err, "failed to open file %s", fn)
-				}
-				rdr = f
+	loop0:
+		for _, e := range es {
+			if len(opts.refs) > 0 && !slices.Contains(opts.refs, e.Record.Ref) {
+				continue
 			}
-			u, err := importFrom(ctx, client, rdr)
-			if err != nil {
-				return err
-			}
-			urls = append(urls, u...)
-			if f != nil {
-				f.Close()
+			for _, matcher := range matchers {
+				if !matcher(e.Record.BuildHistoryRecord) {
+					continue loop0
+				}
 			}
+			entries = append(entries, e)
 		}
 	}
 
+	if opts.list {
+		printBundleEntries(dockerCli.Out(), entries)
+		return nil
+	}
+
+	if len(entries) == 0 {
+		return errors.New("no build records found in the bundle")
+	}
+
+	client, err := desktopClient()
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(bundle.Write(ctx, store, pw, entries))
+	}()
+	urls, err := importFrom(ctx, client, pr)
+	pr.CloseWithError(err)
+	if err != nil {
+		return err
+	}
+	return openImported(dockerCli, urls)
+}
+
+func printBundleEntries(w io.Writer, entries []bundle.Entry) {
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "BUILD ID\tBUILDER\tNAME\tSTATUS\tCREATED AT\n")
+	for _, e := range entries {
+		var created string
+		if e.Record.CreatedAt != nil {
+			created = e.Record.CreatedAt.AsTime().Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			e.Record.Ref,
+			e.Descriptor.Annotations[bundle.AnnotationBuilder],
+			e.Descriptor.Annotations[bundle.AnnotationName],
+			recordStatus(e.Record.BuildHistoryRecord),
+			created,
+		)
+	}
+	tw.Flush()
+}
+
+func desktopClient() (*http.Client, error) {
+	sock, err := desktop.BuildServerAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		network, addr, ok := strings.Cut(sock, "://")
+		if !ok {
+			return nil, errors.Errorf("invalid endpoint address: %s", sock)
+		}
+		return remoteutil.DialContext(ctx, network, addr)
+	}
+
+	return &http.Client{
+		Transport: tr,
+	}, nil
+}
+
+func openImported(dockerCli command.Cli, urls []string) error {
 	if len(urls) == 0 {
 		return errors.New("no build records found in the bundle")
 	}
 
+	var err error
 	for i, url := range urls {
 		fmt.Fprintln(dockerCli.Err(), url)
 		if i == 0 {
@@ -131,6 +252,9 @@ func importCmd(dockerCli command.Cli, _ RootOptions) *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringArrayVarP(&options.file, "file", "f", nil, "Import from a file path")
+	flags.StringArrayVar(&options.refs, "ref", nil, "Import only the build record with this ID")
+	flags.StringArrayVar(&options.filters, "filter", nil, `Import only the build records matching the filter (e.g., "status=error")`)
+	flags.BoolVar(&options.list, "list", false, "List the build records in the bundle without importing them")
 
 	return cmd
 }
@@ -61,6 +61,7 @@ type inspectOptions struct {
 	builder string
 	ref     string
 	format  string
+	analyze bool
 }
 
 type inspectOutput struct {
@@ -82,6 +83,7 @@ type inspectOutput struct {
 	Duration    time.Duration `json:",omitempty"`
 	Status      statusT       `json:",omitempty"`
 	Error       *errorOutput  `json:",omitempty"`
+	Pinned      bool          `json:",omitempty"`
 
 	NumCompletedSteps int32
 	NumTotalSteps     int32
@@ -96,6 +98,8 @@ type inspectOutput struct {
 	Attachments []attachmentOutput `json:",omitempty"`
 
 	Errors []string `json:",omitempty"`
+
+	Analysis *analysisOutput `json:",omitempty"`
 }
 
 type configOutput struct {
@@ -210,10 +214,21 @@ workers0:
 	}
 	st, _ := ls.ReadRef(rec.node.Builder, rec.node.Name, rec.Ref)
 
+	// analyze before the attributes are consumed below
+	var analysis *analysisOutput
+	if opts.analyze {
+		analysis, err = analyzeRecord(ctx, ls, rec, nodes)
+		if err != nil {
+			return errors.Wrap(err, "failed to analyze build")
+		}
+	}
+
 	attrs := rec.FrontendAttrs
 	delete(attrs, "frontend.caps")
 
-	var out inspectOutput
+	out := inspectOutput{
+		Analysis: analysis,
+	}
 
 	var context string
 	var dockerfile string
@@ -356,6 +371,8 @@ workers0:
 		}
 	}
 
+	out.Pinned = rec.Pinned
+
 	out.NumCompletedSteps = rec.NumCompletedSteps
 	out.NumTotalSteps = rec.NumTotalSteps
 	out.NumCachedSteps = rec.NumCachedSteps
@@ -430,34 +447,9 @@ workers0:
 		return err
 	}
 
-	provIndex := slices.IndexFunc(attachments, func(a attachment) bool {
-		return strings.HasPrefix(descrType(a.descr), "https://slsa.dev/provenance/")
-	})
-	if provIndex != -1 {
-		prov := attachments[provIndex]
-		predType := descrType(prov.descr)
-		dt, err := content.ReadBlob(ctx, store, prov.descr)
-		if err != nil {
-			return errors.Errorf("failed to read provenance %s: %v", prov.descr.Digest, err)
-		}
-		var pred *provenancetypes.ProvenancePredicateSLSA1
-		if predType == slsa02.PredicateSLSAProvenance {
-			var pred02 *provenancetypes.ProvenancePredicateSLSA02
-			if err := json.Unmarshal(dt, &pred02); err != nil {
-				return errors.Errorf("failed to unmarshal provenance %s: %v", prov.descr.Digest, err)
-			}
-			pred = pred02.ConvertToSLSA1()
-		} else if err := json.Unmarshal(dt, &pred); err != nil {
-			return errors.Errorf("failed to unmarshal provenance %s: %v", prov.descr.Digest, err)
-		}
-		if pred != nil {
-			for _, m := range pred.BuildDefinition.ResolvedDependencies {
-				out.Materials = append(out.Materials, materialOutput{
-					URI:     m.URI,
-					Digests: digestSetToDigests(m.Digest),
-				})
-			}
-		}
+	out.Materials, err = readMaterials(ctx, store, attachments)
+	if err != nil {
+		return err
 	}
 
 	if len(attachments) > 0 {
@@ -545,6 +537,9 @@ workers0:
 	case statusCanceled:
 		fmt.Fprintf(tw, "Status:\tCanceled\n")
 	}
+	if out.Pinned {
+		fmt.Fprintf(tw, "Pinned:\ttrue\n")
+	}
 
 	fmt.Fprintf(tw, "Build Steps:\t%d/%d (%.0f%% cached)\n", out.NumCompletedSteps, out.NumTotalSteps, float64(out.NumCachedSteps)/float64(out.NumTotalSteps)*100)
 	tw.Flush()
@@ -672,6 +667,10 @@ workers0:
 		}
 	}
 
+	if out.Analysis != nil {
+		printAnalysis(dockerCli.Out(), out.Analysis)
+	}
+
 	fmt.Fprintf(dockerCli.Out(), "Print build logs: docker buildx history logs %s\n", rec.Ref)
 
 	fmt.Fprintf(dockerCli.Out(), "View build in Docker Desktop: %s\n", desktop.BuildURL(fmt.Sprintf("%s/%s/%s", rec.node.Builder, rec.node.Name, rec.Ref)))
@@ -703,6 +702,7 @@ func inspectCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringVar(&options.format, "format", formatter.PrettyFormatKey, "Format the output")
+	flags.BoolVar(&options.analyze, "analyze", false, "Analyze the critical path and cache misses of the build")
 
 	return cmd
 }
@@ -827,6 +827,44 @@ func allAttachments(ctx context.Context, store content.Store, rec historyRecord)
 	return attachments, nil
 }
 
+// readMaterials returns the resolved dependencies of the build from the
+// provenance attestation, if any.
+func readMaterials(ctx context.Context, store content.Store, attachments []attachment) ([]materialOutput, error) {
+	provIndex := slices.IndexFunc(attachments, func(a attachment) bool {
+		return strings.HasPrefix(descrType(a.descr), "https://slsa.dev/provenance/")
+	})
+	if provIndex == -1 {
+		return nil, nil
+	}
+	prov := attachments[provIndex]
+	predType := descrType(prov.descr)
+	dt, err := content.ReadBlob(ctx, store, prov.descr)
+	if err != nil {
+		return nil, errors.Errorf("failed to read provenance %s: %v", prov.descr.Digest, err)
+	}
+	var pred *provenancetypes.ProvenancePredicateSLSA1
+	if predType == slsa02.PredicateSLSAProvenance {
+		var pred02 *provenancetypes.ProvenancePredicateSLSA02
+		if err := json.Unmarshal(dt, &pred02); err != nil {
+			return nil, errors.Errorf("failed to unmarshal provenance %s: %v", prov.descr.Digest, err)
+		}
+		pred = pred02.ConvertToSLSA1()
+	} else if err := json.Unmarshal(dt, &pred); err != nil {
+		return nil, errors.Errorf("failed to unmarshal provenance %s: %v", prov.descr.Digest, err)
+	}
+	if pred == nil {
+		return nil, nil
+	}
+	var out []materialOutput
+	for _, m := range pred.BuildDefinition.ResolvedDependencies {
+		out = append(out, materialOutput{
+			URI:     m.URI,
+			Digests: digestSetToDigests(m.Digest),
+		})
+	}
+	return out, nil
+}
+
 func walkAttachments(ctx context.Context, store content.Store, desc ocispecs.Descriptor, platform *ocispecs.Platform) []attachment {
 	_, err := store.Info(ctx, desc.Digest)
 	if err != nil {
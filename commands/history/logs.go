@@ -4,7 +4,9 @@ import (
 	"context"
 	"io"
 	"os"
+	"strings"
 
+	"github.com/docker/buildx/builder"
 	"github.com/docker/buildx/util/cobrautil/completion"
 	"github.com/docker/buildx/util/progress"
 	"github.com/docker/cli/cli/command"
@@ -19,6 +21,7 @@ type logsOptions struct {
 	builder  string
 	ref      string
 	progress string
+	follow   bool
 }
 
 func runLogs(ctx context.Context, dockerCli command.Cli, opts logsOptions) error {
@@ -27,19 +30,26 @@ func runLogs(ctx context.Context, dockerCli command.Cli, opts logsOptions) error
 		return err
 	}
 
-	recs, err := queryRecords(ctx, opts.ref, nodes, nil)
-	if err != nil {
-		return err
-	}
-
-	if len(recs) == 0 {
-		if opts.ref == "" {
-			return errors.New("no records found")
+	var rec *historyRecord
+	if opts.follow {
+		rec, err = followRecord(ctx, opts.ref, nodes)
+		if err != nil {
+			return err
+		}
+	} else {
+		recs, err := queryRecords(ctx, opts.ref, nodes, nil)
+		if err != nil {
+			return err
 		}
-		return errors.Errorf("no record found for ref %q", opts.ref)
+		if len(recs) == 0 {
+			if opts.ref == "" {
+				return errors.New("no records found")
+			}
+			return errors.Errorf("no record found for ref %q", opts.ref)
+		}
+		rec = &recs[0]
 	}
 
-	rec := &recs[0]
 	c, err := rec.node.Driver.Client(ctx)
 	if err != nil {
 		return err
@@ -53,7 +63,7 @@ func runLogs(ctx context.Context, dockerCli command.Cli, opts logsOptions) error
 	}
 
 	mode := progressui.DisplayMode(opts.progress)
-	if mode == progressui.AutoMode {
+	if mode == progressui.AutoMode && !opts.follow {
 		mode = progressui.PlainMode
 	}
 	printer, err := progress.NewPrinter(context.TODO(), os.Stderr, mode)
@@ -82,6 +92,41 @@ loop0:
 	return printer.Wait()
 }
 
+// followRecord returns the record of the build to follow. Without ref, it is
+// the most recent build in progress. If the build has not started yet, it
+// waits for it.
+func followRecord(ctx context.Context, ref string, nodes []builder.Node) (*historyRecord, error) {
+	if strings.HasPrefix(ref, "^") {
+		return nil, errors.Errorf("offset %q cannot be followed, use a build ID", ref)
+	}
+	recs, err := queryRecords(ctx, ref, nodes, &queryOptions{ActiveOnly: ref == ""})
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) > 0 {
+		return &recs[0], nil
+	}
+
+	var rec *historyRecord
+	err = watchRecords(ctx, ref, nodes, &queryOptions{ActiveOnly: true}, func(r historyRecord, typ controlapi.BuildHistoryEventType) error {
+		if typ != controlapi.BuildHistoryEventType_STARTED {
+			return nil
+		}
+		rec = &r
+		return errStopWatch
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		if err := context.Cause(ctx); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("build history stream closed before the build started")
+	}
+	return rec, nil
+}
+
 func logsCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
 	var options logsOptions
 
@@ -101,7 +146,8 @@ func logsCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
 	}
 
 	flags := cmd.Flags()
-	flags.StringVar(&options.progress, "progress", "plain", "Set type of progress output (plain, rawjson, tty)")
+	flags.StringVar(&options.progress, "progress", "plain", "Set type of progress output (auto, plain, rawjson, tty)")
+	flags.BoolVar(&options.follow, "follow", false, "Stream the logs of a build in progress, waiting for it to start if needed")
 
 	return cmd
 }
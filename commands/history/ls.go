@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/containerd/console"
+	"github.com/docker/buildx/builder"
 	"github.com/docker/buildx/localstate"
 	"github.com/docker/buildx/util/cobrautil/completion"
 	"github.com/docker/buildx/util/confutil"
@@ -20,12 +21,14 @@ import (
 	"github.com/docker/cli/cli/command"
 	"github.com/docker/cli/cli/command/formatter"
 	"github.com/docker/go-units"
+	controlapi "github.com/moby/buildkit/api/services/control"
 	bkgitutil "github.com/moby/buildkit/util/gitutil"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 const (
+	lsHeaderBuilder  = "BUILDER"
 	lsHeaderBuildID  = "BUILD ID"
 	lsHeaderName     = "NAME"
 	lsHeaderStatus   = "STATUS"
@@ -34,21 +37,38 @@ const (
 	lsHeaderLink     = ""
 
 	lsDefaultTableFormat = "table {{.Ref}}\t{{.Name}}\t{{.Status}}\t{{.CreatedAt}}\t{{.Duration}}\t{{.Link}}"
+	// lsAllBuildersTableFormat is the default table format with --all-builders
+	lsAllBuildersTableFormat = "table {{.Builder}}\t{{.Ref}}\t{{.Name}}\t{{.Status}}\t{{.CreatedAt}}\t{{.Duration}}\t{{.Link}}"
 
 	headerKeyTimestamp = "buildkit-current-timestamp"
+
+	// lsWatchTableFormat uses fixed width columns since the records are
+	// printed as they are received
+	lsWatchTableFormat = "%-25s  %-36s  %-9s  %-16s  %s\n"
+	// lsWatchAllBuildersTableFormat adds the builder column with
+	// --all-builders, as the records of all builders are interleaved
+	lsWatchAllBuildersTableFormat = "%-20s  " + lsWatchTableFormat
 )
 
 type lsOptions struct {
-	builder string
-	format  string
-	noTrunc bool
+	builder     string
+	allBuilders bool
+	format      string
+	noTrunc     bool
 
 	filters []string
 	local   bool
+	watch   bool
 }
 
 func runLs(ctx context.Context, dockerCli command.Cli, opts lsOptions) error {
-	nodes, err := loadNodes(ctx, dockerCli, opts.builder)
+	var nodes []builder.Node
+	var err error
+	if opts.allBuilders {
+		nodes, err = loadAllNodes(ctx, dockerCli)
+	} else {
+		nodes, err = loadNodes(ctx, dockerCli, opts.builder)
+	}
 	if err != nil {
 		return err
 	}
@@ -56,31 +76,24 @@ func runLs(ctx context.Context, dockerCli command.Cli, opts lsOptions) error {
 	queryOptions := &queryOptions{}
 
 	if opts.local {
-		wd, err := os.Getwd()
+		filter, err := localRepositoryFilter(ctx)
 		if err != nil {
 			return err
 		}
-		gitc, err := gitutil.New(bkgitutil.WithDir(wd))
-		if err != nil {
-			if st, err1 := os.Stat(path.Join(wd, ".git")); err1 == nil && st.IsDir() {
-				return errors.Wrap(err, "git was not found in the system")
-			}
-			return errors.Wrapf(err, "could not find git repository for local filter")
-		}
-		remote, err := gitc.RemoteURL(ctx)
-		if err != nil {
-			return errors.Wrapf(err, "could not get remote URL for local filter")
-		}
-		queryOptions.Filters = append(queryOptions.Filters, fmt.Sprintf("repository=%s", remote))
+		queryOptions.Filters = append(queryOptions.Filters, filter)
 	}
 	queryOptions.Filters = append(queryOptions.Filters, opts.filters...)
 
-	out, err := queryRecords(ctx, "", nodes, queryOptions)
+	ls, err := localstate.New(confutil.NewConfig(dockerCli))
 	if err != nil {
 		return err
 	}
 
-	ls, err := localstate.New(confutil.NewConfig(dockerCli))
+	if opts.watch {
+		return lsWatch(ctx, dockerCli, nodes, ls, queryOptions, opts)
+	}
+
+	out, err := queryRecords(ctx, "", nodes, queryOptions)
 	if err != nil {
 		return err
 	}
@@ -94,6 +107,27 @@ func runLs(ctx context.Context, dockerCli command.Cli, opts lsOptions) error {
 	return lsPrint(dockerCli, out, opts)
 }
 
+// localRepositoryFilter returns the filter matching the records built from
+// the git repository of the working directory.
+func localRepositoryFilter(ctx context.Context) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	gitc, err := gitutil.New(bkgitutil.WithDir(wd))
+	if err != nil {
+		if st, err1 := os.Stat(path.Join(wd, ".git")); err1 == nil && st.IsDir() {
+			return "", errors.Wrap(err, "git was not found in the system")
+		}
+		return "", errors.Wrapf(err, "could not find git repository for local filter")
+	}
+	remote, err := gitc.RemoteURL(ctx)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not get remote URL for local filter")
+	}
+	return fmt.Sprintf("repository=%s", remote), nil
+}
+
 func lsCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
 	var options lsOptions
 
@@ -102,6 +136,9 @@ func lsCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
 		Short: "List build records",
 		Args:  cli.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.allBuilders && cmd.Flags().Changed("builder") {
+				return errors.New("cannot specify --builder when using --all-builders")
+			}
 			options.builder = *rootOpts.Builder
 			return runLs(cmd.Context(), dockerCli, options)
 		},
@@ -111,9 +148,11 @@ func lsCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringVar(&options.format, "format", formatter.TableFormatKey, "Format the output")
+	flags.BoolVar(&options.allBuilders, "all-builders", false, "List records of all running builders")
 	flags.BoolVar(&options.noTrunc, "no-trunc", false, "Don't truncate output")
 	flags.StringArrayVar(&options.filters, "filter", nil, `Provide filter values (e.g., "status=error")`)
 	flags.BoolVar(&options.local, "local", false, "List records for current repository only")
+	flags.BoolVar(&options.watch, "watch", false, "Print records as builds start and complete until interrupted")
 
 	return cmd
 }
@@ -121,6 +160,9 @@ func lsCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
 func lsPrint(dockerCli command.Cli, records []historyRecord, in lsOptions) error {
 	if in.format == formatter.TableFormatKey {
 		in.format = lsDefaultTableFormat
+		if in.allBuilders {
+			in.format = lsAllBuildersTableFormat
+		}
 	}
 
 	ctx := formatter.Context{
@@ -162,6 +204,7 @@ func lsPrint(dockerCli command.Cli, records []historyRecord, in lsOptions) error
 		trunc:  !in.noTrunc,
 	}
 	lsCtx.Header = formatter.SubHeaderContext{
+		"Builder":   lsHeaderBuilder,
 		"Ref":       lsHeaderBuildID,
 		"Name":      lsHeaderName,
 		"Status":    lsHeaderStatus,
@@ -173,6 +216,64 @@ func lsPrint(dockerCli command.Cli, records []historyRecord, in lsOptions) error
 	return ctx.Write(&lsCtx, render)
 }
 
+// lsWatch prints the records of the history events as they are received. The
+// records are printed one per line as the columns of the table can't be
+// aligned in advance.
+func lsWatch(ctx context.Context, dockerCli command.Cli, nodes []builder.Node, ls *localstate.LocalState, opts *queryOptions, in lsOptions) error {
+	format := in.format
+	if format == formatter.TableFormatKey {
+		fmt.Fprint(dockerCli.Out(), lsWatchRow(in.allBuilders, lsHeaderBuilder, lsHeaderBuildID, lsHeaderName, lsHeaderStatus, lsHeaderCreated, lsHeaderDuration))
+	} else if formatter.Format(format).IsTable() {
+		return errors.New("table formats are not supported with --watch")
+	}
+
+	var term bool
+	if _, err := console.ConsoleFromFile(os.Stdout); err == nil {
+		term = true
+	}
+
+	err := watchRecords(ctx, "", nodes, opts, func(rec historyRecord, _ controlapi.BuildHistoryEventType) error {
+		st, _ := ls.ReadRef(rec.node.Builder, rec.node.Name, rec.Ref)
+		rec.name = historyutil.BuildName(rec.FrontendAttrs, st)
+		c := &lsContext{
+			format: formatter.Format(format),
+			isTerm: term,
+			trunc:  !in.noTrunc,
+			record: &rec,
+		}
+		if format == formatter.TableFormatKey {
+			name := c.record.name
+			if c.trunc {
+				name = trimBeginning(name, 36)
+			}
+			_, err := fmt.Fprint(dockerCli.Out(), lsWatchRow(in.allBuilders, c.Builder(), c.Ref(), name, c.Status(), c.CreatedAt(), c.Duration()))
+			return err
+		}
+		fctx := formatter.Context{
+			Output: dockerCli.Out(),
+			Format: formatter.Format(format),
+			Trunc:  !in.noTrunc,
+		}
+		return fctx.Write(c, func(format func(subContext formatter.SubContext) error) error {
+			return format(c)
+		})
+	})
+	if err != nil && ctx.Err() != nil {
+		// interrupted
+		return nil
+	}
+	return err
+}
+
+// lsWatchRow returns a line of the table printed with --watch. The builder
+// column is only printed with --all-builders.
+func lsWatchRow(allBuilders bool, builder, ref, name, status, createdAt, duration string) string {
+	if allBuilders {
+		return fmt.Sprintf(lsWatchAllBuildersTableFormat, builder, ref, name, status, createdAt, duration)
+	}
+	return fmt.Sprintf(lsWatchTableFormat, ref, name, status, createdAt, duration)
+}
+
 type lsContext struct {
 	formatter.HeaderContext
 
@@ -185,6 +286,7 @@ type lsContext struct {
 func (c *lsContext) MarshalJSON() ([]byte, error) {
 	m := map[string]any{
 		"ref":             c.FullRef(),
+		"builder":         c.Builder(),
 		"name":            c.Name(),
 		"status":          c.Status(),
 		"created_at":      c.record.CreatedAt.AsTime().Format(time.RFC3339Nano),
@@ -198,6 +300,10 @@ func (c *lsContext) MarshalJSON() ([]byte, error) {
 	return json.Marshal(m)
 }
 
+func (c *lsContext) Builder() string {
+	return c.record.node.Builder
+}
+
 func (c *lsContext) Ref() string {
 	return c.record.Ref
 }
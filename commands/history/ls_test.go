@@ -0,0 +1,32 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLsWatchRow(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		allBuilders bool
+		expected    string
+	}{
+		{
+			name:     "single builder",
+			expected: "qf1zvbrekwsdsjaxfqbb2ns5f  app                                   Completed  2 minutes ago     1.2s\n",
+		},
+		{
+			name:        "all builders",
+			allBuilders: true,
+			expected:    "builder1              qf1zvbrekwsdsjaxfqbb2ns5f  app                                   Completed  2 minutes ago     1.2s\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, lsWatchRow(tc.allBuilders, "builder1", "qf1zvbrekwsdsjaxfqbb2ns5f", "app", "Completed", "2 minutes ago", "1.2s"))
+		})
+	}
+
+	header := lsWatchRow(true, lsHeaderBuilder, lsHeaderBuildID, lsHeaderName, lsHeaderStatus, lsHeaderCreated, lsHeaderDuration)
+	require.Equal(t, "BUILDER               BUILD ID                   NAME                                  STATUS     CREATED AT        DURATION\n", header)
+}
@@ -0,0 +1,74 @@
+package history
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type pinOptions struct {
+	builder string
+	refs    []string
+	unpin   bool
+}
+
+func runPin(ctx context.Context, dockerCli command.Cli, opts pinOptions) error {
+	nodes, err := loadNodes(ctx, dockerCli, opts.builder)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range opts.refs {
+		recs, err := queryRecords(ctx, ref, nodes, &queryOptions{
+			CompletedOnly: true,
+		})
+		if err != nil {
+			return err
+		}
+		if len(recs) == 0 {
+			return errors.Errorf("no completed record found for ref %q", ref)
+		}
+		rec := recs[0]
+
+		c, err := rec.node.Driver.Client(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := c.ControlClient().UpdateBuildHistory(ctx, &controlapi.UpdateBuildHistoryRequest{
+			Ref:    rec.Ref,
+			Pinned: !opts.unpin,
+		}); err != nil {
+			return errors.Wrapf(err, "failed to update record %s", rec.Ref)
+		}
+		fmt.Fprintln(dockerCli.Out(), rec.Ref)
+	}
+	return nil
+}
+
+func pinCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
+	var options pinOptions
+
+	cmd := &cobra.Command{
+		Use:   "pin [OPTIONS] REF [REF...]",
+		Short: "Pin build records to protect them from pruning",
+		Args:  cli.RequiresMinArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.refs = args
+			options.builder = *rootOpts.Builder
+			return runPin(cmd.Context(), dockerCli, options)
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&options.unpin, "unpin", false, "Unpin the build records")
+
+	return cmd
+}
@@ -0,0 +1,137 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+type pruneOptions struct {
+	builder      string
+	filters      []string
+	keepDuration time.Duration
+	keepLast     int
+	keepPinned   bool
+	dryRun       bool
+}
+
+func runPrune(ctx context.Context, dockerCli command.Cli, opts pruneOptions) error {
+	if len(opts.filters) == 0 && opts.keepDuration == 0 && opts.keepLast == 0 {
+		return errors.New("prune requires at least one of --filter, --keep-duration or --keep-last")
+	}
+	if opts.keepDuration < 0 {
+		return errors.Errorf("invalid keep-duration %s", opts.keepDuration)
+	}
+	if opts.keepLast < 0 {
+		return errors.Errorf("invalid keep-last %d", opts.keepLast)
+	}
+
+	nodes, err := loadNodes(ctx, dockerCli, opts.builder)
+	if err != nil {
+		return err
+	}
+
+	recs, err := queryRecords(ctx, "", nodes, &queryOptions{
+		CompletedOnly: true,
+		Filters:       opts.filters,
+		Limit:         -1,
+	})
+	if err != nil {
+		return err
+	}
+
+	prune := pruneRecords(recs, opts, time.Now())
+
+	if !opts.dryRun {
+		eg, ctx := errgroup.WithContext(ctx)
+		for _, rec := range prune {
+			eg.Go(func() error {
+				c, err := rec.node.Driver.Client(ctx)
+				if err != nil {
+					return err
+				}
+				_, err = c.ControlClient().UpdateBuildHistory(ctx, &controlapi.UpdateBuildHistoryRequest{
+					Ref:    rec.Ref,
+					Delete: true,
+				})
+				return errors.Wrapf(err, "failed to remove record %s", rec.Ref)
+			})
+		}
+		if err := eg.Wait(); err != nil {
+			return err
+		}
+	}
+
+	if len(prune) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(dockerCli.Out(), 1, 8, 1, '\t', 0)
+	if opts.dryRun {
+		fmt.Fprintln(tw, "Would remove:")
+	} else {
+		fmt.Fprintln(tw, "Removed:")
+	}
+	for _, rec := range prune {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", rec.Ref, recordStatus(rec.BuildHistoryRecord), rec.CreatedAt.AsTime().Local().Format(time.DateTime))
+	}
+	fmt.Fprintf(tw, "Total:\t%d\n", len(prune))
+	return tw.Flush()
+}
+
+// pruneRecords returns the records that are not retained by the keep options.
+// The records must be sorted by creation time, the most recent first.
+func pruneRecords(recs []historyRecord, opts pruneOptions, now time.Time) []historyRecord {
+	var out []historyRecord
+	counts := map[[2]string]int{}
+	for _, rec := range recs {
+		if opts.keepPinned && rec.Pinned {
+			continue
+		}
+		if opts.keepLast > 0 {
+			key := [2]string{recordRepository(rec.BuildHistoryRecord), rec.FrontendAttrs["target"]}
+			counts[key]++
+			if counts[key] <= opts.keepLast {
+				continue
+			}
+		}
+		if opts.keepDuration > 0 && rec.CreatedAt.AsTime().After(now.Add(-opts.keepDuration)) {
+			continue
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+func pruneCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
+	var options pruneOptions
+
+	cmd := &cobra.Command{
+		Use:   "prune [OPTIONS]",
+		Short: "Remove build records matching a retention policy",
+		Args:  cli.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *rootOpts.Builder
+			return runPrune(cmd.Context(), dockerCli, options)
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVar(&options.filters, "filter", nil, `Only remove records matching the filter (e.g., "status=error")`)
+	flags.DurationVar(&options.keepDuration, "keep-duration", 0, "Keep records created within this duration")
+	flags.IntVar(&options.keepLast, "keep-last", 0, "Keep the N most recent records for each repository and target")
+	flags.BoolVar(&options.keepPinned, "keep-pinned", true, "Keep pinned records")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "Print the records that would be removed without removing them")
+
+	return cmd
+}
@@ -0,0 +1,111 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestPruneRecords(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	type rec struct {
+		ref    string
+		repo   string
+		target string
+		age    time.Duration
+		pinned bool
+	}
+	// records are sorted by creation time, the most recent first
+	recs := []rec{
+		{ref: "r1", repo: "https://github.com/docker/buildx.git", target: "binaries", age: time.Hour},
+		{ref: "r2", repo: "https://github.com/docker/buildx.git", target: "lint", age: 2 * time.Hour},
+		{ref: "r3", repo: "https://github.com/docker/buildx.git", target: "binaries", age: 24 * time.Hour, pinned: true},
+		{ref: "r4", repo: "https://github.com/docker/buildx.git", target: "binaries", age: 48 * time.Hour},
+		{ref: "r5", repo: "https://github.com/moby/buildkit.git", target: "binaries", age: 72 * time.Hour},
+		{ref: "r6", target: "binaries", age: 96 * time.Hour},
+		{ref: "r7", age: 120 * time.Hour},
+	}
+	records := make([]historyRecord, 0, len(recs))
+	for _, r := range recs {
+		attrs := map[string]string{}
+		if r.repo != "" {
+			attrs["vcs:source"] = r.repo
+		}
+		if r.target != "" {
+			attrs["target"] = r.target
+		}
+		records = append(records, historyRecord{
+			BuildHistoryRecord: &controlapi.BuildHistoryRecord{
+				Ref:           r.ref,
+				FrontendAttrs: attrs,
+				CreatedAt:     timestamppb.New(now.Add(-r.age)),
+				Pinned:        r.pinned,
+			},
+		})
+	}
+
+	tests := []struct {
+		name     string
+		opts     pruneOptions
+		expected []string
+	}{
+		{
+			name:     "no keep options",
+			expected: []string{"r1", "r2", "r3", "r4", "r5", "r6", "r7"},
+		},
+		{
+			name:     "keep pinned",
+			opts:     pruneOptions{keepPinned: true},
+			expected: []string{"r1", "r2", "r4", "r5", "r6", "r7"},
+		},
+		{
+			name:     "keep last",
+			opts:     pruneOptions{keepLast: 1},
+			expected: []string{"r3", "r4"},
+		},
+		{
+			name:     "keep last 2",
+			opts:     pruneOptions{keepLast: 2},
+			expected: []string{"r4"},
+		},
+		{
+			// pinned records do not count towards the last records kept
+			name:     "keep last and pinned",
+			opts:     pruneOptions{keepLast: 1, keepPinned: true},
+			expected: []string{"r4"},
+		},
+		{
+			name:     "keep duration",
+			opts:     pruneOptions{keepDuration: 24 * time.Hour},
+			expected: []string{"r3", "r4", "r5", "r6", "r7"},
+		},
+		{
+			name:     "keep duration and pinned",
+			opts:     pruneOptions{keepDuration: 36 * time.Hour, keepPinned: true},
+			expected: []string{"r4", "r5", "r6", "r7"},
+		},
+		{
+			// a record is removed only if no keep option retains it
+			name:     "keep last and duration",
+			opts:     pruneOptions{keepLast: 1, keepDuration: 36 * time.Hour},
+			expected: []string{"r4"},
+		},
+		{
+			name: "keep all",
+			opts: pruneOptions{keepDuration: 200 * time.Hour},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var refs []string
+			for _, rec := range pruneRecords(records, tt.opts, now) {
+				refs = append(refs, rec.Ref)
+			}
+			require.Equal(t, tt.expected, refs)
+		})
+	}
+}
@@ -0,0 +1,531 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/containerd/platforms"
+	"github.com/docker/buildx/build"
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/localstate"
+	"github.com/docker/buildx/util/buildflags"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/confutil"
+	"github.com/docker/buildx/util/dockerutil"
+	"github.com/docker/buildx/util/dockerutil/dockerconfig"
+	"github.com/docker/buildx/util/progress"
+	"github.com/docker/buildx/util/urlutil"
+	"github.com/docker/cli/cli/command"
+	dockeropts "github.com/docker/cli/opts"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/session/auth/authprovider"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/moby/buildkit/util/progress/progressui"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tonistiigi/go-csvvalue"
+)
+
+// defaultProvenanceAttest is the provenance attestation added to every build
+// by default. It is not carried over as it is added again on rerun.
+const defaultProvenanceAttest = "mode=min,inline-only=true"
+
+type rerunOptions struct {
+	builder  string
+	ref      string
+	on       string
+	progress string
+	outputs  []string
+	push     bool
+	noCache  bool
+	dryRun   bool
+}
+
+func runRerun(ctx context.Context, dockerCli command.Cli, opts rerunOptions) error {
+	nodes, err := loadNodes(ctx, dockerCli, opts.builder)
+	if err != nil {
+		return err
+	}
+
+	recs, err := queryRecords(ctx, opts.ref, nodes, nil)
+	if err != nil {
+		return err
+	}
+	if len(recs) == 0 {
+		if opts.ref == "" {
+			return errors.New("no records found")
+		}
+		return errors.Errorf("no record found for ref %q", opts.ref)
+	}
+	rec := &recs[0]
+
+	ls, err := localstate.New(confutil.NewConfig(dockerCli))
+	if err != nil {
+		return err
+	}
+	st, _ := ls.ReadRef(rec.node.Builder, rec.node.Name, rec.Ref)
+
+	bopts, missing, err := rerunBuildOptions(rec, st, opts.push)
+	if err != nil {
+		return err
+	}
+	if opts.noCache {
+		bopts.NoCache = true
+		bopts.NoCacheFilter = nil
+	}
+	if len(opts.outputs) > 0 {
+		entries, err := buildflags.ParseExports(opts.outputs)
+		if err != nil {
+			return err
+		}
+		bopts.Exports, bopts.ExportsLocalPathsTemporary, err = build.CreateExports(entries)
+		if err != nil {
+			return err
+		}
+		missing = slices.DeleteFunc(missing, func(s string) bool {
+			return strings.HasPrefix(s, "output ")
+		})
+	}
+
+	bopts.Session = append(bopts.Session, authprovider.NewDockerAuthProvider(authprovider.DockerAuthProviderConfig{
+		AuthConfigProvider: dockerconfig.LoadAuthConfig(dockerCli),
+	}))
+	if buildflags.IsGitSSH(bopts.Inputs.ContextPath) {
+		ssh, err := build.CreateSSH([]*buildflags.SSH{{ID: "default"}})
+		if err != nil {
+			return err
+		}
+		bopts.Session = append(bopts.Session, ssh)
+	}
+
+	printRerun(dockerCli.Err(), rec, bopts, missing)
+	if opts.dryRun {
+		return nil
+	}
+
+	builderName := opts.on
+	if builderName == "" {
+		builderName = rec.node.Builder
+	}
+	b, err := builder.New(dockerCli, builder.WithName(builderName))
+	if err != nil {
+		return err
+	}
+	buildNodes, err := b.LoadNodes(ctx)
+	if err != nil {
+		return err
+	}
+
+	printer, err := progress.NewPrinter(context.TODO(), os.Stderr, progressui.DisplayMode(opts.progress),
+		progress.WithDesc(
+			fmt.Sprintf("building with %q instance using %s driver", b.Name, b.Driver),
+			fmt.Sprintf("%s:%s", b.Driver, b.Name),
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = build.Build(ctx, buildNodes, map[string]build.Options{"default": *bopts}, dockerutil.NewClient(dockerCli), confutil.NewConfig(dockerCli), printer)
+	if err1 := printer.Wait(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// rerunBuildOptions reconstructs the options of a recorded build. The returned
+// list describes the parts of the build that could not be reconstructed.
+// Image outputs are only pushed again if push is set.
+func rerunBuildOptions(rec *historyRecord, st *localstate.State, push bool) (*build.Options, []string, error) {
+	attrs := maps.Clone(rec.FrontendAttrs)
+	var missing []string
+
+	opts := &build.Options{
+		BuildArgs: map[string]string{},
+		Labels:    map[string]string{},
+		Attests:   map[string]*string{},
+	}
+
+	in, ok, err := rerunInputs(attrs, st)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, errors.Errorf("build context of %s cannot be reconstructed: it is not available locally and the record has no git source", rec.Ref)
+	}
+	opts.Inputs = in
+	if strings.HasSuffix(attrs["vcs:revision"], "-dirty") && (st == nil || in.ContextPath != st.LocalPath) {
+		missing = append(missing, "uncommitted changes of the git source "+attrs["vcs:source"])
+	}
+
+	opts.Inputs.NamedContexts = map[string]build.NamedContext{}
+	for k, v := range attrs {
+		name, ok := strings.CutPrefix(k, "context:")
+		if !ok {
+			continue
+		}
+		if p, ok := rerunNamedContext(attrs, st, name, v); ok {
+			opts.Inputs.NamedContexts[name] = build.NamedContext{Path: p}
+		} else {
+			missing = append(missing, fmt.Sprintf("named context %q (%s)", name, v))
+		}
+	}
+
+	for k, v := range attrs {
+		switch {
+		case strings.HasPrefix(k, "build-arg:"):
+			opts.BuildArgs[strings.TrimPrefix(k, "build-arg:")] = v
+		case strings.HasPrefix(k, "label:"):
+			opts.Labels[strings.TrimPrefix(k, "label:")] = v
+		case strings.HasPrefix(k, "attest:"):
+			if k == "attest:provenance" && v == defaultProvenanceAttest {
+				continue
+			}
+			opts.Attests[strings.TrimPrefix(k, "attest:")] = &v
+		case isDerivedAttr(k):
+		default:
+			if err := setRerunAttr(opts, k, v); err != nil {
+				missing = append(missing, fmt.Sprintf("frontend attribute %s=%s (%v)", k, v, err))
+			}
+		}
+	}
+
+	for _, e := range rec.Exporters {
+		entry, ok := rerunExport(e.Type, e.Attrs)
+		if !ok {
+			missing = append(missing, fmt.Sprintf("output %q: destination is not recorded", e.Type))
+			continue
+		}
+		if v, ok := entry.Attrs["push"]; ok && !push {
+			delete(entry.Attrs, "push")
+			if pushed, _ := strconv.ParseBool(v); pushed {
+				missing = append(missing, fmt.Sprintf("output %q: push to %s, use --push to push the result", entry.Type, entry.Attrs["name"]))
+			}
+		}
+		opts.Exports = append(opts.Exports, entry)
+	}
+	if len(rec.Exporters) > 0 && len(opts.Exports) == 0 {
+		// do not fall back to the default output of the builder
+		opts.Exports = []client.ExportEntry{{Type: "cacheonly"}}
+	}
+
+	slices.Sort(missing)
+	return opts, missing, nil
+}
+
+// rerunInputs returns the build context and Dockerfile of the record. The
+// local context is used if it still exists, otherwise the context is
+// resolved from the git source of the build.
+func rerunInputs(attrs map[string]string, st *localstate.State) (build.Inputs, bool, error) {
+	if st != nil && st.LocalPath != "" && st.LocalPath != "-" {
+		if urlutil.IsRemoteURL(st.LocalPath) {
+			return build.Inputs{
+				ContextPath:    st.LocalPath,
+				DockerfilePath: st.DockerfilePath,
+			}, true, nil
+		}
+		if fi, err := os.Stat(st.LocalPath); err == nil && fi.IsDir() {
+			if st.DockerfilePath == "-" {
+				return build.Inputs{}, false, errors.New("Dockerfile was read from stdin and cannot be reconstructed")
+			}
+			if st.DockerfilePath != "" {
+				if _, err := os.Stat(st.DockerfilePath); err != nil {
+					return build.Inputs{}, false, errors.Wrap(err, "failed to find Dockerfile of the build")
+				}
+			}
+			return build.Inputs{
+				ContextPath:    st.LocalPath,
+				DockerfilePath: st.DockerfilePath,
+			}, true, nil
+		}
+	}
+
+	if v, ok := attrs["input:context"]; ok {
+		return build.Inputs{
+			ContextPath:    v,
+			DockerfilePath: attrs["filename"],
+		}, true, nil
+	}
+	if v, ok := attrs["context"]; ok && urlutil.IsRemoteURL(v) {
+		return build.Inputs{
+			ContextPath:    v,
+			DockerfilePath: attrs["filename"],
+		}, true, nil
+	}
+
+	src, rev := attrs["vcs:source"], strings.TrimSuffix(attrs["vcs:revision"], "-dirty")
+	if src == "" || rev == "" {
+		return build.Inputs{}, false, nil
+	}
+	ctxDir := attrs["vcs:localdir:context"]
+	u := src + "#" + rev
+	if ctxDir != "" && ctxDir != "." {
+		u += ":" + filepath.ToSlash(ctxDir)
+	}
+	dockerfile := attrs["filename"]
+	if dfDir, ok := attrs["vcs:localdir:dockerfile"]; ok && dockerfile != "" {
+		if ctxDir == "" {
+			ctxDir = "."
+		}
+		if rel, err := filepath.Rel(ctxDir, dfDir); err == nil && !strings.HasPrefix(rel, "..") {
+			dockerfile = path.Join(filepath.ToSlash(rel), dockerfile)
+		}
+	}
+	return build.Inputs{
+		ContextPath:    u,
+		DockerfilePath: dockerfile,
+	}, true, nil
+}
+
+// rerunNamedContext returns the source of a named context from its frontend
+// attribute value.
+func rerunNamedContext(attrs map[string]string, st *localstate.State, name, v string) (string, bool) {
+	switch {
+	case strings.HasPrefix(v, "input:"):
+		for _, k := range []string{"input:context:" + name, "input:git_state_" + name} {
+			if u, ok := attrs[k]; ok {
+				return u, true
+			}
+		}
+		return "", false
+	case strings.HasPrefix(v, "local:"):
+		// local named contexts are resolved relative to the git root of the
+		// main context when it is still available
+		localName := strings.TrimPrefix(v, "local:")
+		dir, ok := attrs["vcs:localdir:"+localName]
+		if !ok || st == nil || urlutil.IsRemoteURL(st.LocalPath) {
+			return "", false
+		}
+		ctxDir, ok := attrs["vcs:localdir:context"]
+		if !ok {
+			return "", false
+		}
+		root := filepath.Clean(st.LocalPath)
+		if ctxDir = filepath.Clean(ctxDir); ctxDir != "." {
+			var ok bool
+			if root, ok = strings.CutSuffix(root, string(filepath.Separator)+ctxDir); !ok {
+				return "", false
+			}
+		}
+		p := filepath.Join(root, dir)
+		if fi, err := os.Stat(p); err != nil || !fi.IsDir() {
+			return "", false
+		}
+		return p, true
+	case strings.HasPrefix(v, "oci-layout://"):
+		// OCI layouts are referenced by a random store ID
+		return "", false
+	}
+	return v, true
+}
+
+// isDerivedAttr returns true for the frontend attributes that are set by
+// buildx or BuildKit from other options and are not carried over.
+func isDerivedAttr(k string) bool {
+	switch k {
+	case "context", "filename", "dockerfilekey", "frontend.caps", "source", "cmdline", "multi-platform":
+		return true
+	}
+	for _, p := range []string{"context:", "input:", "input-metadata:", "local-sessionid:", "sharedkey:", "vcs:"} {
+		if strings.HasPrefix(k, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// setRerunAttr sets the build option corresponding to a frontend attribute.
+func setRerunAttr(opts *build.Options, k, v string) error {
+	switch k {
+	case "target":
+		opts.Target = v
+	case "platform":
+		for p := range strings.SplitSeq(v, ",") {
+			pp, err := platforms.Parse(p)
+			if err != nil {
+				return err
+			}
+			opts.Platforms = append(opts.Platforms, pp)
+		}
+	case "no-cache":
+		if v == "" {
+			opts.NoCache = true
+		} else {
+			opts.NoCacheFilter = strings.Split(v, ",")
+		}
+	case "image-resolve-mode":
+		opts.Pull = v == pb.AttrImageResolveModeForcePull
+	case "force-network-mode":
+		opts.NetworkMode = v
+	case "cgroup-parent":
+		opts.CgroupParent = v
+	case "add-hosts":
+		fields, err := csvvalue.Fields(v, nil)
+		if err != nil {
+			return err
+		}
+		opts.ExtraHosts = fields
+	case "shm-size":
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		opts.ShmSize = dockeropts.MemBytes(n)
+	case "ulimit":
+		opts.Ulimits = dockeropts.NewUlimitOpt(nil)
+		for u := range strings.SplitSeq(v, ",") {
+			if err := opts.Ulimits.Set(u); err != nil {
+				return err
+			}
+		}
+	case "memory", "memswap", "cpushares", "cpuperiod", "cpuquota":
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		switch k {
+		case "memory":
+			opts.ResourceLimits.Memory = dockeropts.MemBytes(n)
+		case "memswap":
+			opts.ResourceLimits.MemorySwap = dockeropts.MemSwapBytes(n)
+		case "cpushares":
+			opts.ResourceLimits.CPUShares = n
+		case "cpuperiod":
+			opts.ResourceLimits.CPUPeriod = n
+		case "cpuquota":
+			opts.ResourceLimits.CPUQuota = n
+		}
+	case "cpusetcpus":
+		opts.ResourceLimits.CPUSetCPUs = v
+	case "cpusetmems":
+		opts.ResourceLimits.CPUSetMems = v
+	default:
+		return errors.New("not supported")
+	}
+	return nil
+}
+
+// rerunExport returns the export entry of a recorded exporter. Exporters
+// writing to the client cannot be reconstructed as their destination is not
+// recorded, except for images loaded to the Docker daemon.
+func rerunExport(typ string, attrs map[string]string) (client.ExportEntry, bool) {
+	attrs = maps.Clone(attrs)
+	if attrs == nil {
+		attrs = map[string]string{}
+	}
+	_, loaded := attrs["prefer-image-digest"]
+	delete(attrs, "prefer-image-digest")
+	delete(attrs, "buildinfo-attrs")
+
+	switch typ {
+	case "moby":
+		typ = client.ExporterImage
+	case client.ExporterOCI:
+		if !loaded {
+			return client.ExportEntry{}, false
+		}
+		typ = client.ExporterDocker
+	case client.ExporterDocker:
+		if _, ok := attrs["dest"]; ok {
+			return client.ExportEntry{}, false
+		}
+	case client.ExporterLocal, client.ExporterTar:
+		return client.ExportEntry{}, false
+	}
+	return client.ExportEntry{Type: typ, Attrs: attrs}, true
+}
+
+func printRerun(w io.Writer, rec *historyRecord, opts *build.Options, missing []string) {
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "Rerunning:\t%s\n", rec.Ref)
+	fmt.Fprintf(tw, "Context:\t%s\n", opts.Inputs.ContextPath)
+	if opts.Inputs.DockerfilePath != "" {
+		fmt.Fprintf(tw, "Dockerfile:\t%s\n", opts.Inputs.DockerfilePath)
+	}
+	if opts.Target != "" {
+		fmt.Fprintf(tw, "Target:\t%s\n", opts.Target)
+	}
+	if len(opts.Platforms) > 0 {
+		pp := make([]string, 0, len(opts.Platforms))
+		for _, p := range opts.Platforms {
+			pp = append(pp, platforms.FormatAll(p))
+		}
+		fmt.Fprintf(tw, "Platforms:\t%s\n", strings.Join(pp, ", "))
+	}
+	for _, k := range slices.Sorted(maps.Keys(opts.Inputs.NamedContexts)) {
+		fmt.Fprintf(tw, "Named Context:\t%s=%s\n", k, opts.Inputs.NamedContexts[k].Path)
+	}
+	for _, k := range slices.Sorted(maps.Keys(opts.BuildArgs)) {
+		fmt.Fprintf(tw, "Build Arg:\t%s=%s\n", k, opts.BuildArgs[k])
+	}
+	if opts.NoCache {
+		fmt.Fprintf(tw, "No Cache:\ttrue\n")
+	}
+	for _, e := range opts.Exports {
+		fmt.Fprintf(tw, "Output:\t%s\n", formatExportEntry(e))
+	}
+	tw.Flush()
+
+	if len(missing) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Not reconstructed:")
+		for _, m := range missing {
+			fmt.Fprintf(w, " - %s\n", m)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Secrets and SSH agents are not recorded and are not forwarded to the build.")
+	fmt.Fprintln(w)
+}
+
+func formatExportEntry(e client.ExportEntry) string {
+	fields := []string{"type=" + e.Type}
+	for _, k := range slices.Sorted(maps.Keys(e.Attrs)) {
+		fields = append(fields, k+"="+e.Attrs[k])
+	}
+	if e.OutputDir != "" {
+		fields = append(fields, "dest="+e.OutputDir)
+	}
+	return strings.Join(fields, ",")
+}
+
+func rerunCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
+	var options rerunOptions
+
+	cmd := &cobra.Command{
+		Use:   "rerun [OPTIONS] [REF]",
+		Short: "Start a new build with the options of a build record",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				options.ref = args[0]
+			}
+			if options.push && len(options.outputs) > 0 {
+				return errors.New("cannot use --push with --output")
+			}
+			options.builder = *rootOpts.Builder
+			return runRerun(cmd.Context(), dockerCli, options)
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.on, "on", "", "Run the build on a different builder instance")
+	flags.BoolVar(&options.noCache, "no-cache", false, "Do not use cache when building the image")
+	flags.StringArrayVarP(&options.outputs, "output", "o", nil, `Override the outputs of the build (format: "type=local,dest=path")`)
+	flags.BoolVar(&options.push, "push", false, "Push the image outputs of the build to their registry")
+	flags.StringVar(&options.progress, "progress", "auto", "Set type of progress output (auto, plain, quiet, rawjson, tty)")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "Print the reconstructed build options without building")
+
+	return cmd
+}
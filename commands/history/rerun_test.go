@@ -0,0 +1,378 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/platforms"
+	"github.com/docker/buildx/build"
+	"github.com/docker/buildx/localstate"
+	dockeropts "github.com/docker/cli/opts"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/client"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRerunBuildOptions(t *testing.T) {
+	sbom := "generator=docker/buildkit-syft-scanner"
+	rec := &historyRecord{
+		BuildHistoryRecord: &controlapi.BuildHistoryRecord{
+			Ref: "qu2gsuo8ejqrwdfii23xkkckt",
+			FrontendAttrs: map[string]string{
+				"input:context":            "https://github.com/docker/buildx.git#main",
+				"filename":                 "Dockerfile",
+				"target":                   "binaries",
+				"platform":                 "linux/amd64",
+				"build-arg:VERSION":        "1.0",
+				"label:org.opencontainers": "buildx",
+				"attest:provenance":        defaultProvenanceAttest,
+				"attest:sbom":              sbom,
+				"context:base":             "docker-image://alpine",
+				"context:src":              "oci-layout://abcdef",
+				"vcs:source":               "https://github.com/docker/buildx.git",
+				"frontend.caps":            "moby.buildkit.frontend.inputs",
+				"hostname":                 "builder",
+			},
+			Exporters: []*controlapi.Exporter{
+				{Type: client.ExporterImage, Attrs: map[string]string{"name": "docker.io/docker/buildx:latest", "push": "true"}},
+				{Type: client.ExporterLocal, Attrs: map[string]string{}},
+			},
+		},
+	}
+
+	t.Run("without push", func(t *testing.T) {
+		opts, missing, err := rerunBuildOptions(rec, nil, false)
+		require.NoError(t, err)
+		require.Equal(t, build.Inputs{
+			ContextPath:    "https://github.com/docker/buildx.git#main",
+			DockerfilePath: "Dockerfile",
+			NamedContexts: map[string]build.NamedContext{
+				"base": {Path: "docker-image://alpine"},
+			},
+		}, opts.Inputs)
+		require.Equal(t, "binaries", opts.Target)
+		require.Equal(t, []ocispecs.Platform{platforms.MustParse("linux/amd64")}, opts.Platforms)
+		require.Equal(t, map[string]string{"VERSION": "1.0"}, opts.BuildArgs)
+		require.Equal(t, map[string]string{"org.opencontainers": "buildx"}, opts.Labels)
+		require.Equal(t, map[string]*string{"sbom": &sbom}, opts.Attests)
+		require.Equal(t, []client.ExportEntry{
+			{Type: client.ExporterImage, Attrs: map[string]string{"name": "docker.io/docker/buildx:latest"}},
+		}, opts.Exports)
+		require.Equal(t, []string{
+			"frontend attribute hostname=builder (not supported)",
+			`named context "src" (oci-layout://abcdef)`,
+			`output "image": push to docker.io/docker/buildx:latest, use --push to push the result`,
+			`output "local": destination is not recorded`,
+		}, missing)
+	})
+
+	t.Run("with push", func(t *testing.T) {
+		opts, missing, err := rerunBuildOptions(rec, nil, true)
+		require.NoError(t, err)
+		require.Equal(t, []client.ExportEntry{
+			{Type: client.ExporterImage, Attrs: map[string]string{"name": "docker.io/docker/buildx:latest", "push": "true"}},
+		}, opts.Exports)
+		require.NotContains(t, missing, `output "image": push to docker.io/docker/buildx:latest, use --push to push the result`)
+	})
+
+	t.Run("no reconstructed outputs", func(t *testing.T) {
+		rec := &historyRecord{
+			BuildHistoryRecord: &controlapi.BuildHistoryRecord{
+				FrontendAttrs: map[string]string{
+					"input:context": "https://github.com/docker/buildx.git",
+				},
+				Exporters: []*controlapi.Exporter{
+					{Type: client.ExporterTar},
+				},
+			},
+		}
+		opts, missing, err := rerunBuildOptions(rec, nil, false)
+		require.NoError(t, err)
+		require.Equal(t, []client.ExportEntry{{Type: "cacheonly"}}, opts.Exports)
+		require.Equal(t, []string{`output "tar": destination is not recorded`}, missing)
+	})
+
+	t.Run("no context", func(t *testing.T) {
+		rec := &historyRecord{
+			BuildHistoryRecord: &controlapi.BuildHistoryRecord{
+				Ref:           "qu2gsuo8ejqrwdfii23xkkckt",
+				FrontendAttrs: map[string]string{},
+			},
+		}
+		_, _, err := rerunBuildOptions(rec, nil, false)
+		require.ErrorContains(t, err, "build context of qu2gsuo8ejqrwdfii23xkkckt cannot be reconstructed")
+	})
+}
+
+func TestRerunInputs(t *testing.T) {
+	dir := t.TempDir()
+	dockerfile := filepath.Join(dir, "Dockerfile")
+	require.NoError(t, os.WriteFile(dockerfile, []byte("FROM scratch\n"), 0644))
+
+	gitAttrs := map[string]string{
+		"vcs:source":               "https://github.com/docker/buildx.git",
+		"vcs:revision":             "f15eaa1ee324ffbbab29605600d27a84cab86361-dirty",
+		"vcs:localdir:context":     "app",
+		"vcs:localdir:dockerfile":  "app/docker",
+		"filename":                 "Dockerfile",
+		"local-sessionid:context":  "abc",
+		"local-sessionid:dockerfi": "abc",
+	}
+
+	tests := []struct {
+		name     string
+		attrs    map[string]string
+		st       *localstate.State
+		expected build.Inputs
+		ok       bool
+		err      string
+	}{
+		{
+			name:     "local context",
+			attrs:    gitAttrs,
+			st:       &localstate.State{LocalPath: dir, DockerfilePath: dockerfile},
+			expected: build.Inputs{ContextPath: dir, DockerfilePath: dockerfile},
+			ok:       true,
+		},
+		{
+			name:     "remote context",
+			st:       &localstate.State{LocalPath: "https://github.com/docker/buildx.git", DockerfilePath: "Dockerfile"},
+			expected: build.Inputs{ContextPath: "https://github.com/docker/buildx.git", DockerfilePath: "Dockerfile"},
+			ok:       true,
+		},
+		{
+			name:  "dockerfile from stdin",
+			st:    &localstate.State{LocalPath: dir, DockerfilePath: "-"},
+			err:   "Dockerfile was read from stdin",
+			attrs: gitAttrs,
+		},
+		{
+			name:  "dockerfile removed",
+			st:    &localstate.State{LocalPath: dir, DockerfilePath: filepath.Join(dir, "missing.Dockerfile")},
+			err:   "failed to find Dockerfile of the build",
+			attrs: gitAttrs,
+		},
+		{
+			name:  "local context removed",
+			attrs: gitAttrs,
+			st:    &localstate.State{LocalPath: filepath.Join(dir, "missing"), DockerfilePath: dockerfile},
+			expected: build.Inputs{
+				ContextPath:    "https://github.com/docker/buildx.git#f15eaa1ee324ffbbab29605600d27a84cab86361:app",
+				DockerfilePath: "docker/Dockerfile",
+			},
+			ok: true,
+		},
+		{
+			name: "git source at root",
+			attrs: map[string]string{
+				"vcs:source":   "https://github.com/docker/buildx.git",
+				"vcs:revision": "f15eaa1ee324ffbbab29605600d27a84cab86361",
+				"filename":     "Dockerfile",
+			},
+			expected: build.Inputs{
+				ContextPath:    "https://github.com/docker/buildx.git#f15eaa1ee324ffbbab29605600d27a84cab86361",
+				DockerfilePath: "Dockerfile",
+			},
+			ok: true,
+		},
+		{
+			name: "input context",
+			attrs: map[string]string{
+				"input:context": "https://github.com/docker/buildx.git#main",
+				"filename":      "Dockerfile",
+			},
+			expected: build.Inputs{ContextPath: "https://github.com/docker/buildx.git#main", DockerfilePath: "Dockerfile"},
+			ok:       true,
+		},
+		{
+			name: "remote context attribute",
+			attrs: map[string]string{
+				"context": "https://example.com/context.tar.gz",
+			},
+			expected: build.Inputs{ContextPath: "https://example.com/context.tar.gz"},
+			ok:       true,
+		},
+		{
+			name: "local context attribute",
+			attrs: map[string]string{
+				"context": "/home/user/app",
+			},
+		},
+		{
+			name: "no source",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, ok, err := rerunInputs(tt.attrs, tt.st)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.expected, in)
+		})
+	}
+}
+
+func TestRerunNamedContext(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "app"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "libs"), 0755))
+
+	attrs := map[string]string{
+		"input:context:base":   "docker-image://alpine@sha256:1234",
+		"input:git_state_src":  "https://github.com/docker/buildx.git#main",
+		"vcs:localdir:context": "app",
+		"vcs:localdir:libs":    "libs",
+		"vcs:localdir:missing": "missing",
+	}
+	st := &localstate.State{LocalPath: filepath.Join(root, "app")}
+
+	tests := []struct {
+		name     string
+		value    string
+		st       *localstate.State
+		expected string
+		ok       bool
+	}{
+		{name: "base", value: "input:base", expected: "docker-image://alpine@sha256:1234", ok: true},
+		{name: "src", value: "input:src", expected: "https://github.com/docker/buildx.git#main", ok: true},
+		{name: "other", value: "input:other"},
+		{name: "image", value: "docker-image://alpine", expected: "docker-image://alpine", ok: true},
+		{name: "git", value: "https://github.com/docker/buildx.git", expected: "https://github.com/docker/buildx.git", ok: true},
+		{name: "layout", value: "oci-layout://abcdef@sha256:1234"},
+		{name: "libs", value: "local:libs", st: st, expected: filepath.Join(root, "libs"), ok: true},
+		{name: "libs", value: "local:libs"},
+		{name: "libs", value: "local:libs", st: &localstate.State{LocalPath: "https://github.com/docker/buildx.git"}},
+		{name: "libs", value: "local:libs", st: &localstate.State{LocalPath: filepath.Join(root, "other")}},
+		{name: "missing", value: "local:missing", st: st},
+		{name: "unknown", value: "local:unknown", st: st},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			p, ok := rerunNamedContext(attrs, tt.st, tt.name, tt.value)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.expected, p)
+		})
+	}
+}
+
+func TestSetRerunAttr(t *testing.T) {
+	tests := []struct {
+		key      string
+		value    string
+		expected build.Options
+		err      string
+	}{
+		{key: "target", value: "binaries", expected: build.Options{Target: "binaries"}},
+		{
+			key:   "platform",
+			value: "linux/amd64,linux/arm64",
+			expected: build.Options{Platforms: []ocispecs.Platform{
+				platforms.MustParse("linux/amd64"),
+				platforms.MustParse("linux/arm64"),
+			}},
+		},
+		{key: "platform", value: "linux/amd64,linux/", err: "invalid"},
+		{key: "no-cache", value: "", expected: build.Options{NoCache: true}},
+		{key: "no-cache", value: "build,test", expected: build.Options{NoCacheFilter: []string{"build", "test"}}},
+		{key: "image-resolve-mode", value: "pull", expected: build.Options{Pull: true}},
+		{key: "image-resolve-mode", value: "default"},
+		{key: "force-network-mode", value: "host", expected: build.Options{NetworkMode: "host"}},
+		{key: "cgroup-parent", value: "buildx", expected: build.Options{CgroupParent: "buildx"}},
+		{key: "add-hosts", value: `"docker:10.180.0.1","registry:10.180.0.2"`, expected: build.Options{ExtraHosts: []string{"docker:10.180.0.1", "registry:10.180.0.2"}}},
+		{key: "shm-size", value: "67108864", expected: build.Options{ShmSize: dockeropts.MemBytes(64 << 20)}},
+		{key: "shm-size", value: "64m", err: "invalid syntax"},
+		{key: "memory", value: "1073741824", expected: build.Options{ResourceLimits: build.ResourceLimits{Memory: dockeropts.MemBytes(1 << 30)}}},
+		{key: "cpuquota", value: "50000", expected: build.Options{ResourceLimits: build.ResourceLimits{CPUQuota: 50000}}},
+		{key: "cpusetcpus", value: "0-3", expected: build.Options{ResourceLimits: build.ResourceLimits{CPUSetCPUs: "0-3"}}},
+		{key: "hostname", value: "builder", err: "not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			var opts build.Options
+			err := setRerunAttr(&opts, tt.key, tt.value)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, opts)
+		})
+	}
+
+	t.Run("ulimit", func(t *testing.T) {
+		var opts build.Options
+		require.NoError(t, setRerunAttr(&opts, "ulimit", "nofile=1024:2048,nproc=512"))
+		require.Equal(t, "[nofile=1024:2048 nproc=512:512]", opts.Ulimits.String())
+	})
+}
+
+func TestRerunExport(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      string
+		attrs    map[string]string
+		expected client.ExportEntry
+		ok       bool
+	}{
+		{
+			name:     "image",
+			typ:      client.ExporterImage,
+			attrs:    map[string]string{"name": "docker.io/docker/buildx:latest", "push": "true", "buildinfo-attrs": "true"},
+			expected: client.ExportEntry{Type: client.ExporterImage, Attrs: map[string]string{"name": "docker.io/docker/buildx:latest", "push": "true"}},
+			ok:       true,
+		},
+		{
+			name:     "moby",
+			typ:      "moby",
+			attrs:    map[string]string{"name": "buildx:latest"},
+			expected: client.ExportEntry{Type: client.ExporterImage, Attrs: map[string]string{"name": "buildx:latest"}},
+			ok:       true,
+		},
+		{
+			name:     "loaded oci",
+			typ:      client.ExporterOCI,
+			attrs:    map[string]string{"name": "buildx:latest", "prefer-image-digest": "true"},
+			expected: client.ExportEntry{Type: client.ExporterDocker, Attrs: map[string]string{"name": "buildx:latest"}},
+			ok:       true,
+		},
+		{
+			name:  "oci",
+			typ:   client.ExporterOCI,
+			attrs: map[string]string{"name": "buildx:latest"},
+		},
+		{
+			name:     "loaded docker",
+			typ:      client.ExporterDocker,
+			expected: client.ExportEntry{Type: client.ExporterDocker, Attrs: map[string]string{}},
+			ok:       true,
+		},
+		{
+			name:  "docker to file",
+			typ:   client.ExporterDocker,
+			attrs: map[string]string{"dest": "/tmp/image.tar"},
+		},
+		{
+			name: "local",
+			typ:  client.ExporterLocal,
+		},
+		{
+			name: "tar",
+			typ:  client.ExporterTar,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := rerunExport(tt.typ, tt.attrs)
+			require.Equal(t, tt.ok, ok)
+			if ok {
+				require.Equal(t, tt.expected, entry)
+			}
+		})
+	}
+}
@@ -23,8 +23,13 @@ func RootCmd(rootcmd *cobra.Command, dockerCli command.Cli, opts RootOptions) *c
 	cmd.AddCommand(
 		lsCmd(dockerCli, opts),
 		rmCmd(dockerCli, opts),
+		pruneCmd(dockerCli, opts),
+		pinCmd(dockerCli, opts),
+		rerunCmd(dockerCli, opts),
 		logsCmd(dockerCli, opts),
 		inspectCmd(dockerCli, opts),
+		diffCmd(dockerCli, opts),
+		statsCmd(dockerCli, opts),
 		openCmd(dockerCli, opts),
 		traceCmd(dockerCli, opts),
 		importCmd(dockerCli, opts),
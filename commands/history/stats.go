@@ -0,0 +1,338 @@
+package history
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/docker/cli/cli/command/formatter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	statsDefaultLimit = 500
+	// statsConcurrency is the number of records whose steps are loaded in
+	// parallel.
+	statsConcurrency = 8
+	// statsNone is the group key of records without a value for the
+	// grouping field.
+	statsNone = "-"
+)
+
+var statsGroupBy = []string{"target", "repository", "platform", "status"}
+
+type statsOptions struct {
+	builder string
+	format  string
+	filters []string
+	local   bool
+	groupBy string
+	top     int
+	limit   int
+}
+
+type statsOutput struct {
+	Group string `json:",omitempty"`
+
+	Builds   int
+	Failed   int `json:",omitempty"`
+	Canceled int `json:",omitempty"`
+
+	DurationP50 time.Duration
+	DurationP90 time.Duration
+	DurationP95 time.Duration
+	DurationMax time.Duration
+
+	TotalSteps  int
+	CachedSteps int
+	// CacheRatio is the ratio of cached steps over all steps.
+	CacheRatio float64
+
+	SlowestSteps []statsStepOutput `json:",omitempty"`
+}
+
+type statsStepOutput struct {
+	Name string
+	// Runs is the number of builds where the step was executed.
+	Runs            int
+	DurationAverage time.Duration
+	DurationMax     time.Duration
+}
+
+type statsRecord struct {
+	historyRecord
+	duration time.Duration
+	steps    map[string]stepOutput
+}
+
+func runStats(ctx context.Context, dockerCli command.Cli, opts statsOptions) error {
+	if opts.format != formatter.TableFormatKey && opts.format != formatter.JSONFormatKey {
+		return errors.Errorf("unsupported format %q", opts.format)
+	}
+	if opts.groupBy != "" && !slices.Contains(statsGroupBy, opts.groupBy) {
+		return errors.Errorf("invalid group-by %q, must be one of %v", opts.groupBy, statsGroupBy)
+	}
+	if opts.limit <= 0 || opts.limit > math.MaxInt32 {
+		return errors.Errorf("invalid limit %d", opts.limit)
+	}
+
+	nodes, err := loadNodes(ctx, dockerCli, opts.builder)
+	if err != nil {
+		return err
+	}
+
+	queryOptions := &queryOptions{
+		CompletedOnly: true,
+		Limit:         int32(opts.limit),
+	}
+	if opts.local {
+		filter, err := localRepositoryFilter(ctx)
+		if err != nil {
+			return err
+		}
+		queryOptions.Filters = append(queryOptions.Filters, filter)
+	}
+	queryOptions.Filters = append(queryOptions.Filters, opts.filters...)
+
+	recs, err := queryRecords(ctx, "", nodes, queryOptions)
+	if err != nil {
+		return err
+	}
+	if len(recs) == 0 {
+		return errors.New("no records found")
+	}
+
+	records := make([]*statsRecord, len(recs))
+	for i, rec := range recs {
+		records[i] = &statsRecord{historyRecord: rec}
+		if rec.CreatedAt != nil && rec.CompletedAt != nil {
+			records[i].duration = rec.CompletedAt.AsTime().Sub(rec.CreatedAt.AsTime())
+		}
+	}
+	if opts.top > 0 {
+		if err := loadStatsSteps(ctx, records); err != nil {
+			return err
+		}
+	}
+
+	out := aggregateStats(records, opts.groupBy, opts.top)
+
+	if opts.format == formatter.JSONFormatKey {
+		enc := json.NewEncoder(dockerCli.Out())
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+	printStats(dockerCli.Out(), out, opts.groupBy)
+	return nil
+}
+
+func loadStatsSteps(ctx context.Context, records []*statsRecord) error {
+	var mu sync.Mutex
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(statsConcurrency)
+	for _, rec := range records {
+		eg.Go(func() error {
+			c, err := rec.node.Driver.Client(ctx)
+			if err != nil {
+				return err
+			}
+			steps, err := loadSteps(ctx, c, rec.Ref)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load steps of %s", rec.Ref)
+			}
+			mu.Lock()
+			rec.steps = steps
+			mu.Unlock()
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+// statsGroupKey returns the value of the grouping field of the record.
+func statsGroupKey(rec *statsRecord, groupBy string) string {
+	var v string
+	switch groupBy {
+	case "target":
+		v = rec.FrontendAttrs["target"]
+	case "repository":
+		v = recordRepository(rec.BuildHistoryRecord)
+	case "platform":
+		v = rec.FrontendAttrs["platform"]
+	case "status":
+		v = recordStatus(rec.BuildHistoryRecord)
+	}
+	if v == "" {
+		return statsNone
+	}
+	return v
+}
+
+func aggregateStats(records []*statsRecord, groupBy string, top int) []statsOutput {
+	groups := map[string][]*statsRecord{}
+	for _, rec := range records {
+		key := ""
+		if groupBy != "" {
+			key = statsGroupKey(rec, groupBy)
+		}
+		groups[key] = append(groups[key], rec)
+	}
+
+	out := make([]statsOutput, 0, len(groups))
+	for _, key := range slices.Sorted(maps.Keys(groups)) {
+		recs := groups[key]
+		st := statsOutput{
+			Group:  key,
+			Builds: len(recs),
+		}
+		durations := make([]time.Duration, 0, len(recs))
+		for _, rec := range recs {
+			switch recordStatus(rec.BuildHistoryRecord) {
+			case "error":
+				st.Failed++
+			case "canceled":
+				st.Canceled++
+			}
+			durations = append(durations, rec.duration)
+			st.TotalSteps += int(rec.NumTotalSteps)
+			st.CachedSteps += int(rec.NumCachedSteps)
+		}
+		slices.Sort(durations)
+		st.DurationP50 = percentile(durations, 50)
+		st.DurationP90 = percentile(durations, 90)
+		st.DurationP95 = percentile(durations, 95)
+		st.DurationMax = durations[len(durations)-1]
+		if st.TotalSteps > 0 {
+			st.CacheRatio = float64(st.CachedSteps) / float64(st.TotalSteps)
+		}
+		st.SlowestSteps = slowestSteps(recs, top)
+		out = append(out, st)
+	}
+	return out
+}
+
+// percentile returns the nearest-rank percentile of the sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(float64(p)/100*float64(len(sorted)))) - 1
+	return sorted[max(idx, 0)]
+}
+
+// slowestSteps returns the top steps with the longest average duration when
+// they are executed. Cached runs of a step are not counted.
+func slowestSteps(records []*statsRecord, top int) []statsStepOutput {
+	if top <= 0 {
+		return nil
+	}
+	steps := map[string]*statsStepOutput{}
+	totals := map[string]time.Duration{}
+	for _, rec := range records {
+		for name, s := range rec.steps {
+			if s.Cached {
+				continue
+			}
+			st, ok := steps[name]
+			if !ok {
+				st = &statsStepOutput{Name: name}
+				steps[name] = st
+			}
+			st.Runs++
+			st.DurationMax = max(st.DurationMax, s.Duration)
+			totals[name] += s.Duration
+		}
+	}
+	out := make([]statsStepOutput, 0, len(steps))
+	for name, st := range steps {
+		st.DurationAverage = totals[name] / time.Duration(st.Runs)
+		out = append(out, *st)
+	}
+	slices.SortFunc(out, func(a, b statsStepOutput) int {
+		return cmp.Or(
+			cmp.Compare(b.DurationAverage, a.DurationAverage),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+	if len(out) > top {
+		out = out[:top]
+	}
+	return out
+}
+
+func printStats(w io.Writer, out []statsOutput, groupBy string) {
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	if groupBy != "" {
+		fmt.Fprintf(tw, "%s\t", strings.ToUpper(groupBy))
+	}
+	fmt.Fprintf(tw, "BUILDS\tFAILED\tCANCELED\tP50\tP90\tP95\tMAX\tCACHED STEPS\n")
+	for _, st := range out {
+		if groupBy != "" {
+			fmt.Fprintf(tw, "%s\t", st.Group)
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%s\t%s\t%s\t%.0f%% (%d/%d)\n",
+			st.Builds, st.Failed, st.Canceled,
+			formatDuration(st.DurationP50), formatDuration(st.DurationP90), formatDuration(st.DurationP95), formatDuration(st.DurationMax),
+			st.CacheRatio*100, st.CachedSteps, st.TotalSteps,
+		)
+	}
+	tw.Flush()
+
+	for _, st := range out {
+		if len(st.SlowestSteps) == 0 {
+			continue
+		}
+		fmt.Fprintln(w)
+		if groupBy != "" {
+			fmt.Fprintf(w, "Slowest steps for %s %s:\n", groupBy, st.Group)
+		} else {
+			fmt.Fprintln(w, "Slowest steps:")
+		}
+		tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+		fmt.Fprintf(tw, "STEP\tRUNS\tAVERAGE\tMAX\n")
+		for _, s := range st.SlowestSteps {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", s.Name, s.Runs, formatDuration(s.DurationAverage), formatDuration(s.DurationMax))
+		}
+		tw.Flush()
+	}
+}
+
+func statsCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
+	var options statsOptions
+
+	cmd := &cobra.Command{
+		Use:   "stats [OPTIONS]",
+		Short: "Aggregate statistics of build records",
+		Args:  cli.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *rootOpts.Builder
+			return runStats(cmd.Context(), dockerCli, options)
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.format, "format", formatter.TableFormatKey, "Format the output")
+	flags.StringArrayVar(&options.filters, "filter", nil, `Provide filter values (e.g., "status=error")`)
+	flags.BoolVar(&options.local, "local", false, "Aggregate records for current repository only")
+	flags.StringVar(&options.groupBy, "group-by", "", `Group records by field ("target", "repository", "platform", "status")`)
+	flags.IntVar(&options.top, "top", 5, "Number of slowest steps to report for each group")
+	flags.IntVar(&options.limit, "limit", statsDefaultLimit, "Maximum number of records to aggregate for each node")
+
+	return cmd
+}
@@ -0,0 +1,199 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/stretchr/testify/require"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestPercentile(t *testing.T) {
+	durations := func(secs ...int) []time.Duration {
+		out := make([]time.Duration, 0, len(secs))
+		for _, s := range secs {
+			out = append(out, time.Duration(s)*time.Second)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		sorted   []time.Duration
+		p        int
+		expected time.Duration
+	}{
+		{
+			name:     "empty",
+			p:        50,
+			expected: 0,
+		},
+		{
+			name:     "single p50",
+			sorted:   durations(3),
+			p:        50,
+			expected: 3 * time.Second,
+		},
+		{
+			name:     "single p95",
+			sorted:   durations(3),
+			p:        95,
+			expected: 3 * time.Second,
+		},
+		{
+			name:     "p0",
+			sorted:   durations(1, 2, 3),
+			p:        0,
+			expected: 1 * time.Second,
+		},
+		{
+			name:     "p50 odd",
+			sorted:   durations(1, 2, 3),
+			p:        50,
+			expected: 2 * time.Second,
+		},
+		{
+			name:     "p50 even",
+			sorted:   durations(1, 2, 3, 4),
+			p:        50,
+			expected: 2 * time.Second,
+		},
+		{
+			name:     "p90",
+			sorted:   durations(1, 2, 3, 4, 5, 6, 7, 8, 9, 10),
+			p:        90,
+			expected: 9 * time.Second,
+		},
+		{
+			name:     "p95",
+			sorted:   durations(1, 2, 3, 4, 5, 6, 7, 8, 9, 10),
+			p:        95,
+			expected: 10 * time.Second,
+		},
+		{
+			name:     "p100",
+			sorted:   durations(1, 2, 3),
+			p:        100,
+			expected: 3 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, percentile(tt.sorted, tt.p))
+		})
+	}
+}
+
+func TestAggregateStats(t *testing.T) {
+	completed := timestamppb.Now()
+	record := func(target, errMsg string, duration time.Duration, total, cached int32, steps map[string]stepOutput) *statsRecord {
+		rec := &controlapi.BuildHistoryRecord{
+			FrontendAttrs:  map[string]string{},
+			CompletedAt:    completed,
+			NumTotalSteps:  total,
+			NumCachedSteps: cached,
+		}
+		if target != "" {
+			rec.FrontendAttrs["target"] = target
+		}
+		if errMsg != "" {
+			rec.Error = &spb.Status{Message: errMsg}
+		}
+		return &statsRecord{
+			historyRecord: historyRecord{BuildHistoryRecord: rec},
+			duration:      duration,
+			steps:         steps,
+		}
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		require.Empty(t, aggregateStats(nil, "", 3))
+		require.Empty(t, aggregateStats(nil, "target", 3))
+	})
+
+	t.Run("single", func(t *testing.T) {
+		out := aggregateStats([]*statsRecord{
+			record("app", "", 4*time.Second, 4, 1, map[string]stepOutput{
+				"RUN make": {Duration: 3 * time.Second},
+				"FROM":     {Duration: time.Second, Cached: true},
+			}),
+		}, "", 3)
+		require.Equal(t, []statsOutput{
+			{
+				Builds:      1,
+				DurationP50: 4 * time.Second,
+				DurationP90: 4 * time.Second,
+				DurationP95: 4 * time.Second,
+				DurationMax: 4 * time.Second,
+				TotalSteps:  4,
+				CachedSteps: 1,
+				CacheRatio:  0.25,
+				SlowestSteps: []statsStepOutput{
+					{Name: "RUN make", Runs: 1, DurationAverage: 3 * time.Second, DurationMax: 3 * time.Second},
+				},
+			},
+		}, out)
+	})
+
+	t.Run("group by target", func(t *testing.T) {
+		records := []*statsRecord{
+			record("app", "", 2*time.Second, 2, 0, map[string]stepOutput{
+				"RUN make": {Duration: 2 * time.Second},
+			}),
+			record("app", "failed to solve", 6*time.Second, 2, 2, map[string]stepOutput{
+				"RUN make": {Duration: 6 * time.Second},
+			}),
+			record("app", "context canceled", 4*time.Second, 0, 0, nil),
+			record("", "", time.Second, 0, 0, map[string]stepOutput{
+				"RUN make": {Duration: time.Second, Cached: true},
+			}),
+		}
+		out := aggregateStats(records, "target", 0)
+		require.Equal(t, []statsOutput{
+			{
+				Group:       statsNone,
+				Builds:      1,
+				DurationP50: time.Second,
+				DurationP90: time.Second,
+				DurationP95: time.Second,
+				DurationMax: time.Second,
+			},
+			{
+				Group:       "app",
+				Builds:      3,
+				Failed:      1,
+				Canceled:    1,
+				DurationP50: 4 * time.Second,
+				DurationP90: 6 * time.Second,
+				DurationP95: 6 * time.Second,
+				DurationMax: 6 * time.Second,
+				TotalSteps:  4,
+				CachedSteps: 2,
+				CacheRatio:  0.5,
+			},
+		}, out)
+	})
+
+	t.Run("slowest steps", func(t *testing.T) {
+		records := []*statsRecord{
+			record("", "", 5*time.Second, 3, 0, map[string]stepOutput{
+				"RUN a": {Duration: time.Second},
+				"RUN b": {Duration: 4 * time.Second},
+				"RUN c": {Duration: 2 * time.Second},
+			}),
+			record("", "", 3*time.Second, 3, 1, map[string]stepOutput{
+				"RUN a": {Duration: 3 * time.Second},
+				"RUN b": {Duration: 10 * time.Second, Cached: true},
+				"RUN c": {Duration: 2 * time.Second},
+			}),
+		}
+		out := aggregateStats(records, "", 2)
+		require.Len(t, out, 1)
+		require.Equal(t, []statsStepOutput{
+			{Name: "RUN b", Runs: 1, DurationAverage: 4 * time.Second, DurationMax: 4 * time.Second},
+			{Name: "RUN a", Runs: 2, DurationAverage: 2 * time.Second, DurationMax: 3 * time.Second},
+		}, out[0].SlowestSteps)
+	})
+}
@@ -78,23 +78,7 @@ func loadTrace(ctx context.Context, ref string, nodes []builder.Node) (string, [
 		}
 	}
 
-	c, err := rec.node.Driver.Client(ctx)
-	if err != nil {
-		return "", nil, err
-	}
-
-	store := proxy.NewContentStore(c.ContentClient())
-
-	ra, err := store.ReaderAt(ctx, ocispecs.Descriptor{
-		Digest:    digest.Digest(rec.Trace.Digest),
-		MediaType: rec.Trace.MediaType,
-		Size:      rec.Trace.Size,
-	})
-	if err != nil {
-		return "", nil, err
-	}
-
-	spans, err := otelutil.ParseSpanStubs(io.NewSectionReader(ra, 0, ra.Size()))
+	spans, err := readTraceSpans(ctx, rec)
 	if err != nil {
 		return "", nil, err
 	}
@@ -119,6 +103,28 @@ func loadTrace(ctx context.Context, ref string, nodes []builder.Node) (string, [
 	return string(wrapper.Data[0].TraceID), buf.Bytes(), nil
 }
 
+// readTraceSpans reads the spans of the trace attached to the record.
+func readTraceSpans(ctx context.Context, rec *historyRecord) (otelutil.Spans, error) {
+	c, err := rec.node.Driver.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	store := proxy.NewContentStore(c.ContentClient())
+
+	ra, err := store.ReaderAt(ctx, ocispecs.Descriptor{
+		Digest:    digest.Digest(rec.Trace.Digest),
+		MediaType: rec.Trace.MediaType,
+		Size:      rec.Trace.Size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer ra.Close()
+
+	return otelutil.ParseSpanStubs(io.NewSectionReader(ra, 0, ra.Size()))
+}
+
 func runTrace(ctx context.Context, dockerCli command.Cli, opts traceOptions) error {
 	nodes, err := loadNodes(ctx, dockerCli, opts.builder)
 	if err != nil {
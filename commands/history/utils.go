@@ -13,11 +13,14 @@ import (
 	"time"
 
 	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/driver"
+	"github.com/docker/buildx/store/storeutil"
 	"github.com/docker/cli/cli/command"
 	"github.com/docker/go-units"
 	controlapi "github.com/moby/buildkit/api/services/control"
 	"github.com/moby/buildkit/frontend/dockerfile/dfgitutil"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -39,7 +42,12 @@ type historyRecord struct {
 
 type queryOptions struct {
 	CompletedOnly bool
-	Filters       []string
+	// ActiveOnly only returns the records of the builds in progress.
+	ActiveOnly bool
+	Filters    []string
+	// Limit is the maximum number of records fetched from each node. It
+	// defaults to recordsLimit. A negative value fetches all the records.
+	Limit int32
 }
 
 func queryRecords(ctx context.Context, ref string, nodes []builder.Node, opts *queryOptions) ([]historyRecord, error) {
@@ -56,9 +64,12 @@ func queryRecords(ctx context.Context, ref string, nodes []builder.Node, opts *q
 		ref = ""
 	}
 
-	var filters []string
-	if opts != nil {
-		filters = opts.Filters
+	if opts == nil {
+		opts = &queryOptions{}
+	}
+	filters, matchers, err := historyFilters(opts.Filters)
+	if err != nil {
+		return nil, err
 	}
 
 	eg, ctx := errgroup.WithContext(ctx)
@@ -73,24 +84,12 @@ func queryRecords(ctx context.Context, ref string, nodes []builder.Node, opts *q
 				return err
 			}
 
-			var matchers []matchFunc
-			if len(filters) > 0 {
-				filters, matchers, err = dockerFiltersToBuildkit(filters)
-				if err != nil {
-					return err
-				}
-				sb := bytes.NewBuffer(nil)
-				w := csv.NewWriter(sb)
-				w.Write(filters)
-				w.Flush()
-				filters = []string{strings.TrimSuffix(sb.String(), "\n")}
-			}
-
 			serv, err := c.ControlClient().ListenBuildHistory(ctx, &controlapi.BuildHistoryRequest{
-				EarlyExit: true,
-				Ref:       ref,
-				Limit:     recordsLimit,
-				Filter:    filters,
+				EarlyExit:  true,
+				ActiveOnly: opts.ActiveOnly,
+				Ref:        ref,
+				Limit:      opts.limit(),
+				Filter:     filters,
 			})
 			if err != nil {
 				return err
@@ -120,7 +119,7 @@ func queryRecords(ctx context.Context, ref string, nodes []builder.Node, opts *q
 				if he.Type == controlapi.BuildHistoryEventType_DELETED || he.Record == nil {
 					continue
 				}
-				if opts != nil && opts.CompletedOnly && he.Type != controlapi.BuildHistoryEventType_COMPLETE {
+				if opts.CompletedOnly && he.Type != controlapi.BuildHistoryEventType_COMPLETE {
 					continue
 				}
 
@@ -171,6 +170,91 @@ func queryRecords(ctx context.Context, ref string, nodes []builder.Node, opts *q
 	return out, nil
 }
 
+// errStopWatch is returned by the callback of watchRecords to stop watching.
+var errStopWatch = errors.New("stop watching")
+
+// watchRecords subscribes to the history events of the nodes and calls fn for
+// each record that starts or completes, until the context is canceled or fn
+// returns errStopWatch. The records already in the history are sent first.
+// Calls to fn are serialized.
+func watchRecords(ctx context.Context, ref string, nodes []builder.Node, opts *queryOptions, fn func(historyRecord, controlapi.BuildHistoryEventType) error) error {
+	if opts == nil {
+		opts = &queryOptions{}
+	}
+	filters, matchers, err := historyFilters(opts.Filters)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	eg, ctx := errgroup.WithContext(ctx)
+	for _, node := range nodes {
+		eg.Go(func() error {
+			if node.Driver == nil {
+				return nil
+			}
+			c, err := node.Driver.Client(ctx)
+			if err != nil {
+				return err
+			}
+			serv, err := c.ControlClient().ListenBuildHistory(ctx, &controlapi.BuildHistoryRequest{
+				ActiveOnly: opts.ActiveOnly,
+				Ref:        ref,
+				Limit:      opts.limit(),
+				Filter:     filters,
+			})
+			if err != nil {
+				return err
+			}
+			defer serv.CloseSend()
+		loop0:
+			for {
+				he, err := serv.Recv()
+				if err != nil {
+					if errors.Is(err, io.EOF) {
+						return nil
+					}
+					return err
+				}
+				if he.Type == controlapi.BuildHistoryEventType_DELETED || he.Record == nil {
+					continue
+				}
+				if opts.CompletedOnly && he.Type != controlapi.BuildHistoryEventType_COMPLETE {
+					continue
+				}
+				for _, matcher := range matchers {
+					if !matcher(he.Record) {
+						continue loop0
+					}
+				}
+				mu.Lock()
+				err = fn(historyRecord{
+					BuildHistoryRecord: he.Record,
+					node:               &node,
+				}, he.Type)
+				mu.Unlock()
+				if err != nil {
+					return err
+				}
+			}
+		})
+	}
+	if err := eg.Wait(); err != nil && !errors.Is(err, errStopWatch) {
+		return err
+	}
+	return nil
+}
+
+func (o *queryOptions) limit() int32 {
+	if o.Limit > 0 {
+		return o.Limit
+	}
+	if o.Limit < 0 {
+		return 0
+	}
+	return recordsLimit
+}
+
 func finalizeRecord(ctx context.Context, ref string, node builder.Node) error {
 	if node.Driver == nil {
 		return nil
@@ -206,6 +290,24 @@ func humanizeBytes(v string) string {
 
 type matchFunc func(*controlapi.BuildHistoryRecord) bool
 
+// historyFilters converts the filters to the BuildKit history filter and to
+// matchers applied locally for older BuildKit versions that don't support
+// filters.
+func historyFilters(in []string) ([]string, []matchFunc, error) {
+	if len(in) == 0 {
+		return nil, nil, nil
+	}
+	filters, matchers, err := dockerFiltersToBuildkit(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	sb := bytes.NewBuffer(nil)
+	w := csv.NewWriter(sb)
+	w.Write(filters)
+	w.Flush()
+	return []string{strings.TrimSuffix(sb.String(), "\n")}, matchers, nil
+}
+
 func dockerFiltersToBuildkit(in []string) ([]string, []matchFunc, error) {
 	out := []string{}
 	matchers := []matchFunc{}
@@ -251,30 +353,9 @@ func valueFiler(key, value, sep string) matchFunc {
 		case "ref":
 			recValue = rec.Ref
 		case "repository":
-			v, ok := rec.FrontendAttrs["vcs:source"]
-			if ok {
-				recValue = v
-			} else {
-				if context, ok := rec.FrontendAttrs["context"]; ok {
-					if ref, _, err := dfgitutil.ParseGitRef(context); err == nil {
-						recValue = ref.Remote
-					}
-				}
-			}
+			recValue = recordRepository(rec)
 		case "status":
-			if rec.CompletedAt != nil {
-				if rec.Error != nil {
-					if strings.Contains(rec.Error.Message, "context canceled") {
-						recValue = "canceled"
-					} else {
-						recValue = "error"
-					}
-				} else {
-					recValue = "completed"
-				}
-			} else {
-				recValue = "running"
-			}
+			recValue = recordStatus(rec)
 		}
 		switch sep {
 		case "=":
@@ -290,6 +371,34 @@ func valueFiler(key, value, sep string) matchFunc {
 	}
 }
 
+// recordRepository returns the git repository the record was built from.
+func recordRepository(rec *controlapi.BuildHistoryRecord) string {
+	if v, ok := rec.FrontendAttrs["vcs:source"]; ok {
+		return v
+	}
+	if context, ok := rec.FrontendAttrs["context"]; ok {
+		if ref, _, err := dfgitutil.ParseGitRef(context); err == nil {
+			return ref.Remote
+		}
+	}
+	return ""
+}
+
+// recordStatus returns the status of the record as matched by the status
+// filter.
+func recordStatus(rec *controlapi.BuildHistoryRecord) string {
+	if rec.CompletedAt == nil {
+		return "running"
+	}
+	if rec.Error != nil {
+		if strings.Contains(rec.Error.Message, "context canceled") {
+			return "canceled"
+		}
+		return "error"
+	}
+	return "completed"
+}
+
 func timeBasedFilter(key, value, sep string) (matchFunc, error) {
 	var cmp int64
 	switch key {
@@ -375,3 +484,52 @@ func loadNodes(ctx context.Context, dockerCli command.Cli, builderName string) (
 	}
 	return nodes, nil
 }
+
+// loadAllNodes returns the running nodes of all the builders in the store.
+// Builders are loaded concurrently and are not booted. Nodes that can't be
+// loaded are skipped with a warning.
+func loadAllNodes(ctx context.Context, dockerCli command.Cli) ([]builder.Node, error) {
+	txn, release, err := storeutil.GetStore(dockerCli)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	builders, err := builder.GetBuilders(dockerCli, txn)
+	if err != nil {
+		return nil, err
+	}
+
+	eg, ctx2 := errgroup.WithContext(ctx)
+	for _, b := range builders {
+		eg.Go(func() error {
+			_, _ = b.LoadNodes(ctx2, builder.WithData())
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	var nodes []builder.Node
+	for _, b := range builders {
+		if err := b.Err(); err != nil {
+			logrus.Warnf("skipping builder %s: %v", b.Name, err)
+			continue
+		}
+		for _, node := range b.Nodes() {
+			if node.Err != nil {
+				logrus.Warnf("skipping node %s of builder %s: %v", node.Name, b.Name, node.Err)
+				continue
+			}
+			if node.DriverInfo == nil || node.DriverInfo.Status != driver.Running {
+				continue
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("no running builder found")
+	}
+	return nodes, nil
+}
@@ -0,0 +1,365 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/distribution/reference"
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/buildx/util/ocilayout"
+	"github.com/docker/buildx/util/progress"
+	"github.com/docker/cli/cli/command"
+	"github.com/docker/go-units"
+	"github.com/moby/buildkit/util/progress/progressui"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	copyStatusCopied   = "copied"
+	copyStatusUpToDate = "up-to-date"
+	copyStatusFailed   = "failed"
+)
+
+type copyOptions struct {
+	builder        string
+	files          []string
+	maxConcurrency int
+	progress       string
+	metadataFile   string
+}
+
+type copyPair struct {
+	src *imagetools.Location
+	dst *imagetools.Location
+}
+
+type copyResult struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Digest      string `json:"digest,omitempty"`
+	Status      string `json:"status"`
+	Copied      int    `json:"copied"`
+	Skipped     int    `json:"skipped"`
+	Size        int64  `json:"size"`
+	Error       string `json:"error,omitempty"`
+}
+
+type copySummary struct {
+	Images   []copyResult `json:"images"`
+	Copied   int          `json:"copied"`
+	UpToDate int          `json:"upToDate"`
+	Failed   int          `json:"failed"`
+}
+
+func runCopy(ctx context.Context, dockerCli command.Cli, in copyOptions, args []string) error {
+	if in.maxConcurrency < 1 {
+		return errors.Errorf("invalid max concurrency %d, must be at least 1", in.maxConcurrency)
+	}
+
+	var mappings []string
+	for _, f := range in.files {
+		m, err := readCopyMappings(f)
+		if err != nil {
+			return err
+		}
+		mappings = append(mappings, m...)
+	}
+	mappings = append(mappings, args...)
+	if len(mappings) == 0 {
+		return errors.Errorf("no images specified")
+	}
+
+	pairs := make([]copyPair, len(mappings))
+	for i, m := range mappings {
+		p, err := parseCopyPair(m)
+		if err != nil {
+			return err
+		}
+		pairs[i] = p
+	}
+
+	b, err := builder.New(dockerCli, builder.WithName(in.builder))
+	if err != nil {
+		return err
+	}
+	imageopt, err := b.ImageOpt()
+	if err != nil {
+		return err
+	}
+
+	results := make([]copyResult, len(pairs))
+	manifests := make([][]byte, len(pairs))
+	descs := make([]ocispecs.Descriptor, len(pairs))
+	// current is set for the images whose destination already points to the
+	// source digest, their referrers are still copied
+	current := make([]bool, len(pairs))
+
+	r := imagetools.New(imageopt)
+	eg, ctx2 := errgroup.WithContext(ctx)
+	eg.SetLimit(in.maxConcurrency)
+	for i, p := range pairs {
+		results[i] = copyResult{
+			Source:      p.src.String(),
+			Destination: p.dst.String(),
+		}
+		eg.Go(func() error {
+			dt, desc, err := r.Get(ctx2, p.src.String())
+			if err != nil {
+				results[i].Status = copyStatusFailed
+				results[i].Error = errors.Wrapf(err, "failed to resolve %s", p.src).Error()
+				return nil
+			}
+			manifests[i], descs[i] = dt, desc
+			results[i].Digest = desc.Digest.String()
+			if _, dstDesc, err := r.Resolve(ctx2, p.dst.String()); err == nil && dstDesc.Digest == desc.Digest {
+				current[i] = true
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	// new resolver cause need new auth
+	r = imagetools.New(imageopt)
+
+	ctx2, cancel := context.WithCancelCause(context.TODO())
+	defer func() { cancel(errors.WithStack(context.Canceled)) }()
+	progressMode := in.progress
+	if progressMode == "none" {
+		progressMode = "quiet"
+	}
+	printer, err := progress.NewPrinter(ctx2, os.Stderr, progressui.DisplayMode(progressMode))
+	if err != nil {
+		return err
+	}
+	pw := progress.WithPrefix(printer, "internal", true)
+
+	// share a single ingester per destination repository so that blobs
+	// common to several images are only pushed once
+	var mu sync.Mutex
+	ingesters := map[string]content.Ingester{}
+	ingesterFor := func(ctx context.Context, loc *imagetools.Location) (content.Ingester, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ing, ok := ingesters[loc.Name()]; ok {
+			return ing, nil
+		}
+		ing, err := r.IngesterForLocation(ctx, loc)
+		if err != nil {
+			return nil, err
+		}
+		ingesters[loc.Name()] = ing
+		return ing, nil
+	}
+
+	eg, _ = errgroup.WithContext(ctx)
+	eg.SetLimit(in.maxConcurrency)
+	for i, p := range pairs {
+		if results[i].Status != "" {
+			continue
+		}
+		eg.Go(func() error {
+			err := progress.Wrap(fmt.Sprintf("copying %s to %s", p.src, p.dst), pw.Write, func(sub progress.SubLogger) error {
+				ctx := withMediaTypeKeyPrefix(ctx)
+				ing, err := ingesterFor(ctx, p.dst)
+				if err != nil {
+					return err
+				}
+				ing, stats := imagetools.WithCopyStats(ing)
+				defer func() {
+					results[i].Copied = stats.Copied
+					results[i].Skipped = stats.Skipped
+					results[i].Size = stats.Size
+				}()
+				sub.Log(1, fmt.Appendf(nil, "copying %s from %s to %s\n", descs[i].Digest, p.src, p.dst.Name()))
+				if err := r.CopyWithIngester(ctx, &imagetools.Source{Ref: p.src, Desc: descs[i]}, p.dst, ing); err != nil {
+					return errors.Wrapf(err, "copy %s from %s to %s", descs[i].Digest, p.src, p.dst)
+				}
+				if current[i] {
+					// the destination already points to the image
+					return nil
+				}
+				sub.Log(1, fmt.Appendf(nil, "pushing %s to %s\n", descs[i].Digest, p.dst))
+				if err := r.Push(ctx, p.dst, descs[i], manifests[i]); err != nil {
+					return errors.Wrapf(err, "publish %s to %s", descs[i].Digest, p.dst)
+				}
+				return nil
+			})
+			if err != nil {
+				results[i].Status = copyStatusFailed
+				results[i].Error = err.Error()
+			} else {
+				results[i].Status = copiedStatus(current[i], results[i].Copied)
+			}
+			return nil
+		})
+	}
+	err = eg.Wait()
+	if err1 := printer.Wait(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+
+	summary := copySummary{Images: results}
+	for _, res := range results {
+		switch res.Status {
+		case copyStatusCopied:
+			summary.Copied++
+		case copyStatusUpToDate:
+			summary.UpToDate++
+		case copyStatusFailed:
+			summary.Failed++
+		}
+	}
+
+	printCopySummary(dockerCli.Out(), summary)
+
+	if len(in.metadataFile) > 0 {
+		if err := writeMetadataFile(in.metadataFile, summary); err != nil {
+			return err
+		}
+	}
+
+	if summary.Failed > 0 {
+		return errors.Errorf("failed to copy %d of %d images", summary.Failed, len(results))
+	}
+	return nil
+}
+
+// copiedStatus returns the status of an image copied without error. An image
+// whose destination already pointed to the source digest is up-to-date,
+// unless content was copied, like referrers attached since the last copy.
+func copiedStatus(current bool, copied int) string {
+	if current && copied == 0 {
+		return copyStatusUpToDate
+	}
+	return copyStatusCopied
+}
+
+// readCopyMappings reads the SRC=DST mappings of a file, one per line. Empty
+// lines and lines starting with # are ignored.
+func readCopyMappings(filename string) ([]string, error) {
+	var dt []byte
+	var err error
+	if filename == "-" {
+		dt, err = io.ReadAll(os.Stdin)
+	} else {
+		dt, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	s := bufio.NewScanner(bytes.NewReader(dt))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", filename)
+	}
+	return out, nil
+}
+
+// parseCopyPair parses a SRC=DST mapping. If the destination has no tag or
+// digest, the tag or digest of the source is used.
+func parseCopyPair(in string) (copyPair, error) {
+	src, dst, ok := strings.Cut(in, "=")
+	src, dst = strings.TrimSpace(src), strings.TrimSpace(dst)
+	if !ok || src == "" || dst == "" {
+		return copyPair{}, errors.Errorf("invalid mapping %q, expected SRC=DST", in)
+	}
+	srcLoc, err := imagetools.ParseLocation(src)
+	if err != nil {
+		return copyPair{}, errors.Wrapf(err, "invalid source %q", src)
+	}
+	dstLoc, err := imagetools.ParseLocation(dst)
+	if err != nil {
+		return copyPair{}, errors.Wrapf(err, "invalid destination %q", dst)
+	}
+	if isNameOnly(dst) {
+		switch {
+		case srcLoc.Tag() != "":
+			dstLoc, err = dstLoc.WithTag(srcLoc.Tag())
+		case srcLoc.Digest() != "":
+			dstLoc, err = dstLoc.WithDigest(srcLoc.Digest())
+			if err == nil && dstLoc.IsRegistry() {
+				dstLoc, err = imagetools.ParseLocation(dstLoc.Name() + "@" + srcLoc.Digest().String())
+			}
+		}
+		if err != nil {
+			return copyPair{}, errors.Wrapf(err, "invalid destination %q", dst)
+		}
+	}
+	return copyPair{src: srcLoc, dst: dstLoc}, nil
+}
+
+// isNameOnly returns true if the reference has no tag and no digest.
+func isNameOnly(in string) bool {
+	if ref, ok, err := ocilayout.Parse(in); ok {
+		// the tag of an OCI layout defaults to latest when not set
+		return err == nil && ref.Digest == "" && !strings.HasSuffix(in, ":"+ref.Tag)
+	}
+	named, err := reference.ParseNormalizedNamed(in)
+	if err != nil {
+		return false
+	}
+	return reference.IsNameOnly(named)
+}
+
+func printCopySummary(w io.Writer, summary copySummary) {
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintln(tw, "SOURCE\tDESTINATION\tSTATUS\tCOPIED\tSKIPPED\tSIZE")
+	for _, res := range summary.Images {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\n", res.Source, res.Destination, res.Status, res.Copied, res.Skipped, units.HumanSize(float64(res.Size)))
+	}
+	tw.Flush()
+	for _, res := range summary.Images {
+		if res.Error != "" {
+			fmt.Fprintf(w, "\nerror copying %s: %s\n", res.Source, res.Error)
+		}
+	}
+}
+
+func copyCmd(dockerCli command.Cli, opts RootOptions) *cobra.Command {
+	var options copyOptions
+
+	cmd := &cobra.Command{
+		Use:   "copy [OPTIONS] [SRC=DST...]",
+		Short: "Copy images with all their platforms and referrers to other repositories",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *opts.Builder
+			return runCopy(cmd.Context(), dockerCli, options, args)
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVarP(&options.files, "file", "f", []string{}, `Read "SRC=DST" mappings from file, one per line`)
+	flags.IntVar(&options.maxConcurrency, "max-concurrency", 4, "Maximum number of images copied in parallel")
+	flags.StringVar(&options.progress, "progress", "auto", `Set type of progress output ("auto", "none", "plain", "rawjson", "tty"). Use plain to show container output`)
+	flags.StringVar(&options.metadataFile, "metadata-file", "", "Write copy summary to a file")
+
+	return cmd
+}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCopyPair(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		in  string
+		src string
+		dst string
+		err string
+	}{
+		{
+			in:  "alpine:3.20=registry.example.com/mirror/alpine",
+			src: "docker.io/library/alpine:3.20",
+			dst: "registry.example.com/mirror/alpine:3.20",
+		},
+		{
+			in:  "alpine=registry.example.com/mirror/alpine:stable",
+			src: "docker.io/library/alpine:latest",
+			dst: "registry.example.com/mirror/alpine:stable",
+		},
+		{
+			in:  "alpine@sha256:0000000000000000000000000000000000000000000000000000000000000000=registry.example.com/mirror/alpine",
+			src: "docker.io/library/alpine@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			dst: "registry.example.com/mirror/alpine@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			in:  " alpine:3.20 = oci-layout://./mirror",
+			src: "docker.io/library/alpine:3.20",
+			dst: "oci-layout://./mirror:3.20",
+		},
+		{
+			in:  "alpine",
+			err: "expected SRC=DST",
+		},
+		{
+			in:  "alpine=",
+			err: "expected SRC=DST",
+		},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			p, err := parseCopyPair(tc.in)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.src, p.src.String())
+			require.Equal(t, tc.dst, p.dst.String())
+		})
+	}
+}
+
+func TestReadCopyMappings(t *testing.T) {
+	t.Parallel()
+
+	f := filepath.Join(t.TempDir(), "mirror.txt")
+	require.NoError(t, os.WriteFile(f, []byte(`# base images
+alpine:3.20=registry.example.com/mirror/alpine
+
+  busybox=registry.example.com/mirror/busybox
+`), 0o644))
+
+	mappings, err := readCopyMappings(f)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"alpine:3.20=registry.example.com/mirror/alpine",
+		"busybox=registry.example.com/mirror/busybox",
+	}, mappings)
+}
+
+func TestCopiedStatus(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, copyStatusCopied, copiedStatus(false, 3))
+	require.Equal(t, copyStatusCopied, copiedStatus(false, 0))
+	// referrers attached to the source since the last copy
+	require.Equal(t, copyStatusCopied, copiedStatus(true, 2))
+	require.Equal(t, copyStatusUpToDate, copiedStatus(true, 0))
+}
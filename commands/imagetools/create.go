@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 
@@ -200,9 +202,25 @@ func runCreate(ctx context.Context, dockerCli command.Cli, in createOptions, arg
 	// new resolver cause need new auth
 	r = imagetools.New(imageopt)
 
+	err = pushIndex(ctx, r, in.progress, tags, manifests, desc, dt)
+
+	if err == nil && len(in.metadataFile) > 0 {
+		if err := writeMetadataFile(in.metadataFile, map[string]any{
+			exptypes.ExporterImageDescriptorKey: desc,
+			exptypes.ExporterImageNameKey:       strings.Join(repoNames, ","),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// pushIndex copies the manifests of an index from their sources to the
+// repositories of the tags and pushes the index to all the tags.
+func pushIndex(ctx context.Context, r *imagetools.Resolver, progressMode string, tags []*imagetools.Location, manifests []imagetools.DescWithSource, desc ocispecs.Descriptor, dt []byte) error {
 	ctx2, cancel := context.WithCancelCause(context.TODO())
 	defer func() { cancel(errors.WithStack(context.Canceled)) }()
-	progressMode := in.progress
 	if progressMode == "none" {
 		progressMode = "quiet"
 	}
@@ -266,16 +284,6 @@ func runCreate(ctx context.Context, dockerCli command.Cli, in createOptions, arg
 	if err == nil {
 		err = err1
 	}
-
-	if err == nil && len(in.metadataFile) > 0 {
-		if err := writeMetadataFile(in.metadataFile, map[string]any{
-			exptypes.ExporterImageDescriptorKey: desc,
-			exptypes.ExporterImageNameKey:       strings.Join(repoNames, ","),
-		}); err != nil {
-			return err
-		}
-	}
-
 	return err
 }
 
@@ -331,6 +339,15 @@ func parseLocations(in []string) ([]*imagetools.Location, error) {
 	return refs, nil
 }
 
+// tagRepositories returns the sorted names of the repositories of the tags.
+func tagRepositories(tags []*imagetools.Location) []string {
+	repos := map[string]struct{}{}
+	for _, t := range tags {
+		repos[t.Name()] = struct{}{}
+	}
+	return slices.Sorted(maps.Keys(repos))
+}
+
 func parseSource(in string) (*imagetools.Source, error) {
 	// source can be a digest, reference or a descriptor JSON
 	dgst, err := digest.Parse(in)
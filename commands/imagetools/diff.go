@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/docker/cli/cli/command/formatter"
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type diffOptions struct {
+	builder string
+	format  string
+}
+
+func runDiff(ctx context.Context, dockerCli command.Cli, in diffOptions, base, compare string) error {
+	if in.format != formatter.PrettyFormatKey && in.format != formatter.JSONFormatKey {
+		return errors.Errorf("unsupported format %q", in.format)
+	}
+
+	b, err := builder.New(dockerCli, builder.WithName(in.builder))
+	if err != nil {
+		return err
+	}
+	imageopt, err := b.ImageOpt()
+	if err != nil {
+		return err
+	}
+
+	diff, err := imagetools.New(imageopt).Diff(ctx, base, compare)
+	if err != nil {
+		return err
+	}
+
+	if in.format == formatter.JSONFormatKey {
+		enc := json.NewEncoder(dockerCli.Out())
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+	printImageDiff(dockerCli.Out(), diff)
+	return nil
+}
+
+func printImageDiff(w io.Writer, diff *imagetools.ImageDiff) {
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "PLATFORM\tSTATUS\tBASE\tCOMPARE\n")
+	for _, p := range diff.Platforms {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Platform, p.Status, formatDiffDigest(p.BaseDigest.String()), formatDiffDigest(p.CompareDigest.String()))
+	}
+	tw.Flush()
+
+	for _, p := range diff.Platforms {
+		if p.Status != imagetools.DiffStatusChanged {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n\n", p.Platform)
+		printImageValueDiffs(w, p.Config, "Config")
+		printImageValueDiffs(w, p.Env, "Env")
+		printImageValueDiffs(w, p.Labels, "Label")
+		if l := p.Layers; l != nil && (len(l.Removed) > 0 || len(l.Added) > 0) {
+			fmt.Fprintf(w, "Layers: %d shared, %d removed, %d added\n", l.Shared, len(l.Removed), len(l.Added))
+			tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+			for _, d := range l.Removed {
+				fmt.Fprintf(tw, "-\t%s\t%s\n", d.Digest, units.HumanSize(float64(d.Size)))
+			}
+			for _, d := range l.Added {
+				fmt.Fprintf(tw, "+\t%s\t%s\n", d.Digest, units.HumanSize(float64(d.Size)))
+			}
+			tw.Flush()
+			fmt.Fprintln(w)
+		}
+		printImageValueDiffs(w, p.Packages, "Package")
+		printImageValueDiffs(w, p.Materials, "Material")
+	}
+}
+
+func printImageValueDiffs(w io.Writer, diffs []imagetools.ValueDiff, title string) {
+	if len(diffs) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "%s\tBASE\tCOMPARE\n", strings.ToUpper(title))
+	for _, d := range diffs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", d.Name, formatImageDiffValue(d.Base), formatImageDiffValue(d.Compare))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+func formatImageDiffValue(v *string) string {
+	if v == nil {
+		return "-"
+	}
+	if *v == "" {
+		return `""`
+	}
+	return *v
+}
+
+func formatDiffDigest(dgst string) string {
+	if dgst == "" {
+		return "-"
+	}
+	return dgst
+}
+
+func diffCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
+	var options diffOptions
+
+	cmd := &cobra.Command{
+		Use:   "diff [OPTIONS] NAME1 NAME2",
+		Short: "Compare two images in the registry",
+		Args:  cli.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *rootOpts.Builder
+			return runDiff(cmd.Context(), dockerCli, options, args[0], args[1])
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.format, "format", formatter.PrettyFormatKey, "Format the output (pretty, json)")
+
+	return cmd
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/buildflags"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type editOptions struct {
+	builder           string
+	tags              []string
+	removePlatforms   []string
+	dropAttestations  bool
+	annotations       []string
+	removeAnnotations []string
+	dryrun            bool
+	progress          string
+	metadataFile      string
+}
+
+func runEdit(ctx context.Context, dockerCli command.Cli, in editOptions, name string) error {
+	if !in.dryrun && len(in.tags) == 0 {
+		return errors.Errorf("can't push with no tags specified, please set --tag or --dry-run")
+	}
+
+	src, err := imagetools.ParseLocation(name)
+	if err != nil {
+		return err
+	}
+	tags, err := parseLocations(in.tags)
+	if err != nil {
+		return err
+	}
+	removePlatforms, err := parsePlatforms(in.removePlatforms)
+	if err != nil {
+		return err
+	}
+	annotations, err := buildflags.ParseAnnotations(in.annotations)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse annotations")
+	}
+	removeAnnotations, err := parseAnnotationKeys(in.removeAnnotations)
+	if err != nil {
+		return err
+	}
+
+	b, err := builder.New(dockerCli, builder.WithName(in.builder))
+	if err != nil {
+		return err
+	}
+	imageopt, err := b.ImageOpt()
+	if err != nil {
+		return err
+	}
+
+	r := imagetools.New(imageopt)
+
+	_, desc, err := r.Resolve(ctx, src.String())
+	if err != nil {
+		return err
+	}
+
+	dt, desc, manifests, err := r.Edit(ctx, &imagetools.Source{Ref: src, Desc: desc}, imagetools.EditOpt{
+		RemovePlatforms:   removePlatforms,
+		DropAttestations:  in.dropAttestations,
+		Annotations:       annotations,
+		RemoveAnnotations: removeAnnotations,
+	})
+	if err != nil {
+		return err
+	}
+
+	if in.dryrun {
+		fmt.Printf("%s\n", dt)
+		return nil
+	}
+
+	// new resolver cause need new auth
+	r = imagetools.New(imageopt)
+
+	if err := pushIndex(ctx, r, in.progress, tags, manifests, desc, dt); err != nil {
+		return err
+	}
+
+	if len(in.metadataFile) > 0 {
+		if err := writeMetadataFile(in.metadataFile, map[string]any{
+			exptypes.ExporterImageDescriptorKey: desc,
+			exptypes.ExporterImageNameKey:       strings.Join(tagRepositories(tags), ","),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseAnnotationKeys parses annotation keys with the same syntax as the
+// annotations, without value.
+func parseAnnotationKeys(in []string) ([]exptypes.AnnotationKey, error) {
+	out := make([]exptypes.AnnotationKey, 0, len(in))
+	for _, k := range in {
+		if strings.Contains(k, "=") {
+			return nil, errors.Errorf("invalid annotation key %q, value not allowed", k)
+		}
+		ann, err := buildflags.ParseAnnotations([]string{k + "="})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse annotation key %q", k)
+		}
+		out = slices.AppendSeq(out, maps.Keys(ann))
+	}
+	return out, nil
+}
+
+func editCmd(dockerCli command.Cli, opts RootOptions) *cobra.Command {
+	var options editOptions
+
+	cmd := &cobra.Command{
+		Use:   "edit [OPTIONS] NAME",
+		Short: "Remove platforms, attestations or annotations from an image index",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *opts.Builder
+			return runEdit(cmd.Context(), dockerCli, options, args[0])
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVarP(&options.tags, "tag", "t", []string{}, "Set reference for new image")
+	flags.StringArrayVar(&options.removePlatforms, "remove-platform", []string{}, "Remove a platform and its attestations from the index")
+	flags.BoolVar(&options.dropAttestations, "drop-attestations", false, "Remove all attestation manifests from the index")
+	flags.StringArrayVar(&options.annotations, "annotation", []string{}, "Add annotation to the image")
+	flags.StringArrayVar(&options.removeAnnotations, "remove-annotation", []string{}, "Remove annotation from the image")
+	flags.BoolVar(&options.dryrun, "dry-run", false, "Show final image instead of pushing")
+	flags.StringVar(&options.progress, "progress", "auto", `Set type of progress output ("auto", "none", "plain", "rawjson", "tty"). Use plain to show container output`)
+	flags.StringVar(&options.metadataFile, "metadata-file", "", "Write push result metadata to a file")
+
+	return cmd
+}
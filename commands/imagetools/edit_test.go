@@ -0,0 +1,23 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAnnotationKeys(t *testing.T) {
+	t.Parallel()
+
+	keys, err := parseAnnotationKeys([]string{"index:foo", "manifest-descriptor[linux/amd64]:bar"})
+	require.NoError(t, err)
+	require.Equal(t, []exptypes.AnnotationKey{
+		{Type: exptypes.AnnotationIndex, Key: "foo"},
+		{Type: exptypes.AnnotationManifestDescriptor, Key: "bar", Platform: &ocispecs.Platform{OS: "linux", Architecture: "amd64"}},
+	}, keys)
+
+	_, err = parseAnnotationKeys([]string{"index:foo=bar"})
+	require.ErrorContains(t, err, "value not allowed")
+}
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type loadOptions struct {
+	builder      string
+	tags         []string
+	progress     string
+	metadataFile string
+}
+
+func runLoad(ctx context.Context, dockerCli command.Cli, in loadOptions, file string) error {
+	if len(in.tags) == 0 {
+		return errors.Errorf("can't push with no tags specified, please set --tag")
+	}
+	tags, err := parseLocations(in.tags)
+	if err != nil {
+		return err
+	}
+
+	var rd io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		rd = f
+	}
+
+	dir, err := os.MkdirTemp("", "buildx-imagetools-load-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	src, err := imagetools.ReadOCIArchive(rd, dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load %s", file)
+	}
+
+	b, err := builder.New(dockerCli, builder.WithName(in.builder))
+	if err != nil {
+		return err
+	}
+	imageopt, err := b.ImageOpt()
+	if err != nil {
+		return err
+	}
+
+	r := imagetools.New(imageopt)
+	dt, desc, err := r.Get(ctx, src.String())
+	if err != nil {
+		return err
+	}
+
+	manifests := []imagetools.DescWithSource{{Descriptor: desc, Source: &imagetools.Source{Ref: src, Desc: desc}}}
+	if err := pushIndex(ctx, r, in.progress, tags, manifests, desc, dt); err != nil {
+		return err
+	}
+
+	if len(in.metadataFile) > 0 {
+		if err := writeMetadataFile(in.metadataFile, map[string]any{
+			exptypes.ExporterImageDescriptorKey: desc,
+			exptypes.ExporterImageNameKey:       strings.Join(tagRepositories(tags), ","),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadCmd(dockerCli command.Cli, opts RootOptions) *cobra.Command {
+	var options loadOptions
+
+	cmd := &cobra.Command{
+		Use:   "load [OPTIONS] FILE",
+		Short: "Push an image from an OCI layout tarball to a registry",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *opts.Builder
+			return runLoad(cmd.Context(), dockerCli, options, args[0])
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVarP(&options.tags, "tag", "t", []string{}, "Set reference for the loaded image")
+	flags.StringVar(&options.progress, "progress", "auto", `Set type of progress output ("auto", "none", "plain", "rawjson", "tty"). Use plain to show container output`)
+	flags.StringVar(&options.metadataFile, "metadata-file", "", "Write push result metadata to a file")
+
+	return cmd
+}
@@ -21,7 +21,14 @@ func RootCmd(rootcmd *cobra.Command, dockerCli command.Cli, opts RootOptions) *c
 
 	cmd.AddCommand(
 		createCmd(dockerCli, opts),
+		copyCmd(dockerCli, opts),
+		diffCmd(dockerCli, opts),
+		editCmd(dockerCli, opts),
 		inspectCmd(dockerCli, opts),
+		loadCmd(dockerCli, opts),
+		saveCmd(dockerCli, opts),
+		signCmd(dockerCli, opts),
+		verifyCmd(dockerCli, opts),
 	)
 
 	return cmd
@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/containerd/console"
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/buildx/util/progress"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/util/progress/progressui"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type saveOptions struct {
+	builder      string
+	output       string
+	progress     string
+	metadataFile string
+}
+
+func runSave(ctx context.Context, dockerCli command.Cli, in saveOptions, name string) (err error) {
+	src, err := imagetools.ParseLocation(name)
+	if err != nil {
+		return err
+	}
+
+	b, err := builder.New(dockerCli, builder.WithName(in.builder))
+	if err != nil {
+		return err
+	}
+	imageopt, err := b.ImageOpt()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if in.output != "" && in.output != "-" {
+		f, err := os.Create(in.output)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create output file %q", in.output)
+		}
+		defer func() {
+			if err1 := f.Close(); err == nil {
+				err = err1
+			}
+			if err != nil {
+				os.Remove(in.output)
+			}
+		}()
+		w = f
+	} else if _, err := console.ConsoleFromFile(os.Stdout); err == nil {
+		return errors.Errorf("refusing to write to console, use --output to specify a file")
+	}
+
+	ctx2, cancel := context.WithCancelCause(context.TODO())
+	defer func() { cancel(errors.WithStack(context.Canceled)) }()
+	progressMode := in.progress
+	if progressMode == "none" {
+		progressMode = "quiet"
+	}
+	printer, err := progress.NewPrinter(ctx2, os.Stderr, progressui.DisplayMode(progressMode))
+	if err != nil {
+		return err
+	}
+	pw := progress.WithPrefix(printer, "internal", true)
+
+	var desc ocispecs.Descriptor
+	err = progress.Wrap(fmt.Sprintf("saving %s", src), pw.Write, func(sub progress.SubLogger) error {
+		var err error
+		desc, err = imagetools.New(imageopt).Save(withMediaTypeKeyPrefix(ctx), src, w)
+		return err
+	})
+	if err1 := printer.Wait(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(in.metadataFile) > 0 {
+		if err := writeMetadataFile(in.metadataFile, map[string]any{
+			exptypes.ExporterImageDescriptorKey: desc,
+			exptypes.ExporterImageNameKey:       src.String(),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveCmd(dockerCli command.Cli, opts RootOptions) *cobra.Command {
+	var options saveOptions
+
+	cmd := &cobra.Command{
+		Use:   "save [OPTIONS] NAME",
+		Short: "Save an image with its attestations and referrers to an OCI layout tarball",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *opts.Builder
+			return runSave(cmd.Context(), dockerCli, options, args[0])
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&options.output, "output", "o", "", "Write to a file, instead of STDOUT")
+	flags.StringVar(&options.progress, "progress", "auto", `Set type of progress output ("auto", "none", "plain", "rawjson", "tty"). Use plain to show container output`)
+	flags.StringVar(&options.metadataFile, "metadata-file", "", "Write save result metadata to a file")
+
+	return cmd
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/containerd/platforms"
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// signingKeyPasswordEnv is the environment variable holding the password of
+// an encrypted signing key, as used by cosign.
+const signingKeyPasswordEnv = "COSIGN_PASSWORD"
+
+type signOptions struct {
+	builder string
+	key     string
+}
+
+func runSign(ctx context.Context, dockerCli command.Cli, in signOptions, name string) error {
+	if in.key == "" {
+		return errors.Errorf("no signing key specified, please set --key")
+	}
+
+	signer, err := imagetools.LoadSigningKey(in.key, func(bool) ([]byte, error) {
+		pw, ok := os.LookupEnv(signingKeyPasswordEnv)
+		if !ok {
+			return nil, errors.Errorf("signing key is encrypted, please set %s", signingKeyPasswordEnv)
+		}
+		return []byte(pw), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b, err := builder.New(dockerCli, builder.WithName(in.builder))
+	if err != nil {
+		return err
+	}
+	imageopt, err := b.ImageOpt()
+	if err != nil {
+		return err
+	}
+
+	signed, err := imagetools.New(imageopt).Sign(withMediaTypeKeyPrefix(ctx), name, signer)
+	if err != nil {
+		return err
+	}
+	printSigned(dockerCli.Out(), signed)
+	return nil
+}
+
+func printSigned(w io.Writer, signed []imagetools.SignedManifest) {
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintln(tw, "SUBJECT\tPLATFORM\tSIGNATURE")
+	for _, s := range signed {
+		p := "-"
+		if s.Subject.Platform != nil {
+			p = platforms.FormatAll(*s.Subject.Platform)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Subject.Digest, p, s.Signature.Digest)
+	}
+	tw.Flush()
+}
+
+func signCmd(dockerCli command.Cli, opts RootOptions) *cobra.Command {
+	var options signOptions
+
+	cmd := &cobra.Command{
+		Use:   "sign [OPTIONS] NAME",
+		Short: "Sign an image and its attestations with a local key",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *opts.Builder
+			return runSign(cmd.Context(), dockerCli, options, args[0])
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.key, "key", "", "Path to the PEM encoded private key to sign with")
+
+	return cmd
+}
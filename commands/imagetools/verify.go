@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/docker/cli/cli/command/formatter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type verifyOptions struct {
+	builder     string
+	keys        []string
+	trustConfig string
+	format      string
+}
+
+func runVerify(ctx context.Context, dockerCli command.Cli, in verifyOptions, name string) error {
+	if in.format != formatter.PrettyFormatKey && in.format != formatter.JSONFormatKey {
+		return errors.Errorf("unsupported format %q", in.format)
+	}
+
+	cfg := &imagetools.TrustConfig{}
+	if in.trustConfig != "" {
+		c, err := imagetools.LoadTrustConfig(in.trustConfig)
+		if err != nil {
+			return err
+		}
+		cfg = c
+	}
+	for _, k := range in.keys {
+		cfg.Keys = append(cfg.Keys, imagetools.TrustedKey{Path: k})
+	}
+	if len(cfg.Keys) == 0 {
+		return errors.Errorf("no trusted keys specified, please set --key or --trust-config")
+	}
+
+	b, err := builder.New(dockerCli, builder.WithName(in.builder))
+	if err != nil {
+		return err
+	}
+	imageopt, err := b.ImageOpt()
+	if err != nil {
+		return err
+	}
+
+	res, err := imagetools.New(imageopt).Verify(ctx, name, cfg)
+	if err != nil {
+		return err
+	}
+
+	if in.format == formatter.JSONFormatKey {
+		enc := json.NewEncoder(dockerCli.Out())
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	} else {
+		printVerifyResult(dockerCli.Out(), res)
+	}
+
+	if !res.Verified {
+		return errors.Errorf("verification failed for %s", name)
+	}
+	return nil
+}
+
+func printVerifyResult(w io.Writer, res *imagetools.VerifyResult) {
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", res.Name)
+	fmt.Fprintf(tw, "Digest:\t%s\n", res.Digest)
+	fmt.Fprintf(tw, "Verified:\t%t\n", res.Verified)
+	tw.Flush()
+
+	for _, p := range res.Platforms {
+		fmt.Fprintf(w, "\n%s (%s):\n\n", p.Platform, p.Digest)
+		if len(p.Signatures) == 0 && len(p.Attestations) == 0 {
+			fmt.Fprintf(w, "No signatures found\n")
+			continue
+		}
+		tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+		fmt.Fprintln(tw, "TYPE\tDIGEST\tKEY\tSTATUS")
+		for _, s := range p.Signatures {
+			typ := "signature"
+			if s.Subject != p.Digest {
+				typ = "attestation-signature"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", typ, s.Digest, formatVerifyKey(s.Key), formatVerifyStatus(s.Error))
+		}
+		for _, a := range p.Attestations {
+			typ := "attestation"
+			if a.PredicateType != "" {
+				typ += " " + a.PredicateType
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", typ, a.Digest, formatVerifyKey(a.Key), formatVerifyStatus(a.Error))
+		}
+		tw.Flush()
+	}
+}
+
+func formatVerifyKey(key string) string {
+	if key == "" {
+		return "-"
+	}
+	return key
+}
+
+func formatVerifyStatus(err string) string {
+	if err == "" {
+		return "verified"
+	}
+	return "failed: " + err
+}
+
+func verifyCmd(dockerCli command.Cli, opts RootOptions) *cobra.Command {
+	var options verifyOptions
+
+	cmd := &cobra.Command{
+		Use:   "verify [OPTIONS] NAME",
+		Short: "Verify the signatures and attestations of an image",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *opts.Builder
+			return runVerify(cmd.Context(), dockerCli, options, args[0])
+		},
+		ValidArgsFunction:     completion.Disable,
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVar(&options.keys, "key", []string{}, "Path to a PEM encoded public key to trust")
+	flags.StringVar(&options.trustConfig, "trust-config", "", "Path to a JSON file listing the public keys to trust")
+	flags.StringVar(&options.format, "format", formatter.PrettyFormatKey, "Format the output (pretty, json)")
+
+	return cmd
+}
@@ -22,6 +22,8 @@ import (
 	_ "github.com/docker/buildx/driver/docker"
 	_ "github.com/docker/buildx/driver/docker-container"
 	_ "github.com/docker/buildx/driver/kubernetes"
+	_ "github.com/docker/buildx/driver/local-process"
+	_ "github.com/docker/buildx/driver/podman"
 	_ "github.com/docker/buildx/driver/remote"
 )
 
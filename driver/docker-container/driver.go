@@ -550,7 +550,7 @@ func (d *Driver) Features(ctx context.Context) map[driver.Feature]bool {
 }
 
 func (d *Driver) HostGatewayIP(ctx context.Context) (net.IP, error) {
-	return nil, errors.New("host-gateway is not supported by the docker-container driver")
+	return nil, errors.Errorf("host-gateway is not supported by the %s driver", d.factory.Name())
 }
 
 // hasGPUCapability checks if docker daemon has GPU capability. We need to run
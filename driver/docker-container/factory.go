@@ -42,6 +42,13 @@ func (f *factory) New(ctx context.Context, cfg driver.InitConfig) (driver.Driver
 	if cfg.DockerAPI == nil {
 		return nil, errors.Errorf("%s driver requires docker API access", f.Name())
 	}
+	return NewDriver(f, cfg)
+}
+
+// NewDriver returns a driver running BuildKit in a container created through
+// the Docker API of the config. Drivers of other container engines exposing a
+// Docker compatible API use it with their own factory.
+func NewDriver(f driver.Factory, cfg driver.InitConfig) (*Driver, error) {
 	rp, err := dockeropts.ParseRestartPolicy(defaultRestartPolicy)
 	if err != nil {
 		return nil, err
@@ -123,7 +130,7 @@ func (f *factory) New(ctx context.Context, cfg driver.InitConfig) (driver.Driver
 				return nil, err
 			}
 		default:
-			return nil, errors.Errorf("invalid driver option %s for docker-container driver", k)
+			return nil, errors.Errorf("invalid driver option %s for %s driver", k, f.Name())
 		}
 	}
 
@@ -61,6 +61,13 @@ type UncachedClientDriver interface {
 	RequiresUncachedClient() bool
 }
 
+// ImageLoader is implemented by the drivers that load the images of the
+// docker exporter into their own container engine instead of the Docker
+// daemon of the current context.
+type ImageLoader interface {
+	LoadImage(ctx context.Context, status progress.Writer) (io.WriteCloser, func(), error)
+}
+
 type Driver interface {
 	Factory() Factory
 	Bootstrap(context.Context, progress.Logger) error
@@ -0,0 +1,436 @@
+package localprocess
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/buildx/driver"
+	"github.com/docker/buildx/util/confutil"
+	"github.com/docker/buildx/util/progress"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/util/tracing/delegated"
+	"github.com/pkg/errors"
+)
+
+const (
+	socketFile          = "buildkitd.sock"
+	pidFile             = "buildkitd.pid"
+	logFile             = "buildkitd.log"
+	configDir           = "config"
+	rootDir             = "root"
+	buildkitdConfigFile = "buildkitd.toml"
+
+	bootTimeout = 20 * time.Second
+	stopTimeout = 10 * time.Second
+)
+
+type Driver struct {
+	driver.InitConfig
+	factory driver.Factory
+
+	// stateDir holds the socket, pid, log and config files of the daemon,
+	// and its root directory unless another one is set
+	stateDir string
+
+	buildkitd   string
+	root        string
+	rootless    bool
+	rootlesskit string
+	env         []string
+	defaultLoad bool
+}
+
+func (d *Driver) IsMobyDriver() bool {
+	return false
+}
+
+func (d *Driver) Config() driver.InitConfig {
+	return d.InitConfig
+}
+
+func (d *Driver) Bootstrap(ctx context.Context, l progress.Logger) error {
+	return progress.Wrap("[internal] booting buildkit", l, func(sub progress.SubLogger) error {
+		info, err := d.Info(ctx)
+		if err != nil {
+			return err
+		}
+		if info.Status == driver.Running {
+			return nil
+		}
+		var exited <-chan error
+		if err := sub.Wrap("starting buildkitd "+d.Name, func() error {
+			exited, err = d.start()
+			return err
+		}); err != nil {
+			return err
+		}
+		return sub.Wrap("waiting for buildkitd "+d.Name, func() error {
+			return d.wait(ctx, sub, exited)
+		})
+	})
+}
+
+// start launches buildkitd in a new session so that it outlives the buildx
+// process, and records its pid. The returned channel receives the result of
+// the process if it exits while buildx is still running.
+func (d *Driver) start() (<-chan error, error) {
+	// a previous daemon that is not responding anymore is replaced
+	if err := d.Stop(context.TODO(), true); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(d.stateDir, 0o700); err != nil {
+		return nil, err
+	}
+	args, err := d.buildkitdArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	name := d.buildkitd
+	if d.rootless {
+		args = append([]string{d.buildkitd}, args...)
+		name = d.rootlesskit
+	}
+	bin, err := exec.LookPath(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find %s", name)
+	}
+
+	lf, err := os.OpenFile(filepath.Join(d.stateDir, logFile), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer lf.Close()
+
+	cmd := exec.Command(bin, args...)
+	cmd.Dir = d.stateDir
+	cmd.Env = append(os.Environ(), d.env...)
+	cmd.Stdout = lf
+	cmd.Stderr = lf
+	cmd.SysProcAttr = sysProcAttr()
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "failed to start %s", bin)
+	}
+	if err := os.WriteFile(filepath.Join(d.stateDir, pidFile), []byte(strconv.Itoa(cmd.Process.Pid)), 0o600); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	return exited, nil
+}
+
+// buildkitdArgs returns the arguments of buildkitd, and writes the config
+// files of the builder to the state directory.
+func (d *Driver) buildkitdArgs() ([]string, error) {
+	args := []string{
+		"--addr", "unix://" + d.socketPath(),
+		"--root", d.rootPath(),
+	}
+	if d.rootless {
+		args = append(args, "--rootless")
+	}
+	if len(d.Files) > 0 {
+		dir := filepath.Join(d.stateDir, configDir)
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+		for name, dt := range d.Files {
+			fp := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(fp), 0o700); err != nil {
+				return nil, err
+			}
+			if name == buildkitdConfigFile {
+				// registry certificates are referenced in the config
+				// directory of a container, use the one of the daemon
+				dt = bytes.ReplaceAll(dt, []byte(confutil.DefaultBuildKitConfigDir+"/"), []byte(filepath.ToSlash(dir)+"/"))
+			}
+			if err := os.WriteFile(fp, dt, 0o600); err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := d.Files[buildkitdConfigFile]; ok {
+			// user flags come after, so that they can override the config
+			args = append(args, "--config", filepath.Join(dir, buildkitdConfigFile))
+		}
+	}
+	return append(args, d.BuildkitdFlags...), nil
+}
+
+func (d *Driver) wait(ctx context.Context, l progress.SubLogger, exited <-chan error) error {
+	ctx, cancel := context.WithTimeoutCause(ctx, bootTimeout, errors.WithStack(context.DeadlineExceeded))
+	defer cancel()
+
+	try := 1
+	for {
+		conn, err := d.Dial(ctx)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case err := <-exited:
+			d.copyLogs(l)
+			d.cleanup()
+			if err == nil {
+				err = errors.New("exited")
+			}
+			return errors.Wrap(err, "buildkitd exited before accepting connections")
+		case <-ctx.Done():
+			d.copyLogs(l)
+			return context.Cause(ctx)
+		case <-time.After(time.Duration(min(try, 10)*100) * time.Millisecond):
+			try++
+		}
+	}
+}
+
+func (d *Driver) copyLogs(l progress.SubLogger) {
+	dt, err := os.ReadFile(filepath.Join(d.stateDir, logFile))
+	if err == nil && len(dt) > 0 {
+		l.Log(2, dt)
+	}
+}
+
+func (d *Driver) Info(ctx context.Context) (*driver.Info, error) {
+	pid, err := d.pid()
+	if err != nil {
+		return nil, err
+	}
+	if pid == 0 {
+		if _, err := os.Stat(d.stateDir); err == nil {
+			return &driver.Info{
+				Status: driver.Stopped,
+			}, nil
+		}
+		return &driver.Info{
+			Status: driver.Inactive,
+		}, nil
+	}
+	if !d.daemonAlive(pid) {
+		return &driver.Info{
+			Status: driver.Stopped,
+		}, nil
+	}
+	conn, err := d.Dial(ctx)
+	if err != nil {
+		return &driver.Info{
+			Status: driver.Starting,
+		}, nil
+	}
+	conn.Close()
+	return &driver.Info{
+		Status: driver.Running,
+	}, nil
+}
+
+func (d *Driver) Version(ctx context.Context) (string, error) {
+	bufStdout := &bytes.Buffer{}
+	bufStderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, d.buildkitd, "--version")
+	cmd.Stdout = bufStdout
+	cmd.Stderr = bufStderr
+	if err := cmd.Run(); err != nil {
+		if bufStderr.Len() > 0 {
+			return "", errors.Wrap(err, bufStderr.String())
+		}
+		return "", err
+	}
+	version := strings.Fields(bufStdout.String())
+	if len(version) != 4 {
+		return "", errors.Errorf("unexpected version format: %s", bufStdout.String())
+	}
+	return version[2], nil
+}
+
+// Stop terminates buildkitd and waits for it to exit. The daemon is killed if
+// force is set, or if it doesn't exit in time.
+func (d *Driver) Stop(ctx context.Context, force bool) error {
+	pid, err := d.pid()
+	if err != nil || pid == 0 {
+		return err
+	}
+	// a pid file left by a daemon that didn't exit cleanly may point to
+	// an unrelated process, which is never signaled
+	if d.daemonAlive(pid) {
+		if err := signalProcess(pid, force); err != nil {
+			return errors.Wrapf(err, "failed to stop buildkitd %d", pid)
+		}
+		exited, err := d.waitExit(ctx, pid)
+		if err != nil {
+			return err
+		}
+		if !exited && !force {
+			if err := signalProcess(pid, true); err != nil {
+				return errors.Wrapf(err, "failed to kill buildkitd %d", pid)
+			}
+			if exited, err = d.waitExit(ctx, pid); err != nil {
+				return err
+			}
+		}
+		if !exited {
+			return errors.Errorf("buildkitd %d did not exit", pid)
+		}
+	}
+	d.cleanup()
+	return nil
+}
+
+// waitExit waits for a process to exit, and returns false if it is still
+// running after the stop timeout.
+func (d *Driver) waitExit(ctx context.Context, pid int) (bool, error) {
+	deadline := time.Now().Add(stopTimeout)
+	for d.daemonAlive(pid) {
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, context.Cause(ctx)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return true, nil
+}
+
+// daemonAlive returns true if the process is running and is the buildkitd of
+// this builder, as its pid may have been reused by another process since the
+// pid file was written.
+func (d *Driver) daemonAlive(pid int) bool {
+	if !processAlive(pid) {
+		return false
+	}
+	cmdline, err := processCmdline(pid)
+	if err != nil {
+		return false
+	}
+	return isDaemonCmdline(cmdline, d.buildkitd, "unix://"+d.socketPath())
+}
+
+// isDaemonCmdline returns true if the space separated command line runs the
+// buildkitd binary, directly or through rootlesskit, listening on addr. The
+// address is unique to the state directory of the builder.
+func isDaemonCmdline(cmdline, buildkitd, addr string) bool {
+	if !strings.Contains(cmdline+" ", " --addr "+addr+" ") {
+		return false
+	}
+	return slices.ContainsFunc(strings.Fields(cmdline), func(arg string) bool {
+		return filepath.Base(arg) == filepath.Base(buildkitd)
+	})
+}
+
+// cleanup removes the runtime files of a daemon that is not running.
+func (d *Driver) cleanup() {
+	os.Remove(filepath.Join(d.stateDir, pidFile))
+	os.Remove(d.socketPath())
+}
+
+// Rm stops buildkitd. With rmDaemon, the state directory is removed except
+// for the root directory of the daemon, which is only removed with rmVolume.
+// A root directory set with the root driver option is never removed.
+func (d *Driver) Rm(ctx context.Context, force, rmVolume, rmDaemon bool) error {
+	if !rmDaemon {
+		return nil
+	}
+	if err := d.Stop(ctx, force); err != nil {
+		return err
+	}
+	if rmVolume {
+		return os.RemoveAll(d.stateDir)
+	}
+	entries, err := os.ReadDir(d.stateDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() == rootDir {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(d.stateDir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Driver) Dial(ctx context.Context) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", d.socketPath())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return conn, nil
+}
+
+func (d *Driver) Client(ctx context.Context, opts ...client.ClientOpt) (*client.Client, error) {
+	opts = append([]client.ClientOpt{
+		client.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return d.Dial(ctx)
+		}),
+		client.WithTracerDelegate(delegated.DefaultExporter),
+	}, opts...)
+	return client.New(ctx, "", opts...)
+}
+
+func (d *Driver) Factory() driver.Factory {
+	return d.factory
+}
+
+func (d *Driver) Features(ctx context.Context) map[driver.Feature]bool {
+	return map[driver.Feature]bool{
+		driver.OCIExporter:    true,
+		driver.DockerExporter: true,
+		driver.CacheExport:    true,
+		driver.MultiPlatform:  true,
+		driver.DirectPush:     true,
+		driver.DefaultLoad:    d.defaultLoad,
+	}
+}
+
+func (d *Driver) HostGatewayIP(ctx context.Context) (net.IP, error) {
+	return nil, errors.New("host-gateway is not supported by the local-process driver")
+}
+
+func (d *Driver) socketPath() string {
+	return filepath.Join(d.stateDir, socketFile)
+}
+
+func (d *Driver) rootPath() string {
+	if d.root != "" {
+		return d.root
+	}
+	return filepath.Join(d.stateDir, rootDir)
+}
+
+// pid returns the pid of the daemon recorded in the state directory, or zero
+// if it was never started.
+func (d *Driver) pid() (int, error) {
+	dt, err := os.ReadFile(filepath.Join(d.stateDir, pidFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(dt)))
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid pid file for %s", d.Name)
+	}
+	return pid, nil
+}
@@ -0,0 +1,296 @@
+//go:build !windows
+
+package localprocess
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/docker/buildx/driver"
+	"github.com/moby/buildkit/client"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBuildkitdEnv makes the test binary behave as buildkitd, "fail" makes
+// it exit before listening.
+const fakeBuildkitdEnv = "BUILDX_TEST_FAKE_BUILDKITD"
+
+func TestMain(m *testing.M) {
+	if v := os.Getenv(fakeBuildkitdEnv); v != "" {
+		os.Exit(fakeBuildkitd(v, os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// fakeBuildkitd accepts connections on its address until it is terminated.
+func fakeBuildkitd(mode string, args []string) int {
+	fs := flag.NewFlagSet("buildkitd", flag.ContinueOnError)
+	addr := fs.String("addr", "", "")
+	root := fs.String("root", "", "")
+	version := fs.Bool("version", false, "")
+	fs.String("config", "", "")
+	fs.Bool("rootless", false, "")
+	fs.Bool("debug", false, "")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *version {
+		fmt.Println("buildkitd github.com/moby/buildkit v0.0.0-test 0123456789ab")
+		return 0
+	}
+	if mode == "fail" {
+		fmt.Fprintln(os.Stderr, "fake buildkitd failed to start")
+		return 1
+	}
+	if err := os.MkdirAll(*root, 0o700); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	l, err := net.Listen("unix", strings.TrimPrefix(*addr, "unix://"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+	<-sig
+	return 0
+}
+
+func newTestDriver(t *testing.T, opts map[string]string) *Driver {
+	t.Helper()
+	bin, err := os.Executable()
+	require.NoError(t, err)
+	if opts == nil {
+		opts = map[string]string{}
+	}
+	opts["buildkitd"] = bin
+	d, err := (&factory{}).New(context.TODO(), driver.InitConfig{
+		Name:       driver.BuilderName("test"),
+		DriverOpts: opts,
+		ConfigDir:  t.TempDir(),
+	})
+	require.NoError(t, err)
+	return d.(*Driver)
+}
+
+func requireStatus(t *testing.T, d *Driver, status driver.Status) {
+	t.Helper()
+	info, err := d.Info(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, status, info.Status)
+}
+
+func discardLogger(*client.SolveStatus) {}
+
+func TestDriverLifecycle(t *testing.T) {
+	t.Setenv(fakeBuildkitdEnv, "1")
+	ctx := context.TODO()
+	d := newTestDriver(t, nil)
+
+	requireStatus(t, d, driver.Inactive)
+
+	require.NoError(t, d.Bootstrap(ctx, discardLogger))
+	requireStatus(t, d, driver.Running)
+	require.DirExists(t, d.rootPath())
+
+	v, err := d.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "v0.0.0-test", v)
+
+	// bootstrapping a running daemon is a no-op
+	pid, err := d.pid()
+	require.NoError(t, err)
+	require.NoError(t, d.Bootstrap(ctx, discardLogger))
+	pid2, err := d.pid()
+	require.NoError(t, err)
+	require.Equal(t, pid, pid2)
+
+	require.NoError(t, d.Stop(ctx, false))
+	requireStatus(t, d, driver.Stopped)
+	require.False(t, d.daemonAlive(pid))
+	require.NoFileExists(t, d.socketPath())
+
+	require.NoError(t, d.Bootstrap(ctx, discardLogger))
+	requireStatus(t, d, driver.Running)
+
+	// the root directory is kept unless the volume is removed
+	require.NoError(t, d.Rm(ctx, true, false, true))
+	requireStatus(t, d, driver.Stopped)
+	require.DirExists(t, d.rootPath())
+	require.NoFileExists(t, filepath.Join(d.stateDir, logFile))
+
+	require.NoError(t, d.Rm(ctx, true, true, true))
+	requireStatus(t, d, driver.Inactive)
+	require.NoDirExists(t, d.stateDir)
+}
+
+func TestDriverBootstrapFailure(t *testing.T) {
+	t.Setenv(fakeBuildkitdEnv, "fail")
+	d := newTestDriver(t, nil)
+
+	err := d.Bootstrap(context.TODO(), discardLogger)
+	require.ErrorContains(t, err, "buildkitd exited before accepting connections")
+	requireStatus(t, d, driver.Stopped)
+
+	dt, err := os.ReadFile(filepath.Join(d.stateDir, logFile))
+	require.NoError(t, err)
+	require.Contains(t, string(dt), "fake buildkitd failed to start")
+}
+
+func TestDriverStalePid(t *testing.T) {
+	t.Setenv(fakeBuildkitdEnv, "1")
+	ctx := context.TODO()
+	d := newTestDriver(t, nil)
+	require.NoError(t, d.Bootstrap(ctx, discardLogger))
+	t.Cleanup(func() {
+		d.Stop(ctx, true)
+	})
+	pid, err := d.pid()
+	require.NoError(t, err)
+
+	// the pid of an unrelated process, and of the daemon of another builder
+	for _, p := range []int{os.Getpid(), pid} {
+		d2 := newTestDriver(t, nil)
+		require.NoError(t, os.MkdirAll(d2.stateDir, 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(d2.stateDir, pidFile), []byte(strconv.Itoa(p)), 0o600))
+
+		requireStatus(t, d2, driver.Stopped)
+		require.NoError(t, d2.Stop(ctx, true))
+		require.NoFileExists(t, filepath.Join(d2.stateDir, pidFile))
+		require.True(t, processAlive(p))
+	}
+	requireStatus(t, d, driver.Running)
+}
+
+func TestIsDaemonCmdline(t *testing.T) {
+	const addr = "unix:///home/user/.docker/buildx/local-process/test/buildkitd.sock"
+	for _, tc := range []struct {
+		name     string
+		cmdline  string
+		expected bool
+	}{
+		{
+			name:     "daemon",
+			cmdline:  "/usr/local/bin/buildkitd --addr " + addr + " --root /home/user/.docker/buildx/local-process/test/root",
+			expected: true,
+		},
+		{
+			name:     "rootless",
+			cmdline:  "/usr/bin/rootlesskit buildkitd --addr " + addr + " --root /var/lib/buildkit --rootless",
+			expected: true,
+		},
+		{
+			name:    "other process",
+			cmdline: "/usr/bin/sleep 3600",
+		},
+		{
+			name:    "other builder",
+			cmdline: "/usr/local/bin/buildkitd --addr unix:///home/user/.docker/buildx/local-process/other/buildkitd.sock",
+		},
+		{
+			name:    "address prefix",
+			cmdline: "/usr/local/bin/buildkitd --addr " + addr + ".old",
+		},
+		{
+			name:    "other binary",
+			cmdline: "/usr/bin/socat --addr " + addr,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, isDaemonCmdline(tc.cmdline, "buildkitd", addr))
+		})
+	}
+}
+
+func TestBuildkitdArgs(t *testing.T) {
+	root := t.TempDir()
+	d := newTestDriver(t, map[string]string{
+		"root":     root,
+		"rootless": "true",
+	})
+	d.BuildkitdFlags = []string{"--debug"}
+	d.Files = map[string][]byte{
+		"buildkitd.toml":           []byte("[registry.\"example.com\"]\n  ca = [\"/etc/buildkit/certs/example.com/ca.pem\"]\n"),
+		"certs/example.com/ca.pem": []byte("ca"),
+	}
+
+	args, err := d.buildkitdArgs()
+	require.NoError(t, err)
+	cfgDir := filepath.Join(d.stateDir, configDir)
+	require.Equal(t, []string{
+		"--addr", "unix://" + d.socketPath(),
+		"--root", root,
+		"--rootless",
+		"--config", filepath.Join(cfgDir, "buildkitd.toml"),
+		"--debug",
+	}, args)
+
+	dt, err := os.ReadFile(filepath.Join(cfgDir, "buildkitd.toml"))
+	require.NoError(t, err)
+	require.Contains(t, string(dt), filepath.Join(cfgDir, "certs", "example.com", "ca.pem"))
+	require.FileExists(t, filepath.Join(cfgDir, "certs", "example.com", "ca.pem"))
+}
+
+func TestFactoryNew(t *testing.T) {
+	f := &factory{}
+	cfg := driver.InitConfig{
+		Name:      driver.BuilderName("test"),
+		ConfigDir: t.TempDir(),
+	}
+
+	for _, tc := range []struct {
+		opts map[string]string
+		err  string
+	}{
+		{opts: map[string]string{"root": "relative/root"}, err: "non-absolute path"},
+		{opts: map[string]string{"rootless": "maybe"}, err: "invalid syntax"},
+		{opts: map[string]string{"rootlesskit": "/usr/bin/rootlesskit"}, err: "requires rootless=true"},
+		{opts: map[string]string{"env.": "foo"}, err: "invalid env option"},
+		{opts: map[string]string{"image": "moby/buildkit"}, err: "invalid driver option image for local-process driver"},
+	} {
+		cfg.DriverOpts = tc.opts
+		_, err := f.New(context.TODO(), cfg)
+		require.ErrorContains(t, err, tc.err)
+	}
+
+	cfg.DriverOpts = map[string]string{"rootless": "true", "env.FOO": "bar", "default-load": "true"}
+	d, err := f.New(context.TODO(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, defaultRootlesskitBinary, d.(*Driver).rootlesskit)
+	require.Equal(t, []string{"FOO=bar"}, d.(*Driver).env)
+	require.True(t, d.Features(context.TODO())[driver.DefaultLoad])
+
+	cfg.ConfigDir = ""
+	cfg.DriverOpts = nil
+	_, err = f.New(context.TODO(), cfg)
+	require.ErrorContains(t, err, "requires a buildx config directory")
+}
+
+func TestFactoryPriority(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	require.Equal(t, priorityUnsupported, (&factory{}).Priority(context.TODO(), "", nil, nil))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, defaultBuildkitdBinary), []byte("#!/bin/sh\n"), 0o755))
+	require.Equal(t, prioritySupported, (&factory{}).Priority(context.TODO(), "", nil, nil))
+}
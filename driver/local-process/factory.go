@@ -0,0 +1,106 @@
+package localprocess
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/docker/buildx/driver"
+	dockerclient "github.com/moby/moby/client"
+	"github.com/pkg/errors"
+)
+
+const prioritySupported = 50
+const priorityUnsupported = 95
+
+const (
+	defaultBuildkitdBinary   = "buildkitd"
+	defaultRootlesskitBinary = "rootlesskit"
+)
+
+func init() {
+	driver.Register(&factory{})
+}
+
+type factory struct {
+}
+
+func (*factory) Name() string {
+	return "local-process"
+}
+
+func (*factory) Usage() string {
+	return "local-process"
+}
+
+func (*factory) Priority(ctx context.Context, endpoint string, api dockerclient.APIClient, dialMeta map[string][]string) int {
+	if runtime.GOOS == "windows" {
+		return priorityUnsupported
+	}
+	if _, err := exec.LookPath(defaultBuildkitdBinary); err != nil {
+		return priorityUnsupported
+	}
+	return prioritySupported
+}
+
+func (f *factory) New(ctx context.Context, cfg driver.InitConfig) (driver.Driver, error) {
+	if runtime.GOOS == "windows" {
+		return nil, errors.Errorf("%s driver is not supported on Windows", f.Name())
+	}
+	if cfg.ConfigDir == "" {
+		return nil, errors.Errorf("%s driver requires a buildx config directory", f.Name())
+	}
+	d := &Driver{
+		factory:    f,
+		InitConfig: cfg,
+		stateDir:   filepath.Join(cfg.ConfigDir, "local-process", cfg.Name),
+		buildkitd:  defaultBuildkitdBinary,
+	}
+	var err error
+	for k, v := range cfg.DriverOpts {
+		switch {
+		case k == "buildkitd":
+			d.buildkitd = v
+		case k == "root":
+			if !filepath.IsAbs(v) {
+				return nil, errors.Errorf("non-absolute path '%s' provided for %s", v, k)
+			}
+			d.root = v
+		case k == "rootless":
+			d.rootless, err = strconv.ParseBool(v)
+			if err != nil {
+				return nil, err
+			}
+		case k == "rootlesskit":
+			d.rootlesskit = v
+		case k == "default-load":
+			d.defaultLoad, err = strconv.ParseBool(v)
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(k, "env."):
+			envName := strings.TrimPrefix(k, "env.")
+			if envName == "" {
+				return nil, errors.Errorf("invalid env option %q, expecting env.FOO=bar", k)
+			}
+			d.env = append(d.env, fmt.Sprintf("%s=%s", envName, v))
+		default:
+			return nil, errors.Errorf("invalid driver option %s for %s driver", k, f.Name())
+		}
+	}
+	if d.rootlesskit != "" && !d.rootless {
+		return nil, errors.Errorf("rootlesskit driver option requires rootless=true")
+	}
+	if d.rootless && d.rootlesskit == "" {
+		d.rootlesskit = defaultRootlesskitBinary
+	}
+	return d, nil
+}
+
+func (f *factory) AllowsInstances() bool {
+	return true
+}
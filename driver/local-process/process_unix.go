@@ -0,0 +1,52 @@
+//go:build !windows
+
+package localprocess
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// sysProcAttr starts the daemon in its own session, so that it isn't killed
+// together with the buildx process group.
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// processAlive returns true if the process exists and belongs to the current
+// user. A pid reused by a process of another user after a reboot is not
+// considered as the daemon.
+func processAlive(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
+
+// signalProcess asks the process to terminate, or kills it if force is set.
+func signalProcess(pid int, force bool) error {
+	sig := syscall.SIGTERM
+	if force {
+		sig = syscall.SIGKILL
+	}
+	return syscall.Kill(pid, sig)
+}
+
+// processCmdline returns the arguments of the process separated by spaces.
+// Systems without procfs are queried with ps.
+func processCmdline(pid int) (string, error) {
+	if runtime.GOOS == "linux" {
+		dt, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/cmdline")
+		if err != nil {
+			return "", err
+		}
+		return string(bytes.ReplaceAll(bytes.TrimRight(dt, "\x00"), []byte{0}, []byte{' '})), nil
+	}
+	dt, err := exec.Command("ps", "-o", "command=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(dt)), nil
+}
@@ -0,0 +1,23 @@
+package localprocess
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+func sysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+func processAlive(pid int) bool {
+	return false
+}
+
+func signalProcess(pid int, force bool) error {
+	return errors.New("signaling processes is not supported on Windows")
+}
+
+func processCmdline(pid int) (string, error) {
+	return "", errors.New("reading process command lines is not supported on Windows")
+}
@@ -51,6 +51,9 @@ type InitConfig struct {
 	Platforms       []ocispecs.Platform
 	ContextPathHash string
 	DialMeta        map[string][]string
+	// ConfigDir is the buildx configuration directory, where drivers running
+	// BuildKit on the host keep their state.
+	ConfigDir string
 }
 
 var drivers map[string]Factory
@@ -0,0 +1,24 @@
+package podman
+
+import (
+	"context"
+	"io"
+
+	dockercontainer "github.com/docker/buildx/driver/docker-container"
+	"github.com/docker/buildx/util/dockerutil"
+	"github.com/docker/buildx/util/progress"
+)
+
+// Driver runs BuildKit in a container created through the Docker compatible
+// API of Podman.
+type Driver struct {
+	*dockercontainer.Driver
+}
+
+// LoadImage loads the images of the docker exporter into Podman, as the
+// Docker daemon of the current context may not exist or may not be the
+// engine running the builder.
+func (d *Driver) LoadImage(ctx context.Context, status progress.Writer) (io.WriteCloser, func(), error) {
+	w, cancel := dockerutil.LoadImageAPI(ctx, d.Config().DockerAPI, "podman", status)
+	return w, cancel, nil
+}
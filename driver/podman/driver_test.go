@@ -0,0 +1,151 @@
+package podman
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/buildx/driver"
+	"github.com/docker/buildx/util/progress"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+var apiVersionPrefix = regexp.MustCompile(`^/v[0-9.]+`)
+
+// fakePodman serves the subset of the Docker compatible API of Podman used
+// to create the builder container and to load images.
+type fakePodman struct {
+	mu       sync.Mutex
+	created  []byte
+	loaded   []byte
+	requests []string
+}
+
+func (f *fakePodman) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := apiVersionPrefix.ReplaceAllString(r.URL.Path, "")
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+p)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case p == "/_ping":
+		w.Header().Set("Api-Version", "1.41")
+		io.WriteString(w, "OK")
+	case r.Method == http.MethodGet && strings.HasPrefix(p, "/containers/") && strings.HasSuffix(p, "/json"):
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"message":"no such container"}`)
+	case p == "/images/create":
+		io.WriteString(w, `{"status":"Pulled"}`)
+	case r.Method == http.MethodGet && strings.HasPrefix(p, "/images/") && strings.HasSuffix(p, "/json"):
+		io.WriteString(w, `{"Id":"sha256:0000000000000000000000000000000000000000000000000000000000000000"}`)
+	case p == "/info":
+		io.WriteString(w, `{}`)
+	case p == "/containers/create":
+		dt, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.created = dt
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"Id":"abc"}`)
+	case strings.HasSuffix(p, "/archive"):
+		io.Copy(io.Discard, r.Body)
+	case strings.HasSuffix(p, "/start"):
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, `{"message":"start not supported by fake"}`)
+	case p == "/images/load":
+		dt, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.loaded = dt
+		f.mu.Unlock()
+		io.WriteString(w, `{"stream":"Loaded image: test:latest\n"}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"message":"unexpected request"}`)
+	}
+}
+
+func newTestDriver(t *testing.T, opts map[string]string) (*Driver, *fakePodman) {
+	fake := &fakePodman{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	opts["socket"] = "tcp://" + srv.Listener.Addr().String()
+	d, err := (&factory{}).New(context.TODO(), driver.InitConfig{
+		Name:       driver.BuilderName("test"),
+		DriverOpts: opts,
+	})
+	require.NoError(t, err)
+	return d.(*Driver), fake
+}
+
+func TestBootstrapCreatesContainer(t *testing.T) {
+	d, fake := newTestDriver(t, map[string]string{
+		"network":        "host",
+		"memory":         "512m",
+		"image":          "moby/buildkit:test",
+		"restart-policy": "always",
+	})
+
+	err := d.Bootstrap(context.TODO(), func(*client.SolveStatus) {})
+	require.ErrorContains(t, err, "start not supported by fake")
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	require.Contains(t, fake.requests, "POST /images/create")
+	require.NotNil(t, fake.created)
+
+	var req struct {
+		container.Config
+		HostConfig *container.HostConfig
+	}
+	require.NoError(t, json.Unmarshal(fake.created, &req))
+	require.Equal(t, "moby/buildkit:test", req.Image)
+	require.NotNil(t, req.HostConfig)
+	require.True(t, req.HostConfig.Privileged)
+	require.Equal(t, container.NetworkMode("host"), req.HostConfig.NetworkMode)
+	require.Equal(t, int64(512*1024*1024), req.HostConfig.Memory)
+	require.Equal(t, container.RestartPolicyAlways, req.HostConfig.RestartPolicy.Name)
+	require.Len(t, req.HostConfig.Mounts, 1)
+	require.Equal(t, mount.TypeVolume, req.HostConfig.Mounts[0].Type)
+	require.Equal(t, d.Name+"_state", req.HostConfig.Mounts[0].Source)
+}
+
+func TestLoadImage(t *testing.T) {
+	d, fake := newTestDriver(t, map[string]string{})
+
+	var _ driver.ImageLoader = d
+	w, cancel, err := d.LoadImage(context.TODO(), testProgressWriter{})
+	require.NoError(t, err)
+	defer cancel()
+
+	_, err = w.Write([]byte("image tarball"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	require.Equal(t, "image tarball", string(fake.loaded))
+}
+
+type testProgressWriter struct{}
+
+func (testProgressWriter) Write(*client.SolveStatus) {}
+
+func (testProgressWriter) WriteBuildRef(string, string) {}
+
+func (testProgressWriter) ValidateLogSource(digest.Digest, any) bool { return true }
+
+func (testProgressWriter) ClearLogSource(any) {}
+
+var _ progress.Writer = testProgressWriter{}
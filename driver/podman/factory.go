@@ -0,0 +1,114 @@
+package podman
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/buildx/driver"
+	dockercontainer "github.com/docker/buildx/driver/docker-container"
+	dockerclient "github.com/moby/moby/client"
+	"github.com/pkg/errors"
+)
+
+const prioritySupported = 60
+const priorityUnsupported = 85
+
+// socketEnv is the environment variable used by podman-remote to set the
+// address of the Podman API service.
+const socketEnv = "CONTAINER_HOST"
+
+func init() {
+	driver.Register(&factory{})
+}
+
+type factory struct {
+}
+
+func (*factory) Name() string {
+	return "podman"
+}
+
+func (*factory) Usage() string {
+	return "podman"
+}
+
+func (*factory) Priority(ctx context.Context, endpoint string, api dockerclient.APIClient, dialMeta map[string][]string) int {
+	proto, addr, _ := strings.Cut(defaultSocket(), "://")
+	if proto != "unix" {
+		return priorityUnsupported
+	}
+	if _, err := os.Stat(addr); err != nil {
+		return priorityUnsupported
+	}
+	return prioritySupported
+}
+
+func (f *factory) New(ctx context.Context, cfg driver.InitConfig) (driver.Driver, error) {
+	socket := defaultSocket()
+	if v, ok := cfg.DriverOpts["socket"]; ok {
+		socket = v
+	}
+	host, err := parseSocket(socket)
+	if err != nil {
+		return nil, err
+	}
+
+	api, err := dockerclient.New(dockerclient.WithHost(host), dockerclient.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create client for podman socket %s", host)
+	}
+
+	cfg.DockerAPI = api
+	cfg.DriverOpts = maps.Clone(cfg.DriverOpts)
+	delete(cfg.DriverOpts, "socket")
+	d, err := dockercontainer.NewDriver(f, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Driver{Driver: d}, nil
+}
+
+func (f *factory) AllowsInstances() bool {
+	return true
+}
+
+// defaultSocket returns the address of the Podman API service, as set by
+// CONTAINER_HOST or the default socket of the rootless or rootful service.
+func defaultSocket() string {
+	if v := os.Getenv(socketEnv); v != "" {
+		return v
+	}
+	if os.Geteuid() != 0 {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			return "unix://" + filepath.Join(dir, "podman", "podman.sock")
+		}
+	}
+	return "unix:///run/podman/podman.sock"
+}
+
+// parseSocket returns the host of the Docker compatible API client for a
+// socket address, which can be a path or a unix:// or tcp:// address.
+func parseSocket(s string) (string, error) {
+	if s == "" {
+		return "", errors.Errorf("invalid empty podman socket")
+	}
+	proto, addr, ok := strings.Cut(s, "://")
+	if !ok {
+		if !filepath.IsAbs(s) {
+			return "", errors.Errorf("invalid podman socket %q, expecting an absolute path", s)
+		}
+		return "unix://" + s, nil
+	}
+	switch proto {
+	case "unix", "tcp":
+		if addr == "" {
+			return "", errors.Errorf("invalid podman socket %q", s)
+		}
+		return s, nil
+	default:
+		return "", errors.Errorf("unsupported podman socket %q, only unix and tcp addresses are supported", s)
+	}
+}
@@ -0,0 +1,75 @@
+package podman
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/buildx/driver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSocket(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		expected string
+		err      string
+	}{
+		{in: "/run/podman/podman.sock", expected: "unix:///run/podman/podman.sock"},
+		{in: "unix:///run/user/1000/podman/podman.sock", expected: "unix:///run/user/1000/podman/podman.sock"},
+		{in: "tcp://127.0.0.1:8080", expected: "tcp://127.0.0.1:8080"},
+		{in: "", err: "invalid empty podman socket"},
+		{in: "podman.sock", err: "expecting an absolute path"},
+		{in: "unix://", err: "invalid podman socket"},
+		{in: "ssh://core@localhost/run/podman/podman.sock", err: "only unix and tcp addresses are supported"},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			host, err := parseSocket(tc.in)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, host)
+		})
+	}
+}
+
+func TestDefaultSocket(t *testing.T) {
+	t.Setenv(socketEnv, "unix:///tmp/podman.sock")
+	require.Equal(t, "unix:///tmp/podman.sock", defaultSocket())
+
+	t.Setenv(socketEnv, "")
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	socket := defaultSocket()
+	require.Contains(t, []string{"unix:///run/user/1000/podman/podman.sock", "unix:///run/podman/podman.sock"}, socket)
+}
+
+func TestFactoryNew(t *testing.T) {
+	f := &factory{}
+	opts := map[string]string{
+		"socket":  "/run/podman/podman.sock",
+		"network": "host",
+		"image":   "moby/buildkit:latest",
+	}
+	d, err := f.New(context.TODO(), driver.InitConfig{
+		Name:       driver.BuilderName("test"),
+		DriverOpts: opts,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "podman", d.Factory().Name())
+	require.NotNil(t, d.Config().DockerAPI)
+	require.NotContains(t, d.Config().DriverOpts, "socket")
+	require.Contains(t, opts, "socket")
+
+	_, err = f.New(context.TODO(), driver.InitConfig{
+		Name:       driver.BuilderName("test"),
+		DriverOpts: map[string]string{"foo": "bar"},
+	})
+	require.ErrorContains(t, err, "invalid driver option foo for podman driver")
+
+	_, err = f.New(context.TODO(), driver.InitConfig{
+		Name:       driver.BuilderName("test"),
+		DriverOpts: map[string]string{"socket": "ssh://localhost"},
+	})
+	require.ErrorContains(t, err, "unsupported podman socket")
+}
@@ -15,9 +15,10 @@ import (
 )
 
 const (
-	version  = 2
-	refsDir  = "refs"
-	groupDir = "__group__"
+	version    = 2
+	refsDir    = "refs"
+	groupDir   = "__group__"
+	targetsDir = "targets"
 )
 
 type State struct {
@@ -39,6 +40,14 @@ type StateGroup struct {
 	Refs []string
 }
 
+type TargetState struct {
+	// Fingerprint is the content digest of the resolved target and its
+	// inputs at the time of the last successful build
+	Fingerprint string
+	// ExporterResponse is the exporter response of the last successful build
+	ExporterResponse map[string]string `json:",omitempty"`
+}
+
 type LocalState struct {
 	cfg *confutil.Config
 }
@@ -111,11 +120,48 @@ func (ls *LocalState) SaveGroup(id string, stg StateGroup) error {
 	return ls.cfg.AtomicWriteFile(filepath.Join(refDir, id), dt, 0600)
 }
 
+// ReadTarget returns the state of the last successful build of a bake target
+// for the given builder. The key identifies the set of definitions the target
+// belongs to, typically a hash of the working directory.
+func (ls *LocalState) ReadTarget(builderName, key, target string) (*TargetState, error) {
+	if err := ls.validateTarget(builderName, key, target); err != nil {
+		return nil, err
+	}
+	dt, err := os.ReadFile(filepath.Join(ls.cfg.Dir(), targetsDir, builderName, key, target))
+	if err != nil {
+		return nil, err
+	}
+	var st TargetState
+	if err := json.Unmarshal(dt, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (ls *LocalState) SaveTarget(builderName, key, target string, st TargetState) error {
+	if err := ls.validateTarget(builderName, key, target); err != nil {
+		return err
+	}
+	dir := filepath.Join(targetsDir, builderName, key)
+	if err := ls.cfg.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	dt, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return ls.cfg.AtomicWriteFile(filepath.Join(dir, target), dt, 0600)
+}
+
 func (ls *LocalState) RemoveBuilder(builderName string) error {
 	if builderName == "" {
 		return errors.Errorf("builder name empty")
 	}
 
+	if err := os.RemoveAll(filepath.Join(ls.cfg.Dir(), targetsDir, builderName)); err != nil {
+		return err
+	}
+
 	dir := filepath.Join(ls.cfg.Dir(), refsDir, builderName)
 	if _, err := os.Lstat(dir); err != nil {
 		if !os.IsNotExist(err) {
@@ -231,6 +277,19 @@ func (ls *LocalState) validate(builderName, nodeName, id string) error {
 	return nil
 }
 
+func (ls *LocalState) validateTarget(builderName, key, target string) error {
+	if builderName == "" {
+		return errors.Errorf("builder name empty")
+	}
+	if key == "" {
+		return errors.Errorf("target key empty")
+	}
+	if target == "" {
+		return errors.Errorf("target name empty")
+	}
+	return nil
+}
+
 func (ls *LocalState) readVersion() int {
 	if vdt, err := os.ReadFile(filepath.Join(ls.cfg.Dir(), refsDir, "version")); err == nil {
 		if v, err := strconv.Atoi(string(vdt)); err == nil {
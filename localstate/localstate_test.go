@@ -1,6 +1,7 @@
 package localstate
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -26,9 +27,21 @@ func TestReadGroup(t *testing.T) {
 	require.Equal(t, testStateGroup, *g)
 }
 
+func TestReadTarget(t *testing.T) {
+	l := newls(t)
+	st, err := l.ReadTarget(testBuilderName, testTargetKey, "build")
+	require.NoError(t, err)
+	require.Equal(t, testTargetState, *st)
+
+	_, err = l.ReadTarget(testBuilderName, testTargetKey, "missing")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
 func TestRemoveBuilder(t *testing.T) {
 	l := newls(t)
 	require.NoError(t, l.RemoveBuilder(testBuilderName))
+	_, err := l.ReadTarget(testBuilderName, testTargetKey, "build")
+	require.ErrorIs(t, err, os.ErrNotExist)
 }
 
 func TestRemoveBuilderNode(t *testing.T) {
@@ -52,6 +65,8 @@ func newls(t *testing.T) *LocalState {
 	require.NoError(t, l.SaveRef(testBuilderName, testNodeName, testStateGroupRef2ID, testStateGroupRef2))
 	require.NoError(t, l.SaveRef(testBuilderName, testNodeName, testStateGroupRef3ID, testStateGroupRef3))
 
+	require.NoError(t, l.SaveTarget(testBuilderName, testTargetKey, "build", testTargetState))
+
 	return l
 }
 
@@ -95,4 +110,12 @@ var (
 		DockerfilePath: "/home/foo/github.com/docker/docker-bake-action/dev.Dockerfile",
 		GroupRef:       "kvqs0sgly2rmitz84r25u9qd0",
 	}
+
+	testTargetKey   = "5f1c9bc2e6e4a3e1b0f0d2b8a1c7e9d3f4a6b8c0d2e4f6a8b0c2d4e6f8a0b2c4"
+	testTargetState = TargetState{
+		Fingerprint: "sha256:0b8c2fb9b4d4bbd4e7bda7b6d01b0f1a3c1e5e0a9f2c4e6d8b0a2c4e6f8a0b2c",
+		ExporterResponse: map[string]string{
+			"containerimage.digest": "sha256:9f3e7b5f5c0e3fa5c8b5e1d6a4a2d5c8e1b2a3d4c5e6f7a8b9c0d1e2f3a4b5c6",
+		},
+	}
 )
@@ -87,6 +87,7 @@ var bakeTests = []func(t *testing.T, sb integration.Sandbox){
 	testBakeCallCheck,
 	testBakeCallCheckFlag,
 	testBakeCallMetadata,
+	testBakeDependsOnCheck,
 	testBakeMultiPlatform,
 	testBakeCheckCallOutput,
 	testBakeExtraHosts,
@@ -2606,6 +2607,44 @@ target "validate" {
 	require.Contains(t, out, "ConsistentInstructionCasing")
 }
 
+func testBakeDependsOnCheck(t *testing.T, sb integration.Sandbox) {
+	bakefile := []byte(`
+target "lint" {
+	call = "check"
+	dockerfile-inline = <<EOT
+FROM scratch
+COPy foo /foo
+EOT
+}
+
+target "app" {
+	depends_on = ["lint"]
+	dockerfile-inline = <<EOT
+FROM scratch
+COPY foo /foo
+EOT
+	output = ["type=local,dest=out"]
+}
+`)
+	dir := tmpdir(
+		t,
+		fstest.CreateFile("docker-bake.hcl", bakefile, 0600),
+		fstest.CreateFile("foo", []byte("foo"), 0600),
+	)
+
+	out, err := bakeCmd(
+		sb,
+		withDir(dir),
+		withArgs("app"),
+	)
+	require.Error(t, err, out)
+	require.Contains(t, out, "ConsistentInstructionCasing")
+	require.Contains(t, out, "dependency lint failed")
+
+	_, err = os.Stat(filepath.Join(dir, "out"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
 func testBakeCallCheckFlag(t *testing.T, sb integration.Sandbox) {
 	dockerfile := []byte(`
 FROM scratch
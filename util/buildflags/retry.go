@@ -0,0 +1,60 @@
+package buildflags
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RetryConfig configures how many times a target is re-solved after a
+// transient failure, how long to wait between attempts and which classes of
+// failures are retried.
+type RetryConfig struct {
+	Attempts *int64   `json:"attempts,omitempty"`
+	Backoff  *string  `json:"backoff,omitempty"`
+	On       []string `json:"on,omitempty"`
+}
+
+func (r *RetryConfig) Merge(other *RetryConfig) *RetryConfig {
+	if r == nil {
+		r = &RetryConfig{}
+	}
+	merged := *r
+	if other != nil {
+		if other.Attempts != nil {
+			merged.Attempts = other.Attempts
+		}
+		if other.Backoff != nil {
+			merged.Backoff = other.Backoff
+		}
+		if other.On != nil {
+			merged.On = other.On
+		}
+	}
+	return &merged
+}
+
+// SetField sets a single retry field by name, used by bake `--set` overrides.
+func (r *RetryConfig) SetField(name, value string) error {
+	switch name {
+	case "attempts":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.Errorf("invalid value %s for int64 key retry.%s", value, name)
+		}
+		r.Attempts = &n
+	case "backoff":
+		r.Backoff = &value
+	case "on":
+		r.On = nil
+		for v := range strings.SplitSeq(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				r.On = append(r.On, v)
+			}
+		}
+	default:
+		return errors.Errorf("unknown retry key %s", name)
+	}
+	return nil
+}
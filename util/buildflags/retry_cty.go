@@ -0,0 +1,77 @@
+package buildflags
+
+import (
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+var retryType = sync.OnceValue(func() cty.Type {
+	return cty.ObjectWithOptionalAttrs(
+		map[string]cty.Type{
+			"attempts": cty.Number,
+			"backoff":  cty.String,
+			"on":       cty.List(cty.String),
+		},
+		[]string{"attempts", "backoff", "on"},
+	)
+})
+
+func (r *RetryConfig) FromCtyValue(in cty.Value, p cty.Path) error {
+	conv, err := convert.Convert(in, retryType())
+	if err != nil {
+		return p.NewError(err)
+	}
+
+	if v := conv.GetAttr("attempts"); !v.IsNull() && v.IsKnown() {
+		var n int64
+		if err := gocty.FromCtyValue(v, &n); err != nil {
+			return p.NewError(err)
+		}
+		r.Attempts = &n
+	}
+	if v := conv.GetAttr("backoff"); !v.IsNull() && v.IsKnown() {
+		s := v.AsString()
+		r.Backoff = &s
+	}
+	if v := conv.GetAttr("on"); !v.IsNull() && v.IsKnown() {
+		var on []string
+		if err := gocty.FromCtyValue(v, &on); err != nil {
+			return p.NewError(err)
+		}
+		r.On = on
+	}
+	return nil
+}
+
+func (r *RetryConfig) ToCtyValue() cty.Value {
+	if r == nil {
+		return cty.NullVal(retryType())
+	}
+
+	vals := map[string]cty.Value{
+		"attempts": cty.NullVal(cty.Number),
+		"backoff":  cty.NullVal(cty.String),
+		"on":       cty.NullVal(cty.List(cty.String)),
+	}
+	if r.Attempts != nil {
+		vals["attempts"] = cty.NumberIntVal(*r.Attempts)
+	}
+	if r.Backoff != nil {
+		vals["backoff"] = cty.StringVal(*r.Backoff)
+	}
+	if r.On != nil {
+		on := make([]cty.Value, 0, len(r.On))
+		for _, v := range r.On {
+			on = append(on, cty.StringVal(v))
+		}
+		if len(on) == 0 {
+			vals["on"] = cty.ListValEmpty(cty.String)
+		} else {
+			vals["on"] = cty.ListVal(on)
+		}
+	}
+	return cty.ObjectVal(vals)
+}
@@ -0,0 +1,134 @@
+package bundle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/containerd/containerd/v2/core/content"
+	imgarchive "github.com/containerd/containerd/v2/core/images/archive"
+	"github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// Entry is a build record of a bundle.
+type Entry struct {
+	// Descriptor is the descriptor of the manifest of the record, with the
+	// annotations of the index of the bundle.
+	Descriptor ocispecs.Descriptor
+	Record     *Record
+}
+
+// Read imports the bundle read from r into store and returns its records.
+func Read(ctx context.Context, store content.Store, r io.Reader) ([]Entry, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read dockerbuild archive")
+	}
+	defer gz.Close()
+
+	desc, err := imgarchive.ImportIndex(ctx, store, gz)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read dockerbuild archive")
+	}
+
+	var layout ocispecs.Index
+	if err := readJSON(ctx, store, desc, &layout); err != nil {
+		return nil, err
+	}
+	if len(layout.Manifests) != 1 {
+		return nil, errors.Errorf("invalid dockerbuild archive: expected 1 manifest, got %d", len(layout.Manifests))
+	}
+
+	descs := layout.Manifests
+	switch desc := layout.Manifests[0]; desc.MediaType {
+	case ocispecs.MediaTypeImageManifest:
+	case ocispecs.MediaTypeImageIndex:
+		var idx ocispecs.Index
+		if err := readJSON(ctx, store, desc, &idx); err != nil {
+			return nil, err
+		}
+		descs = idx.Manifests
+	default:
+		return nil, errors.Errorf("invalid dockerbuild archive: unexpected media type %s", desc.MediaType)
+	}
+
+	entries := make([]Entry, 0, len(descs))
+	for _, desc := range descs {
+		var mfst ocispecs.Manifest
+		if err := readJSON(ctx, store, desc, &mfst); err != nil {
+			return nil, err
+		}
+		if mfst.Config.MediaType != HistoryRecordMediaTypeV0 {
+			return nil, errors.Errorf("invalid dockerbuild archive: unexpected config media type %s", mfst.Config.MediaType)
+		}
+		var rec Record
+		if err := readJSON(ctx, store, mfst.Config, &rec); err != nil {
+			return nil, err
+		}
+		if rec.BuildHistoryRecord == nil {
+			return nil, errors.Errorf("invalid dockerbuild archive: missing build record in %s", desc.Digest)
+		}
+		if desc.Annotations == nil {
+			desc.Annotations = recordAnnotations(&rec)
+		}
+		entries = append(entries, Entry{
+			Descriptor: desc,
+			Record:     &rec,
+		})
+	}
+	return entries, nil
+}
+
+// Write writes a bundle with the entries read from store to w.
+func Write(ctx context.Context, store content.Store, w io.Writer, entries []Entry) error {
+	if len(entries) == 0 {
+		return errors.New("no build records to write")
+	}
+
+	desc := entries[0].Descriptor
+	if len(entries) > 1 {
+		var idx ocispecs.Index
+		idx.MediaType = ocispecs.MediaTypeImageIndex
+		idx.SchemaVersion = 2
+		for _, e := range entries {
+			idx.Manifests = append(idx.Manifests, e.Descriptor)
+		}
+		dt, err := json.MarshalIndent(idx, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal index")
+		}
+		desc = ocispecs.Descriptor{
+			MediaType: idx.MediaType,
+			Digest:    digest.FromBytes(dt),
+			Size:      int64(len(dt)),
+		}
+		if err := content.WriteBlob(ctx, store, "index-"+desc.Digest.String(), bytes.NewReader(dt), desc); err != nil {
+			return errors.Wrap(err, "failed to write index")
+		}
+	} else {
+		desc.Annotations = nil
+	}
+
+	gz := gzip.NewWriter(w)
+	defer gz.Close()
+
+	if err := imgarchive.Export(ctx, store, gz, imgarchive.WithManifest(desc), imgarchive.WithSkipDockerManifest()); err != nil {
+		return errors.Wrap(err, "failed to create dockerbuild archive")
+	}
+	return nil
+}
+
+func readJSON(ctx context.Context, p content.Provider, desc ocispecs.Descriptor, v any) error {
+	dt, err := content.ReadBlob(ctx, p, desc)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", desc.Digest)
+	}
+	if err := json.Unmarshal(dt, v); err != nil {
+		return errors.Wrapf(err, "failed to unmarshal %s", desc.Digest)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package bundle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+	"time"
+
+	imgarchive "github.com/containerd/containerd/v2/core/images/archive"
+	"github.com/containerd/containerd/v2/plugins/content/local"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/util/contentutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestReadWrite(t *testing.T) {
+	ctx := context.TODO()
+
+	created := time.Date(2025, 4, 15, 12, 33, 0, 0, time.UTC)
+	var records []*Record
+	for _, ref := range []string{"ref1", "ref2", "ref3"} {
+		records = append(records, &Record{
+			BuildHistoryRecord: &controlapi.BuildHistoryRecord{
+				Ref:           ref,
+				CreatedAt:     timestamppb.New(created),
+				FrontendAttrs: map[string]string{"target": ref + "-target"},
+			},
+			Annotations: map[string]string{"builder": "default"},
+		})
+	}
+
+	mp := contentutil.NewMultiProvider(nil)
+	desc, err := export(ctx, mp, records)
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	require.NoError(t, imgarchive.Export(ctx, mp, gz, imgarchive.WithManifest(desc), imgarchive.WithSkipDockerManifest()))
+	require.NoError(t, gz.Close())
+
+	store, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+	entries, err := Read(ctx, store, buf)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for i, e := range entries {
+		require.Equal(t, records[i].Ref, e.Record.Ref)
+		require.Equal(t, records[i].Ref+"-target", e.Record.FrontendAttrs["target"])
+		require.Equal(t, records[i].Ref, e.Descriptor.Annotations[AnnotationRef])
+		require.Equal(t, "default", e.Descriptor.Annotations["builder"])
+		require.Equal(t, "2025-04-15T12:33:00Z", e.Descriptor.Annotations["org.opencontainers.image.created"])
+	}
+
+	for _, n := range []int{1, 2} {
+		buf := &bytes.Buffer{}
+		require.NoError(t, Write(ctx, store, buf, entries[1:1+n]))
+
+		store, err := local.NewStore(t.TempDir())
+		require.NoError(t, err)
+		selected, err := Read(ctx, store, buf)
+		require.NoError(t, err)
+		require.Len(t, selected, n)
+		for i, e := range selected {
+			require.Equal(t, entries[1+i].Record.Ref, e.Record.Ref)
+			require.Equal(t, entries[1+i].Record.Ref, e.Descriptor.Annotations[AnnotationRef])
+		}
+	}
+}
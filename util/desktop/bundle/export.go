@@ -6,6 +6,8 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"maps"
+	"time"
 
 	"github.com/containerd/containerd/v2/core/content"
 	"github.com/containerd/containerd/v2/core/content/proxy"
@@ -22,6 +24,17 @@ import (
 const (
 	HistoryRecordMediaTypeV0 = "application/vnd.buildkit.historyrecord.v0"
 	RefDescriptorMediaType   = "vnd.export-build.descriptor.mediatype"
+
+	// AnnotationRef is the annotation of the manifests in the index of a
+	// bundle with the ref of the build record.
+	AnnotationRef = "vnd.buildkit.history.reference"
+	// AnnotationBuilder, AnnotationNode, AnnotationName and AnnotationStatus
+	// are the annotations of the manifests in the index of a bundle with the
+	// builder, node, name and status of the build record.
+	AnnotationBuilder = "vnd.docker.buildx.history.builder"
+	AnnotationNode    = "vnd.docker.buildx.history.node"
+	AnnotationName    = "vnd.docker.buildx.history.name"
+	AnnotationStatus  = "vnd.docker.buildx.history.status"
 )
 
 type Record struct {
@@ -30,6 +43,10 @@ type Record struct {
 	DefaultPlatform string
 	LocalState      *localstate.State      `json:"localState,omitempty"`
 	StateGroup      *localstate.StateGroup `json:"stateGroup,omitempty"`
+
+	// Annotations are added to the manifest of the record in the index of
+	// the bundle to describe the record without reading it.
+	Annotations map[string]string `json:"-"`
 }
 
 func Export(ctx context.Context, c []*client.Client, w io.Writer, records []*Record) error {
@@ -84,10 +101,7 @@ func export(ctx context.Context, mp *contentutil.MultiProvider, records []*Recor
 		if err != nil {
 			return ocispecs.Descriptor{}, errors.Wrap(err, "failed to export record")
 		}
-		if desc.Annotations == nil {
-			desc.Annotations = make(map[string]string)
-		}
-		desc.Annotations["vnd.buildkit.history.reference"] = r.Ref
+		desc.Annotations = recordAnnotations(r)
 		idx.Manifests = append(idx.Manifests, desc)
 	}
 
@@ -99,6 +113,18 @@ func export(ctx context.Context, mp *contentutil.MultiProvider, records []*Recor
 	return desc, nil
 }
 
+// recordAnnotations returns the annotations of the manifest of the record in
+// the index of a bundle.
+func recordAnnotations(r *Record) map[string]string {
+	annotations := make(map[string]string, len(r.Annotations)+2)
+	maps.Copy(annotations, r.Annotations)
+	annotations[AnnotationRef] = r.Ref
+	if r.CreatedAt != nil {
+		annotations[ocispecs.AnnotationCreated] = r.CreatedAt.AsTime().Format(time.RFC3339Nano)
+	}
+	return annotations
+}
+
 func writeJSON(ctx context.Context, mp *contentutil.MultiProvider, mt string, data any) (ocispecs.Descriptor, error) {
 	dt, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
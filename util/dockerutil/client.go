@@ -37,7 +37,13 @@ func (c *Client) LoadImage(ctx context.Context, name string, status progress.Wri
 	if err != nil {
 		return nil, nil, err
 	}
+	w, cancel := LoadImageAPI(ctx, dapi, "docker", status)
+	return w, cancel, nil
+}
 
+// LoadImageAPI imports an image through a Docker compatible API. The engine
+// name is only used in the progress output.
+func LoadImageAPI(ctx context.Context, dapi dockerclient.APIClient, engine string, status progress.Writer) (io.WriteCloser, func()) {
 	pr, pw := io.Pipe()
 	done := make(chan struct{})
 
@@ -60,7 +66,7 @@ func (c *Client) LoadImage(ctx context.Context, name string, status progress.Wri
 			}
 
 			status = progress.ResetTime(status)
-			if err := progress.Wrap("importing to docker", status.Write, func(l progress.SubLogger) error {
+			if err := progress.Wrap("importing to "+engine, status.Write, func(l progress.SubLogger) error {
 				return fromReader(l, resp)
 			}); err != nil {
 				handleErr(err)
@@ -70,7 +76,7 @@ func (c *Client) LoadImage(ctx context.Context, name string, status progress.Wri
 	}
 	return w, func() {
 		pr.Close()
-	}, nil
+	}
 }
 
 func (c *Client) Features(ctx context.Context, name string) map[Feature]bool {
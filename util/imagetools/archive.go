@@ -0,0 +1,209 @@
+package imagetools
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	archiveDirMode  int64 = 0o755
+	archiveFileMode int64 = 0o644
+)
+
+// Save copies an image with its referrers to an OCI layout and writes the
+// layout as a tar archive.
+func (r *Resolver) Save(ctx context.Context, src *Location, w io.Writer) (ocispecs.Descriptor, error) {
+	dt, desc, err := r.Get(ctx, src.String())
+	if err != nil {
+		return ocispecs.Descriptor{}, err
+	}
+
+	dir, err := os.MkdirTemp("", "buildx-imagetools-save-")
+	if err != nil {
+		return ocispecs.Descriptor{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	tag := src.Tag()
+	if tag == "" {
+		tag = "latest"
+	}
+	dest, err := ParseLocation("oci-layout://" + dir + ":" + tag)
+	if err != nil {
+		return ocispecs.Descriptor{}, err
+	}
+	if err := r.Copy(ctx, &Source{Ref: src, Desc: desc}, dest); err != nil {
+		return ocispecs.Descriptor{}, errors.Wrapf(err, "failed to copy %s", src)
+	}
+	if err := r.Push(ctx, dest, desc, dt); err != nil {
+		return ocispecs.Descriptor{}, err
+	}
+	if err := WriteOCIArchive(w, dir); err != nil {
+		return ocispecs.Descriptor{}, err
+	}
+	return desc, nil
+}
+
+// WriteOCIArchive writes the OCI layout of a directory as a tar archive.
+// Entries carry no timestamps or ownership, and the content store ingest
+// directory is left out.
+func WriteOCIArchive(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	for _, name := range []string{ocispecs.ImageLayoutFile, ocispecs.ImageIndexFile} {
+		if err := writeArchiveFile(tw, dir, name); err != nil {
+			return err
+		}
+	}
+	err := fs.WalkDir(os.DirFS(dir), ocispecs.ImageBlobsDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return tw.WriteHeader(&tar.Header{
+				Name:     name + "/",
+				Typeflag: tar.TypeDir,
+				Mode:     archiveDirMode,
+			})
+		}
+		return writeArchiveFile(tw, dir, name)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to archive %s", dir)
+	}
+	return tw.Close()
+}
+
+func writeArchiveFile(tw *tar.Writer, dir, name string) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return errors.Errorf("%s is not a regular file", name)
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     archiveFileMode,
+		Size:     fi.Size(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// ReadOCIArchive extracts an OCI layout tar archive to a directory and
+// verifies the digest of every blob. It returns the location of the image
+// of the layout, which must have a single one.
+func ReadOCIArchive(r io.Reader, dir string) (*Location, error) {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read archive")
+		}
+		name := strings.TrimSuffix(path.Clean(hdr.Name), "/")
+		if !fs.ValidPath(name) || strings.Contains(hdr.Name, `\`) {
+			return nil, errors.Errorf("invalid archive entry %q", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			if err := extractArchiveFile(tr, name, target); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.Errorf("unsupported archive entry %q of type %q", hdr.Name, hdr.Typeflag)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ocispecs.ImageLayoutFile)); err != nil {
+		return nil, errors.Wrapf(err, "archive is not an OCI layout")
+	}
+	dt, err := os.ReadFile(filepath.Join(dir, ocispecs.ImageIndexFile))
+	if err != nil {
+		return nil, errors.Wrapf(err, "archive is not an OCI layout")
+	}
+	var idx ocispecs.Index
+	if err := json.Unmarshal(dt, &idx); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", ocispecs.ImageIndexFile)
+	}
+
+	// referrers of the image can be recorded as entries of index.json, they
+	// are copied together with the image
+	var root digest.Digest
+	for _, m := range idx.Manifests {
+		if hasSubjectAnnotation(m) || m.Digest == root {
+			continue
+		}
+		if root != "" {
+			return nil, errors.Errorf("archive contains more than one image")
+		}
+		root = m.Digest
+	}
+	if root == "" {
+		return nil, errors.Errorf("archive does not contain any image")
+	}
+	return ParseLocation("oci-layout://" + dir + "@" + root.String())
+}
+
+// extractArchiveFile writes a file of an archive. Blobs are verified against
+// the digest of their path while being written.
+func extractArchiveFile(r io.Reader, name, target string) error {
+	var verifier digest.Verifier
+	if rest, ok := strings.CutPrefix(name, ocispecs.ImageBlobsDir+"/"); ok {
+		alg, encoded, ok := strings.Cut(rest, "/")
+		if !ok {
+			return errors.Errorf("invalid blob path %q", name)
+		}
+		dgst := digest.NewDigestFromEncoded(digest.Algorithm(alg), encoded)
+		if err := dgst.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid blob path %q", name)
+		}
+		verifier = dgst.Verifier()
+		r = io.TeeReader(r, verifier)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to extract %s", name)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if verifier != nil && !verifier.Verified() {
+		return errors.Errorf("digest mismatch for blob %s", name)
+	}
+	return nil
+}
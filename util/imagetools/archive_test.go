@@ -0,0 +1,87 @@
+package imagetools
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveLoadArchive(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.TODO()
+	loc, desc := newTestIndex(t)
+
+	signer, _ := newECDSAKey(t)
+	signed, err := New(Opt{}).Sign(ctx, loc.String(), signer)
+	require.NoError(t, err)
+	require.NotEmpty(t, signed)
+
+	var buf bytes.Buffer
+	saved, err := New(Opt{}).Save(ctx, loc, &buf)
+	require.NoError(t, err)
+	require.Equal(t, desc.Digest, saved.Digest)
+
+	loaded, err := ReadOCIArchive(&buf, t.TempDir())
+	require.NoError(t, err)
+	require.Equal(t, desc.Digest, loaded.Digest())
+
+	r := New(Opt{})
+	_, d, err := r.Get(ctx, loaded.String())
+	require.NoError(t, err)
+	require.Equal(t, desc.Digest, d.Digest)
+
+	refs, err := r.FetchReferrers(ctx, loaded, signed[0].Subject.Digest)
+	require.NoError(t, err)
+	var found bool
+	for _, ref := range refs {
+		if ref.Digest == signed[0].Signature.Digest {
+			found = true
+		}
+	}
+	require.True(t, found, "signature not found in loaded archive")
+}
+
+func TestReadOCIArchiveInvalid(t *testing.T) {
+	t.Parallel()
+
+	writeTar := func(files map[string][]byte) *bytes.Buffer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for name, dt := range files {
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(dt))}))
+			_, err := tw.Write(dt)
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		return &buf
+	}
+	layout := []byte(`{"imageLayoutVersion":"1.0.0"}`)
+
+	_, err := ReadOCIArchive(writeTar(map[string][]byte{
+		"../evil": []byte("foo"),
+	}), t.TempDir())
+	require.ErrorContains(t, err, "invalid archive entry")
+
+	_, err = ReadOCIArchive(writeTar(map[string][]byte{
+		ocispecs.ImageLayoutFile:                             layout,
+		"blobs/sha256/" + digest.FromString("foo").Encoded(): []byte("bar"),
+	}), t.TempDir())
+	require.ErrorContains(t, err, "digest mismatch")
+
+	_, err = ReadOCIArchive(writeTar(map[string][]byte{
+		ocispecs.ImageLayoutFile: layout,
+		ocispecs.ImageIndexFile:  []byte(`{"schemaVersion":2,"manifests":[]}`),
+	}), t.TempDir())
+	require.ErrorContains(t, err, "does not contain any image")
+
+	_, err = ReadOCIArchive(writeTar(map[string][]byte{
+		ocispecs.ImageIndexFile: []byte(`{"schemaVersion":2,"manifests":[]}`),
+	}), t.TempDir())
+	require.ErrorContains(t, err, "not an OCI layout")
+}
@@ -0,0 +1,83 @@
+package imagetools
+
+import (
+	"context"
+	"sync"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/errdefs"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// CopyStats counts the content written through an ingester returned by
+// WithCopyStats.
+type CopyStats struct {
+	mu sync.Mutex
+
+	// Copied is the number of manifests and blobs written to the destination.
+	Copied int
+	// Skipped is the number of manifests and blobs that already existed in
+	// the destination.
+	Skipped int
+	// Size is the total size of the content written to the destination.
+	Size int64
+}
+
+func (s *CopyStats) add(copied bool, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if copied {
+		s.Copied++
+		s.Size += size
+	} else {
+		s.Skipped++
+	}
+}
+
+// WithCopyStats wraps an ingester to count the content copied to it and the
+// content skipped because it already exists.
+func WithCopyStats(ingester content.Ingester) (content.Ingester, *CopyStats) {
+	stats := &CopyStats{}
+	return &statsIngester{Ingester: ingester, stats: stats}, stats
+}
+
+type statsIngester struct {
+	content.Ingester
+	stats *CopyStats
+}
+
+func (i *statsIngester) Writer(ctx context.Context, opts ...content.WriterOpt) (content.Writer, error) {
+	var wOpts content.WriterOpts
+	for _, opt := range opts {
+		if err := opt(&wOpts); err != nil {
+			return nil, err
+		}
+	}
+	w, err := i.Ingester.Writer(ctx, opts...)
+	if err != nil {
+		if errdefs.IsAlreadyExists(err) {
+			i.stats.add(false, 0)
+		}
+		return nil, err
+	}
+	return &statsWriter{Writer: w, stats: i.stats, size: wOpts.Desc.Size}, nil
+}
+
+type statsWriter struct {
+	content.Writer
+	stats *CopyStats
+	size  int64
+}
+
+func (w *statsWriter) Commit(ctx context.Context, size int64, expected digest.Digest, opts ...content.Opt) error {
+	err := w.Writer.Commit(ctx, size, expected, opts...)
+	if err == nil {
+		if size <= 0 {
+			size = w.size
+		}
+		w.stats.add(true, size)
+	} else if errdefs.IsAlreadyExists(err) {
+		w.stats.add(false, 0)
+	}
+	return err
+}
@@ -0,0 +1,34 @@
+package imagetools
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/plugins/content/local"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCopyStats(t *testing.T) {
+	ctx := context.TODO()
+
+	store, err := local.NewStore(t.TempDir())
+	require.NoError(t, err)
+	ingester, stats := WithCopyStats(store)
+
+	for _, dt := range [][]byte{[]byte("foo"), []byte("barbaz"), []byte("foo")} {
+		desc := ocispecs.Descriptor{
+			MediaType: ocispecs.MediaTypeImageLayer,
+			Digest:    digest.FromBytes(dt),
+			Size:      int64(len(dt)),
+		}
+		require.NoError(t, content.WriteBlob(ctx, ingester, desc.Digest.String(), bytes.NewReader(dt), desc))
+	}
+
+	require.Equal(t, 2, stats.Copied)
+	require.Equal(t, 1, stats.Skipped)
+	require.Equal(t, int64(9), stats.Size)
+}
@@ -0,0 +1,317 @@
+package imagetools
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	DiffStatusAdded     = "added"
+	DiffStatusRemoved   = "removed"
+	DiffStatusChanged   = "changed"
+	DiffStatusUnchanged = "unchanged"
+)
+
+// ImageDiff is the difference between two images or indexes.
+type ImageDiff struct {
+	Base      string         `json:"base"`
+	Compare   string         `json:"compare"`
+	Platforms []PlatformDiff `json:"platforms"`
+}
+
+// PlatformDiff is the difference between the images of a platform.
+type PlatformDiff struct {
+	Platform string `json:"platform"`
+	// Status is one of added, removed, changed or unchanged.
+	Status        string        `json:"status"`
+	BaseDigest    digest.Digest `json:"baseDigest,omitempty"`
+	CompareDigest digest.Digest `json:"compareDigest,omitempty"`
+
+	Config    []ValueDiff `json:"config,omitempty"`
+	Env       []ValueDiff `json:"env,omitempty"`
+	Labels    []ValueDiff `json:"labels,omitempty"`
+	Layers    *LayersDiff `json:"layers,omitempty"`
+	Packages  []ValueDiff `json:"packages,omitempty"`
+	Materials []ValueDiff `json:"materials,omitempty"`
+}
+
+// ValueDiff is a value that differs between two images. Base or Compare is
+// nil if the value is only set in one of them.
+type ValueDiff struct {
+	Name    string  `json:"name"`
+	Base    *string `json:"base,omitempty"`
+	Compare *string `json:"compare,omitempty"`
+}
+
+// LayersDiff is the difference between the layer lists of two images. Layers
+// are compared in order, the layers after the first different one are
+// reported as removed and added.
+type LayersDiff struct {
+	Shared  int                   `json:"shared"`
+	Removed []ocispecs.Descriptor `json:"removed,omitempty"`
+	Added   []ocispecs.Descriptor `json:"added,omitempty"`
+}
+
+// Diff compares the platforms, configs, layers, SBOM packages and provenance
+// materials of two images.
+func (r *Resolver) Diff(ctx context.Context, base, compare string) (*ImageDiff, error) {
+	var results [2]*result
+	eg, ctx := errgroup.WithContext(ctx)
+	for i, ref := range []string{base, compare} {
+		eg.Go(func() error {
+			res, err := newLoader(r).Load(ctx, ref)
+			if err != nil {
+				return err
+			}
+			results[i] = res
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	out := &ImageDiff{
+		Base:    base,
+		Compare: compare,
+	}
+	for _, p := range mergedPlatforms(results[0], results[1]) {
+		d, err := diffPlatform(p, results[0], results[1])
+		if err != nil {
+			return nil, err
+		}
+		out.Platforms = append(out.Platforms, d)
+	}
+	return out, nil
+}
+
+func mergedPlatforms(a, b *result) []string {
+	pp := slices.Concat(a.platforms, b.platforms)
+	slices.Sort(pp)
+	return slices.Compact(pp)
+}
+
+func diffPlatform(p string, base, compare *result) (PlatformDiff, error) {
+	out := PlatformDiff{
+		Platform:      p,
+		BaseDigest:    base.images[p],
+		CompareDigest: compare.images[p],
+	}
+	switch {
+	case out.BaseDigest == "":
+		out.Status = DiffStatusAdded
+		return out, nil
+	case out.CompareDigest == "":
+		out.Status = DiffStatusRemoved
+		return out, nil
+	case out.BaseDigest == out.CompareDigest:
+		out.Status = DiffStatusUnchanged
+		return out, nil
+	}
+	out.Status = DiffStatusChanged
+
+	baseConfig, compareConfig := base.assets[p].config, compare.assets[p].config
+	if baseConfig != nil && compareConfig != nil {
+		out.Config = diffValues(configValues(baseConfig), configValues(compareConfig))
+		out.Env = diffValues(envValues(baseConfig.Config.Env), envValues(compareConfig.Config.Env))
+		out.Labels = diffValues(baseConfig.Config.Labels, compareConfig.Config.Labels)
+	}
+
+	out.Layers = diffLayers(base.manifests[out.BaseDigest].manifest.Layers, compare.manifests[out.CompareDigest].manifest.Layers)
+
+	basePkgs, err := platformPackages(base, p)
+	if err != nil {
+		return out, err
+	}
+	comparePkgs, err := platformPackages(compare, p)
+	if err != nil {
+		return out, err
+	}
+	out.Packages = diffValues(basePkgs, comparePkgs)
+
+	baseMaterials, err := platformMaterials(base, p)
+	if err != nil {
+		return out, err
+	}
+	compareMaterials, err := platformMaterials(compare, p)
+	if err != nil {
+		return out, err
+	}
+	out.Materials = diffValues(baseMaterials, compareMaterials)
+
+	return out, nil
+}
+
+// configValues returns the execution parameters of an image config that are
+// compared, other than the environment and labels.
+func configValues(img *ocispecs.Image) map[string]string {
+	out := map[string]string{}
+	set := func(k string, v []string) {
+		if len(v) > 0 {
+			dt, _ := json.Marshal(v)
+			out[k] = string(dt)
+		}
+	}
+	set("Entrypoint", img.Config.Entrypoint)
+	set("Cmd", img.Config.Cmd)
+	if img.Config.User != "" {
+		out["User"] = img.Config.User
+	}
+	if img.Config.WorkingDir != "" {
+		out["WorkingDir"] = img.Config.WorkingDir
+	}
+	if len(img.Config.ExposedPorts) > 0 {
+		out["ExposedPorts"] = strings.Join(slices.Sorted(maps.Keys(img.Config.ExposedPorts)), " ")
+	}
+	return out
+}
+
+func envValues(env []string) map[string]string {
+	out := make(map[string]string, len(env))
+	for _, e := range env {
+		k, v, _ := strings.Cut(e, "=")
+		out[k] = v
+	}
+	return out
+}
+
+func diffLayers(base, compare []ocispecs.Descriptor) *LayersDiff {
+	out := &LayersDiff{}
+	for out.Shared < len(base) && out.Shared < len(compare) && base[out.Shared].Digest == compare[out.Shared].Digest {
+		out.Shared++
+	}
+	out.Removed = base[out.Shared:]
+	out.Added = compare[out.Shared:]
+	return out
+}
+
+// platformPackages returns the versions of the packages in the SBOMs of the
+// image of a platform, by package name.
+func platformPackages(r *result, p string) (map[string]string, error) {
+	a, ok := r.assets[p]
+	if !ok || a.deferredSbom == nil {
+		return nil, nil
+	}
+	sbom, err := a.deferredSbom()
+	if err != nil || sbom == nil {
+		return nil, err
+	}
+	return sbomPackages(sbom), nil
+}
+
+func sbomPackages(sbom *sbomStub) map[string]string {
+	versions := map[string][]string{}
+	for _, doc := range append([]any{sbom.SPDX}, sbom.AdditionalSPDXs...) {
+		var spdx struct {
+			Packages []struct {
+				Name        string `json:"name"`
+				VersionInfo string `json:"versionInfo"`
+			} `json:"packages"`
+		}
+		if !remarshal(doc, &spdx) {
+			continue
+		}
+		for _, pkg := range spdx.Packages {
+			if pkg.Name == "" {
+				continue
+			}
+			if !slices.Contains(versions[pkg.Name], pkg.VersionInfo) {
+				versions[pkg.Name] = append(versions[pkg.Name], pkg.VersionInfo)
+			}
+		}
+	}
+	out := make(map[string]string, len(versions))
+	for name, vv := range versions {
+		slices.Sort(vv)
+		out[name] = strings.Join(vv, ", ")
+	}
+	return out
+}
+
+// platformMaterials returns the digests of the materials in the provenance
+// of the image of a platform, by material URI.
+func platformMaterials(r *result, p string) (map[string]string, error) {
+	a, ok := r.assets[p]
+	if !ok || a.deferredProvenance == nil {
+		return nil, nil
+	}
+	prv, err := a.deferredProvenance()
+	if err != nil || prv == nil {
+		return nil, err
+	}
+	return provenanceMaterials(prv), nil
+}
+
+func provenanceMaterials(prv *provenanceStub) map[string]string {
+	type material struct {
+		URI    string            `json:"uri"`
+		Digest map[string]string `json:"digest"`
+	}
+	var slsa struct {
+		// SLSA v0.2
+		Materials []material `json:"materials"`
+		// SLSA v1
+		BuildDefinition struct {
+			ResolvedDependencies []material `json:"resolvedDependencies"`
+		} `json:"buildDefinition"`
+	}
+	if !remarshal(prv.SLSA, &slsa) {
+		return nil
+	}
+	out := map[string]string{}
+	for _, m := range slices.Concat(slsa.Materials, slsa.BuildDefinition.ResolvedDependencies) {
+		var dgsts []string
+		for _, alg := range slices.Sorted(maps.Keys(m.Digest)) {
+			dgsts = append(dgsts, alg+":"+m.Digest[alg])
+		}
+		out[m.URI] = strings.Join(dgsts, ", ")
+	}
+	return out
+}
+
+func diffValues(base, compare map[string]string) []ValueDiff {
+	keys := slices.Collect(maps.Keys(base))
+	for k := range compare {
+		if _, ok := base[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	var out []ValueDiff
+	for _, k := range keys {
+		b, okb := base[k]
+		c, okc := compare[k]
+		if okb && okc && b == c {
+			continue
+		}
+		d := ValueDiff{Name: k}
+		if okb {
+			d.Base = &b
+		}
+		if okc {
+			d.Compare = &c
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// remarshal decodes a generic JSON value into v.
+func remarshal(in any, v any) bool {
+	if in == nil {
+		return false
+	}
+	dt, err := json.Marshal(in)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(dt, v) == nil
+}
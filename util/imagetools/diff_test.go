@@ -0,0 +1,59 @@
+package imagetools
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffLayers(t *testing.T) {
+	layer := func(s string) ocispecs.Descriptor {
+		return ocispecs.Descriptor{Digest: digest.FromString(s)}
+	}
+
+	d := diffLayers(
+		[]ocispecs.Descriptor{layer("a"), layer("b"), layer("c")},
+		[]ocispecs.Descriptor{layer("a"), layer("b"), layer("d"), layer("e")},
+	)
+	require.Equal(t, 2, d.Shared)
+	require.Equal(t, []ocispecs.Descriptor{layer("c")}, d.Removed)
+	require.Equal(t, []ocispecs.Descriptor{layer("d"), layer("e")}, d.Added)
+}
+
+func TestDiffValues(t *testing.T) {
+	d := diffValues(
+		map[string]string{"PATH": "/bin", "GOVERSION": "1.23.0", "FOO": "bar"},
+		map[string]string{"PATH": "/bin", "GOVERSION": "1.23.1", "BAZ": "qux"},
+	)
+	dt, err := json.Marshal(d)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"name": "BAZ", "compare": "qux"},
+		{"name": "FOO", "base": "bar"},
+		{"name": "GOVERSION", "base": "1.23.0", "compare": "1.23.1"}
+	]`, string(dt))
+}
+
+func TestSBOMPackages(t *testing.T) {
+	var spdx, additional any
+	require.NoError(t, json.Unmarshal([]byte(`{"packages": [{"name": "musl", "versionInfo": "1.2.5"}, {"name": "busybox", "versionInfo": "1.36.1"}]}`), &spdx))
+	require.NoError(t, json.Unmarshal([]byte(`{"packages": [{"name": "musl", "versionInfo": "1.2.4"}, {"name": "musl", "versionInfo": "1.2.5"}]}`), &additional))
+
+	pkgs := sbomPackages(&sbomStub{SPDX: spdx, AdditionalSPDXs: []any{additional}})
+	require.Equal(t, map[string]string{
+		"busybox": "1.36.1",
+		"musl":    "1.2.4, 1.2.5",
+	}, pkgs)
+}
+
+func TestProvenanceMaterials(t *testing.T) {
+	var v02, v1 any
+	require.NoError(t, json.Unmarshal([]byte(`{"materials": [{"uri": "pkg:docker/alpine@3.20", "digest": {"sha256": "aaa"}}]}`), &v02))
+	require.NoError(t, json.Unmarshal([]byte(`{"buildDefinition": {"resolvedDependencies": [{"uri": "pkg:docker/alpine@3.20", "digest": {"sha256": "bbb"}}]}}`), &v1))
+
+	require.Equal(t, map[string]string{"pkg:docker/alpine@3.20": "sha256:aaa"}, provenanceMaterials(&provenanceStub{SLSA: v02}))
+	require.Equal(t, map[string]string{"pkg:docker/alpine@3.20": "sha256:bbb"}, provenanceMaterials(&provenanceStub{SLSA: v1}))
+}
@@ -0,0 +1,188 @@
+package imagetools
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/platforms"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// EditOpt are the changes applied to an index by Edit.
+type EditOpt struct {
+	// RemovePlatforms are the platforms removed from the index, together
+	// with their attestation manifests.
+	RemovePlatforms []ocispecs.Platform
+	// DropAttestations removes all the attestation manifests from the index.
+	DropAttestations bool
+	// Annotations are set on the index or on the manifest descriptors.
+	Annotations map[exptypes.AnnotationKey]string
+	// RemoveAnnotations are removed from the index or from the manifest
+	// descriptors.
+	RemoveAnnotations []exptypes.AnnotationKey
+}
+
+// Edit rewrites the index of a source. It returns the new index and the
+// manifests it references, which need to be copied with the index.
+func (r *Resolver) Edit(ctx context.Context, src *Source, opt EditOpt) ([]byte, ocispecs.Descriptor, []DescWithSource, error) {
+	dt, desc, srcMap, err := r.combine(ctx, []*Source{src}, nil, false)
+	if err != nil {
+		return nil, ocispecs.Descriptor{}, nil, err
+	}
+	if !images.IsIndexType(desc.MediaType) {
+		return nil, ocispecs.Descriptor{}, nil, errors.Errorf("%s is not an image index", src.Ref)
+	}
+
+	// keep the platforms that are not removed, filterPlatforms takes care of
+	// removing the attestation manifests of the removed ones
+	var keep []ocispecs.Platform
+	if len(opt.RemovePlatforms) > 0 {
+		var idx ocispecs.Index
+		if err := json.Unmarshal(dt, &idx); err != nil {
+			return nil, ocispecs.Descriptor{}, nil, errors.Wrapf(err, "failed to parse index")
+		}
+		remove := platforms.Any(opt.RemovePlatforms...)
+		removed := false
+		for _, m := range idx.Manifests {
+			if m.Platform == nil || isAttestationManifest(m) {
+				continue
+			}
+			if remove.Match(*m.Platform) {
+				removed = true
+				continue
+			}
+			keep = append(keep, *m.Platform)
+		}
+		if !removed {
+			return nil, ocispecs.Descriptor{}, nil, errors.Errorf("none of the platforms to remove are in %s", src.Ref)
+		}
+		if len(keep) == 0 {
+			return nil, ocispecs.Descriptor{}, nil, errors.Errorf("cannot remove all the platforms of %s", src.Ref)
+		}
+	}
+	dt, desc, manifests, err := r.filterPlatforms(ctx, dt, desc, srcMap, keep)
+	if err != nil {
+		return nil, ocispecs.Descriptor{}, nil, err
+	}
+	var idx ocispecs.Index
+	if err := json.Unmarshal(dt, &idx); err != nil {
+		return nil, ocispecs.Descriptor{}, nil, errors.Wrapf(err, "failed to parse index")
+	}
+
+	changed := false
+	if opt.DropAttestations {
+		var mfsts []ocispecs.Descriptor
+		for _, m := range idx.Manifests {
+			if !isAttestationManifest(m) {
+				mfsts = append(mfsts, m)
+			}
+		}
+		changed = len(mfsts) != len(idx.Manifests)
+		idx.Manifests = mfsts
+
+		var kept []DescWithSource
+		for _, m := range manifests {
+			if !isAttestationManifest(m.Descriptor) {
+				kept = append(kept, m)
+			}
+		}
+		manifests = kept
+	}
+
+	if len(opt.Annotations) > 0 || len(opt.RemoveAnnotations) > 0 {
+		if err := editAnnotations(&idx, desc.MediaType, opt); err != nil {
+			return nil, ocispecs.Descriptor{}, nil, err
+		}
+		changed = true
+	}
+	if !changed {
+		return dt, desc, manifests, nil
+	}
+
+	// descriptors of the referenced manifests are copied from the index so
+	// that they carry the edited annotations
+	descs := make(map[digest.Digest]ocispecs.Descriptor, len(idx.Manifests))
+	for _, m := range idx.Manifests {
+		descs[m.Digest] = m
+	}
+	for i, m := range manifests {
+		if d, ok := descs[m.Digest]; ok {
+			manifests[i].Descriptor = d
+		}
+	}
+
+	dt, err = json.MarshalIndent(&idx, "", "  ")
+	if err != nil {
+		return nil, ocispecs.Descriptor{}, nil, errors.Wrap(err, "failed to marshal index")
+	}
+	return dt, ocispecs.Descriptor{
+		MediaType: desc.MediaType,
+		Size:      int64(len(dt)),
+		Digest:    digest.FromBytes(dt),
+	}, manifests, nil
+}
+
+// editAnnotations sets and removes the index and manifest descriptor
+// annotations of an index. Annotations are only allowed on OCI indexes.
+func editAnnotations(idx *ocispecs.Index, mt string, opt EditOpt) error {
+	if mt != ocispecs.MediaTypeImageIndex {
+		return errors.Errorf("annotations are only supported on OCI indexes, not %s", mt)
+	}
+
+	matchDesc := func(k exptypes.AnnotationKey, d ocispecs.Descriptor) bool {
+		return k.Platform == nil || (d.Platform != nil && k.PlatformString() == platforms.Format(*d.Platform))
+	}
+	checkType := func(k exptypes.AnnotationKey) error {
+		switch k.Type {
+		case exptypes.AnnotationIndex, exptypes.AnnotationManifestDescriptor:
+			return nil
+		case exptypes.AnnotationManifest, "":
+			return errors.Errorf("%q annotations are not supported yet", k.Type)
+		default:
+			return errors.Errorf("%q annotations are invalid while editing an image", k.Type)
+		}
+	}
+
+	for _, k := range opt.RemoveAnnotations {
+		if err := checkType(k); err != nil {
+			return err
+		}
+		switch k.Type {
+		case exptypes.AnnotationIndex:
+			delete(idx.Annotations, k.Key)
+		case exptypes.AnnotationManifestDescriptor:
+			for i, d := range idx.Manifests {
+				if matchDesc(k, d) {
+					delete(idx.Manifests[i].Annotations, k.Key)
+				}
+			}
+		}
+	}
+	for k, v := range opt.Annotations {
+		if err := checkType(k); err != nil {
+			return err
+		}
+		switch k.Type {
+		case exptypes.AnnotationIndex:
+			if idx.Annotations == nil {
+				idx.Annotations = map[string]string{}
+			}
+			idx.Annotations[k.Key] = v
+		case exptypes.AnnotationManifestDescriptor:
+			for i, d := range idx.Manifests {
+				if !matchDesc(k, d) {
+					continue
+				}
+				if idx.Manifests[i].Annotations == nil {
+					idx.Manifests[i].Annotations = map[string]string{}
+				}
+				idx.Manifests[i].Annotations[k.Key] = v
+			}
+		}
+	}
+	return nil
+}
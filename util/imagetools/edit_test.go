@@ -0,0 +1,153 @@
+package imagetools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/content"
+	contentlocal "github.com/containerd/containerd/v2/plugins/content/local"
+	"github.com/moby/buildkit/client/ociindex"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/util/attestation"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEdit(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.TODO()
+	loc, desc := newTestIndex(t)
+	src := &Source{Ref: loc, Desc: desc}
+
+	parse := func(dt []byte) ocispecs.Index {
+		var idx ocispecs.Index
+		require.NoError(t, json.Unmarshal(dt, &idx))
+		return idx
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		_, d, manifests, err := New(Opt{}).Edit(ctx, src, EditOpt{})
+		require.NoError(t, err)
+		require.Equal(t, desc.Digest, d.Digest)
+		require.Len(t, manifests, 3)
+	})
+
+	t.Run("remove platform", func(t *testing.T) {
+		dt, d, manifests, err := New(Opt{}).Edit(ctx, src, EditOpt{
+			RemovePlatforms: []ocispecs.Platform{{OS: "linux", Architecture: "arm64"}},
+		})
+		require.NoError(t, err)
+		require.NotEqual(t, desc.Digest, d.Digest)
+		idx := parse(dt)
+		require.Len(t, idx.Manifests, 2)
+		require.Equal(t, "amd64", idx.Manifests[0].Platform.Architecture)
+		require.True(t, isAttestationManifest(idx.Manifests[1]))
+		require.Len(t, manifests, 2)
+	})
+
+	t.Run("drop attestations", func(t *testing.T) {
+		dt, _, manifests, err := New(Opt{}).Edit(ctx, src, EditOpt{DropAttestations: true})
+		require.NoError(t, err)
+		idx := parse(dt)
+		require.Len(t, idx.Manifests, 2)
+		for _, m := range idx.Manifests {
+			require.False(t, isAttestationManifest(m))
+		}
+		require.Len(t, manifests, 2)
+	})
+
+	t.Run("annotations", func(t *testing.T) {
+		dt, _, manifests, err := New(Opt{}).Edit(ctx, src, EditOpt{
+			Annotations: map[exptypes.AnnotationKey]string{
+				{Type: exptypes.AnnotationIndex, Key: "foo"}: "bar",
+				{Type: exptypes.AnnotationManifestDescriptor, Key: "baz", Platform: &ocispecs.Platform{OS: "linux", Architecture: "amd64"}}: "qux",
+			},
+			RemoveAnnotations: []exptypes.AnnotationKey{
+				{Type: exptypes.AnnotationIndex, Key: "org.opencontainers.image.created"},
+			},
+		})
+		require.NoError(t, err)
+		idx := parse(dt)
+		require.Equal(t, map[string]string{"foo": "bar"}, idx.Annotations)
+		require.Equal(t, "qux", idx.Manifests[0].Annotations["baz"])
+		require.NotContains(t, idx.Manifests[1].Annotations, "baz")
+		require.Equal(t, "qux", manifests[0].Annotations["baz"])
+
+		_, _, _, err = New(Opt{}).Edit(ctx, src, EditOpt{
+			Annotations: map[exptypes.AnnotationKey]string{{Type: exptypes.AnnotationManifest, Key: "foo"}: "bar"},
+		})
+		require.ErrorContains(t, err, "not supported")
+	})
+
+	t.Run("invalid platforms", func(t *testing.T) {
+		_, _, _, err := New(Opt{}).Edit(ctx, src, EditOpt{
+			RemovePlatforms: []ocispecs.Platform{{OS: "linux", Architecture: "s390x"}},
+		})
+		require.ErrorContains(t, err, "none of the platforms")
+
+		_, _, _, err = New(Opt{}).Edit(ctx, src, EditOpt{
+			RemovePlatforms: []ocispecs.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64"}},
+		})
+		require.ErrorContains(t, err, "cannot remove all the platforms")
+	})
+}
+
+// newTestIndex writes an OCI layout with an index of a linux/amd64 image, its
+// attestation manifest and a linux/arm64 image.
+func newTestIndex(t *testing.T) (*Location, ocispecs.Descriptor) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+	store, err := contentlocal.NewStore(dir)
+	require.NoError(t, err)
+
+	writeBlob := func(mt string, v any) ocispecs.Descriptor {
+		dt, err := json.Marshal(v)
+		require.NoError(t, err)
+		desc := ocispecs.Descriptor{MediaType: mt, Digest: digest.FromBytes(dt), Size: int64(len(dt))}
+		require.NoError(t, content.WriteBlob(ctx, store, desc.Digest.String(), bytes.NewReader(dt), desc))
+		return desc
+	}
+	writeImage := func(p ocispecs.Platform) ocispecs.Descriptor {
+		desc := writeBlob(ocispecs.MediaTypeImageManifest, ocispecs.Manifest{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispecs.MediaTypeImageManifest,
+			Config:    writeBlob(ocispecs.MediaTypeImageConfig, ocispecs.Image{Platform: p}),
+			Layers:    []ocispecs.Descriptor{},
+		})
+		desc.Platform = &p
+		return desc
+	}
+
+	amd64 := writeImage(ocispecs.Platform{OS: "linux", Architecture: "amd64"})
+	arm64 := writeImage(ocispecs.Platform{OS: "linux", Architecture: "arm64"})
+	att := writeBlob(ocispecs.MediaTypeImageManifest, ocispecs.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispecs.MediaTypeImageManifest,
+		Config:    writeBlob(ocispecs.MediaTypeImageConfig, map[string]any{}),
+		Layers:    []ocispecs.Descriptor{},
+	})
+	att.Platform = &ocispecs.Platform{OS: "unknown", Architecture: "unknown"}
+	att.Annotations = map[string]string{
+		attestation.DockerAnnotationReferenceType:   attestation.DockerAnnotationReferenceTypeDefault,
+		attestation.DockerAnnotationReferenceDigest: amd64.Digest.String(),
+	}
+
+	idx := writeBlob(ocispecs.MediaTypeImageIndex, ocispecs.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispecs.MediaTypeImageIndex,
+		Manifests: []ocispecs.Descriptor{amd64, att, arm64},
+		Annotations: map[string]string{
+			"org.opencontainers.image.created": "2025-01-01T00:00:00Z",
+		},
+	})
+	require.NoError(t, ociindex.NewStoreIndex(dir).Put(idx, ociindex.Tag("latest")))
+
+	loc, err := ParseLocation("oci-layout://" + dir + ":latest")
+	require.NoError(t, err)
+	return loc, idx
+}
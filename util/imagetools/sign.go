@@ -0,0 +1,227 @@
+package imagetools
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/containerd/v2/core/remotes"
+	"github.com/moby/buildkit/client/ociindex"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+const (
+	annotationBundleContent       = "dev.sigstore.bundle.content"
+	annotationBundlePredicateType = "dev.sigstore.bundle.predicateType"
+
+	bundleContentMessageSignature = "message-signature"
+	cosignSignPredicateType       = "https://sigstore.dev/cosign/sign/v1"
+)
+
+// SignedManifest is a manifest signed by Sign and the signature manifest
+// pushed as its referrer.
+type SignedManifest struct {
+	Subject   ocispecs.Descriptor `json:"subject"`
+	Signature ocispecs.Descriptor `json:"signature"`
+}
+
+// LoadSigningKey loads a PEM encoded private key. Keys encrypted by cosign
+// are decrypted with the password returned by pf.
+func LoadSigningKey(path string, pf cryptoutils.PassFunc) (signature.Signer, error) {
+	dt, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read signing key")
+	}
+	priv, err := cryptoutils.UnmarshalPEMToPrivateKey(dt, pf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load signing key %s", path)
+	}
+	return signature.LoadDefaultSigner(priv)
+}
+
+// Sign signs the image at the given reference and pushes the signatures as
+// sigstore bundle referrers. For an index, every image and attestation
+// manifest of the index is signed.
+func (r *Resolver) Sign(ctx context.Context, in string, signer signature.Signer) ([]SignedManifest, error) {
+	loc, err := ParseLocation(in)
+	if err != nil {
+		return nil, err
+	}
+	dt, desc, err := r.Get(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	subjects := []ocispecs.Descriptor{desc}
+	if images.IsIndexType(desc.MediaType) {
+		var idx ocispecs.Index
+		if err := json.Unmarshal(dt, &idx); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		subjects = subjects[:0]
+		for _, m := range idx.Manifests {
+			if images.IsManifestType(m.MediaType) {
+				subjects = append(subjects, m)
+			}
+		}
+	}
+
+	out := make([]SignedManifest, 0, len(subjects))
+	for _, subject := range subjects {
+		sdt := dt
+		if subject.Digest != desc.Digest {
+			if sdt, err = r.GetDescriptor(ctx, loc, subject); err != nil {
+				return nil, err
+			}
+		}
+		bundleBytes, err := newSignatureBundle(signer, sdt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to sign %s", subject.Digest)
+		}
+		sig, err := r.pushSignature(ctx, loc, subject, bundleBytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to push signature for %s", subject.Digest)
+		}
+		subject.Annotations = nil
+		out = append(out, SignedManifest{Subject: subject, Signature: sig})
+	}
+	return out, nil
+}
+
+// newSignatureBundle signs dt and returns the JSON encoded sigstore bundle
+// holding the signature. The signing key is only identified by a hint, the
+// verifier needs to know the public key.
+func newSignatureBundle(signer signature.Signer, dt []byte) ([]byte, error) {
+	sig, err := signer.SignMessage(bytes.NewReader(dt))
+	if err != nil {
+		return nil, err
+	}
+	pub, err := signer.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	hint, err := publicKeyHint(pub)
+	if err != nil {
+		return nil, err
+	}
+	dgst := sha256.Sum256(dt)
+	b, err := bundle.NewBundle(&protobundle.Bundle{
+		MediaType: artifactTypeSigstoreBundle,
+		VerificationMaterial: &protobundle.VerificationMaterial{
+			Content: &protobundle.VerificationMaterial_PublicKey{
+				PublicKey: &protocommon.PublicKeyIdentifier{Hint: hint},
+			},
+		},
+		Content: &protobundle.Bundle_MessageSignature{
+			MessageSignature: &protocommon.MessageSignature{
+				MessageDigest: &protocommon.HashOutput{
+					Algorithm: protocommon.HashAlgorithm_SHA2_256,
+					Digest:    dgst[:],
+				},
+				Signature: sig,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.MarshalJSON()
+}
+
+// publicKeyHint returns the hex encoded SHA-256 digest of the DER encoding of
+// a public key.
+func publicKeyHint(pub crypto.PublicKey) (string, error) {
+	der, err := cryptoutils.MarshalPublicKeyToDER(pub)
+	if err != nil {
+		return "", err
+	}
+	dgst := sha256.Sum256(der)
+	return hex.EncodeToString(dgst[:]), nil
+}
+
+// pushSignature pushes a signature manifest holding a sigstore bundle as a
+// referrer of subject.
+func (r *Resolver) pushSignature(ctx context.Context, loc *Location, subject ocispecs.Descriptor, bundleBytes []byte) (ocispecs.Descriptor, error) {
+	layer := ocispecs.Descriptor{
+		MediaType: artifactTypeSigstoreBundle,
+		Digest:    digest.FromBytes(bundleBytes),
+		Size:      int64(len(bundleBytes)),
+	}
+	mfst := ocispecs.Manifest{
+		Versioned:    specs.Versioned{SchemaVersion: 2},
+		MediaType:    ocispecs.MediaTypeImageManifest,
+		ArtifactType: artifactTypeSigstoreBundle,
+		Config:       ocispecs.DescriptorEmptyJSON,
+		Layers:       []ocispecs.Descriptor{layer},
+		Subject: &ocispecs.Descriptor{
+			MediaType: subject.MediaType,
+			Digest:    subject.Digest,
+			Size:      subject.Size,
+		},
+		Annotations: map[string]string{
+			annotationBundleContent:       bundleContentMessageSignature,
+			annotationBundlePredicateType: cosignSignPredicateType,
+		},
+	}
+	dt, err := json.MarshalIndent(mfst, "", "  ")
+	if err != nil {
+		return ocispecs.Descriptor{}, errors.WithStack(err)
+	}
+	desc := ocispecs.Descriptor{
+		MediaType:    ocispecs.MediaTypeImageManifest,
+		ArtifactType: artifactTypeSigstoreBundle,
+		Digest:       digest.FromBytes(dt),
+		Size:         int64(len(dt)),
+	}
+
+	ingester, err := r.IngesterForLocation(ctx, loc)
+	if err != nil {
+		return ocispecs.Descriptor{}, err
+	}
+	for _, blob := range []struct {
+		desc ocispecs.Descriptor
+		dt   []byte
+	}{
+		{desc: ocispecs.DescriptorEmptyJSON, dt: ocispecs.DescriptorEmptyJSON.Data},
+		{desc: layer, dt: bundleBytes},
+	} {
+		if err := content.WriteBlob(ctx, ingester, remotes.MakeRefKey(ctx, blob.desc), bytes.NewReader(blob.dt), blob.desc); err != nil {
+			return ocispecs.Descriptor{}, err
+		}
+	}
+
+	if loc.IsOCILayout() {
+		if err := content.WriteBlob(ctx, ingester, remotes.MakeRefKey(ctx, desc), bytes.NewReader(dt), desc); err != nil {
+			return ocispecs.Descriptor{}, err
+		}
+		idx := ociindex.NewStoreIndex(loc.OCILayout().Path)
+		if err := putSubjectReferrerIndexEntry(idx, nil, subject.Digest, desc); err != nil {
+			return ocispecs.Descriptor{}, err
+		}
+		return desc, nil
+	}
+
+	// push by digest so that the tag of the signed image is not moved
+	ref, err := ParseLocation(loc.Name() + "@" + desc.Digest.String())
+	if err != nil {
+		return ocispecs.Descriptor{}, err
+	}
+	if err := r.Push(ctx, ref, desc, dt); err != nil {
+		return ocispecs.Descriptor{}, err
+	}
+	return desc, nil
+}
@@ -0,0 +1,285 @@
+package imagetools
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/content"
+	contentlocal "github.com/containerd/containerd/v2/plugins/content/local"
+	"github.com/moby/buildkit/client/ociindex"
+	"github.com/moby/buildkit/util/attestation"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	sigdsse "github.com/sigstore/sigstore/pkg/signature/dsse"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureBundle(t *testing.T) {
+	t.Parallel()
+
+	artifact := []byte(`{"schemaVersion":2}`)
+	for name, newKey := range map[string]func(t *testing.T) (signature.Signer, trustedKey){
+		"ecdsa":   newECDSAKey,
+		"ed25519": newED25519Key,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			signer, key := newKey(t)
+			_, other := newKey(t)
+
+			dt, err := newSignatureBundle(signer, artifact)
+			require.NoError(t, err)
+			var b bundle.Bundle
+			require.NoError(t, b.UnmarshalJSON(dt))
+
+			name, err := verifyBundle(&b, []trustedKey{other, key}, artifact)
+			require.NoError(t, err)
+			require.Equal(t, key.name, name)
+
+			_, err = verifyBundle(&b, []trustedKey{other}, artifact)
+			require.ErrorContains(t, err, "not signed by a trusted key")
+
+			_, err = verifyBundle(&b, []trustedKey{key}, []byte(`{"schemaVersion":3}`))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestVerifyEnvelope(t *testing.T) {
+	t.Parallel()
+
+	signer, key := newECDSAKey(t)
+	_, other := newECDSAKey(t)
+	subject := digest.FromString("image")
+
+	pub, err := signer.PublicKey()
+	require.NoError(t, err)
+	es, err := dsse.NewEnvelopeSigner(&sigdsse.SignerAdapter{SignatureSigner: signer, Pub: pub})
+	require.NoError(t, err)
+	stmt, err := json.Marshal(map[string]any{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": "https://slsa.dev/provenance/v0.2",
+		"subject": []map[string]any{
+			{"name": "_", "digest": map[string]string{"sha256": subject.Encoded()}},
+		},
+		"predicate": map[string]any{},
+	})
+	require.NoError(t, err)
+	env, err := es.SignPayload(context.TODO(), "application/vnd.in-toto+json", stmt)
+	require.NoError(t, err)
+	dt, err := json.Marshal(env)
+	require.NoError(t, err)
+
+	name, err := verifyEnvelope(context.TODO(), dt, []trustedKey{other, key}, subject)
+	require.NoError(t, err)
+	require.Equal(t, key.name, name)
+
+	_, err = verifyEnvelope(context.TODO(), dt, []trustedKey{other}, subject)
+	require.ErrorContains(t, err, "not signed by a trusted key")
+
+	_, err = verifyEnvelope(context.TODO(), dt, []trustedKey{key}, digest.FromString("other"))
+	require.ErrorContains(t, err, "statement subject does not match")
+}
+
+func TestSignVerifyOCILayout(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.TODO()
+	dir := t.TempDir()
+	store, err := contentlocal.NewStore(dir)
+	require.NoError(t, err)
+
+	writeBlob := func(mt string, v any) ocispecs.Descriptor {
+		dt, err := json.Marshal(v)
+		require.NoError(t, err)
+		desc := ocispecs.Descriptor{MediaType: mt, Digest: digest.FromBytes(dt), Size: int64(len(dt))}
+		require.NoError(t, content.WriteBlob(ctx, store, desc.Digest.String(), bytes.NewReader(dt), desc))
+		return desc
+	}
+	config := writeBlob(ocispecs.MediaTypeImageConfig, ocispecs.Image{
+		Platform: ocispecs.Platform{OS: "linux", Architecture: "amd64"},
+	})
+	img := writeBlob(ocispecs.MediaTypeImageManifest, ocispecs.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispecs.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispecs.Descriptor{},
+	})
+	require.NoError(t, ociindex.NewStoreIndex(dir).Put(img, ociindex.Tag("latest")))
+
+	privPEM, pubPEM, err := cryptoutils.GeneratePEMEncodedECDSAKeyPair(elliptic.P256(), cryptoutils.SkipPassword)
+	require.NoError(t, err)
+	keyDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(keyDir, "key.pem"), privPEM, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(keyDir, "key.pub"), pubPEM, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(keyDir, "trust.json"), []byte(`{"keys":[{"name":"release","path":"key.pub"}]}`), 0o600))
+
+	signer, err := LoadSigningKey(filepath.Join(keyDir, "key.pem"), nil)
+	require.NoError(t, err)
+
+	ref := "oci-layout://" + dir + ":latest"
+
+	cfg, err := LoadTrustConfig(filepath.Join(keyDir, "trust.json"))
+	require.NoError(t, err)
+	res, err := New(Opt{}).Verify(ctx, ref, cfg)
+	require.NoError(t, err)
+	require.False(t, res.Verified)
+	require.Len(t, res.Platforms, 1)
+	require.Empty(t, res.Platforms[0].Signatures)
+
+	signed, err := New(Opt{}).Sign(ctx, ref, signer)
+	require.NoError(t, err)
+	require.Len(t, signed, 1)
+	require.Equal(t, img.Digest, signed[0].Subject.Digest)
+
+	res, err = New(Opt{}).Verify(ctx, ref, cfg)
+	require.NoError(t, err)
+	require.True(t, res.Verified)
+	require.Len(t, res.Platforms, 1)
+	require.Equal(t, "linux/amd64", res.Platforms[0].Platform)
+	require.Len(t, res.Platforms[0].Signatures, 1)
+	require.Equal(t, "release", res.Platforms[0].Signatures[0].Key)
+	require.Equal(t, signed[0].Signature.Digest, res.Platforms[0].Signatures[0].Digest)
+
+	_, otherPub, err := cryptoutils.GeneratePEMEncodedECDSAKeyPair(elliptic.P256(), cryptoutils.SkipPassword)
+	require.NoError(t, err)
+	res, err = New(Opt{}).Verify(ctx, ref, &TrustConfig{Keys: []TrustedKey{{PublicKey: string(otherPub)}}})
+	require.NoError(t, err)
+	require.False(t, res.Verified)
+	require.Len(t, res.Platforms[0].Signatures, 1)
+	require.NotEmpty(t, res.Platforms[0].Signatures[0].Error)
+}
+
+func TestVerifySplicedAttestation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.TODO()
+	dir := t.TempDir()
+	store, err := contentlocal.NewStore(dir)
+	require.NoError(t, err)
+
+	writeBlob := func(mt string, v any) ocispecs.Descriptor {
+		dt, err := json.Marshal(v)
+		require.NoError(t, err)
+		desc := ocispecs.Descriptor{MediaType: mt, Digest: digest.FromBytes(dt), Size: int64(len(dt))}
+		require.NoError(t, content.WriteBlob(ctx, store, desc.Digest.String(), bytes.NewReader(dt), desc))
+		return desc
+	}
+	writeImage := func(arch string) ocispecs.Descriptor {
+		config := writeBlob(ocispecs.MediaTypeImageConfig, ocispecs.Image{
+			Platform: ocispecs.Platform{OS: "linux", Architecture: arch},
+		})
+		img := writeBlob(ocispecs.MediaTypeImageManifest, ocispecs.Manifest{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispecs.MediaTypeImageManifest,
+			Config:    config,
+			Layers:    []ocispecs.Descriptor{},
+		})
+		img.Platform = &ocispecs.Platform{OS: "linux", Architecture: arch}
+		return img
+	}
+	attestationDesc := func(att ocispecs.Descriptor, subject digest.Digest) ocispecs.Descriptor {
+		att.Platform = &ocispecs.Platform{OS: "unknown", Architecture: "unknown"}
+		att.Annotations = map[string]string{
+			attestation.DockerAnnotationReferenceType:   attestation.DockerAnnotationReferenceTypeDefault,
+			attestation.DockerAnnotationReferenceDigest: subject.String(),
+		}
+		return att
+	}
+	writeIndex := func(tag string, manifests ...ocispecs.Descriptor) {
+		idx := writeBlob(ocispecs.MediaTypeImageIndex, ocispecs.Index{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispecs.MediaTypeImageIndex,
+			Manifests: manifests,
+		})
+		require.NoError(t, ociindex.NewStoreIndex(dir).Put(idx, ociindex.Tag(tag)))
+	}
+
+	signed := writeImage("amd64")
+	stmt := writeBlob(inTotoGenericMime, map[string]any{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": "https://slsa.dev/provenance/v0.2",
+		"subject": []map[string]any{
+			{"name": "pkg:docker/app@latest", "digest": map[string]string{"sha256": signed.Digest.Encoded()}},
+		},
+		"predicate": map[string]any{},
+	})
+	stmt.Annotations = map[string]string{annotationInTotoPredicateType: "https://slsa.dev/provenance/v0.2"}
+	att := writeBlob(ocispecs.MediaTypeImageManifest, ocispecs.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispecs.MediaTypeImageManifest,
+		Config:    writeBlob("application/vnd.oci.image.config.v1+json", map[string]any{}),
+		Layers:    []ocispecs.Descriptor{stmt},
+	})
+	writeIndex("latest", signed, attestationDesc(att, signed.Digest))
+
+	signer, key := newECDSAKey(t)
+	pub, err := key.verifier.PublicKey()
+	require.NoError(t, err)
+	pubPEM, err := cryptoutils.MarshalPublicKeyToPEM(pub)
+	require.NoError(t, err)
+	cfg := &TrustConfig{Keys: []TrustedKey{{Name: "release", PublicKey: string(pubPEM)}}}
+
+	res, err := New(Opt{}).Sign(ctx, "oci-layout://"+dir+":latest", signer)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+
+	vr, err := New(Opt{}).Verify(ctx, "oci-layout://"+dir+":latest", cfg)
+	require.NoError(t, err)
+	require.True(t, vr.Verified)
+	require.Len(t, vr.Platforms, 1)
+	require.Len(t, vr.Platforms[0].Signatures, 2)
+	for _, s := range vr.Platforms[0].Signatures {
+		require.Empty(t, s.Error)
+	}
+
+	// the signed attestation manifest is copied next to an unsigned image
+	unsigned := writeImage("arm64")
+	writeIndex("spliced", unsigned, attestationDesc(att, unsigned.Digest))
+
+	vr, err = New(Opt{}).Verify(ctx, "oci-layout://"+dir+":spliced", cfg)
+	require.NoError(t, err)
+	require.False(t, vr.Verified)
+	require.Len(t, vr.Platforms, 1)
+	require.Equal(t, unsigned.Digest, vr.Platforms[0].Digest)
+	require.False(t, vr.Platforms[0].Verified)
+	require.Len(t, vr.Platforms[0].Signatures, 1)
+	require.Equal(t, att.Digest, vr.Platforms[0].Signatures[0].Subject)
+	require.Contains(t, vr.Platforms[0].Signatures[0].Error, "statement subject does not match "+unsigned.Digest.String())
+}
+
+func newECDSAKey(t *testing.T) (signature.Signer, trustedKey) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return newTestKey(t, priv, priv.Public())
+}
+
+func newED25519Key(t *testing.T) (signature.Signer, trustedKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return newTestKey(t, priv, pub)
+}
+
+func newTestKey(t *testing.T, priv, pub any) (signature.Signer, trustedKey) {
+	signer, err := signature.LoadDefaultSigner(priv)
+	require.NoError(t, err)
+	verifier, err := signature.LoadDefaultVerifier(pub)
+	require.NoError(t, err)
+	hint, err := publicKeyHint(pub)
+	require.NoError(t, err)
+	return signer, trustedKey{name: hint[:12], verifier: verifier}
+}
@@ -0,0 +1,534 @@
+package imagetools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/containerd/v2/core/remotes"
+	"github.com/containerd/errdefs"
+	"github.com/containerd/platforms"
+	"github.com/moby/buildkit/util/attestation"
+	"github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	sigdsse "github.com/sigstore/sigstore/pkg/signature/dsse"
+)
+
+const annotationInTotoPredicateType = "in-toto.io/predicate-type"
+
+// TrustConfig is the set of public keys that signatures and attestations are
+// verified against.
+type TrustConfig struct {
+	Keys []TrustedKey `json:"keys"`
+}
+
+// TrustedKey is a PEM encoded public key, either read from a file or set
+// inline.
+type TrustedKey struct {
+	Name string `json:"name,omitempty"`
+	// Path is the path of the public key file. Relative paths are relative to
+	// the trust config file.
+	Path      string `json:"path,omitempty"`
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+// LoadTrustConfig reads a JSON trust config file.
+func LoadTrustConfig(path string) (*TrustConfig, error) {
+	dt, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read trust config")
+	}
+	var cfg TrustConfig
+	if err := json.Unmarshal(dt, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse trust config %s", path)
+	}
+	for i, k := range cfg.Keys {
+		if k.Path != "" && !filepath.IsAbs(k.Path) {
+			cfg.Keys[i].Path = filepath.Join(filepath.Dir(path), k.Path)
+		}
+	}
+	return &cfg, nil
+}
+
+type trustedKey struct {
+	name     string
+	verifier signature.Verifier
+}
+
+func (c *TrustConfig) verifiers() ([]trustedKey, error) {
+	if c == nil || len(c.Keys) == 0 {
+		return nil, errors.New("no trusted keys configured")
+	}
+	out := make([]trustedKey, 0, len(c.Keys))
+	for i, k := range c.Keys {
+		name := k.Name
+		dt := []byte(k.PublicKey)
+		switch {
+		case k.Path != "" && k.PublicKey != "":
+			return nil, errors.Errorf("trusted key %d sets both path and publicKey", i)
+		case k.Path != "":
+			var err error
+			if dt, err = os.ReadFile(k.Path); err != nil {
+				return nil, errors.Wrapf(err, "failed to read trusted key")
+			}
+			if name == "" {
+				name = filepath.Base(k.Path)
+			}
+		case k.PublicKey == "":
+			return nil, errors.Errorf("trusted key %d sets neither path nor publicKey", i)
+		}
+		if name == "" {
+			name = "key" + strconv.Itoa(i)
+		}
+		pub, err := cryptoutils.UnmarshalPEMToPublicKey(dt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load trusted key %s", name)
+		}
+		v, err := signature.LoadDefaultVerifier(pub)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load trusted key %s", name)
+		}
+		out = append(out, trustedKey{name: name, verifier: v})
+	}
+	return out, nil
+}
+
+// VerifyResult is the result of the verification of an image or index.
+type VerifyResult struct {
+	Name   string        `json:"name"`
+	Digest digest.Digest `json:"digest"`
+	// Verified is true if all the platforms are verified.
+	Verified  bool                   `json:"verified"`
+	Platforms []PlatformVerification `json:"platforms"`
+}
+
+// PlatformVerification is the result of the verification of the image of a
+// platform. The image is verified if it has at least one valid signature on
+// the image manifest, and all its DSSE attestations are valid. Signatures of
+// the attestation manifest are only valid if all its statements are about
+// the image.
+type PlatformVerification struct {
+	Platform     string                    `json:"platform"`
+	Digest       digest.Digest             `json:"digest"`
+	Verified     bool                      `json:"verified"`
+	Signatures   []SignatureVerification   `json:"signatures,omitempty"`
+	Attestations []AttestationVerification `json:"attestations,omitempty"`
+}
+
+// SignatureVerification is the result of the verification of a signature
+// manifest.
+type SignatureVerification struct {
+	// Subject is the digest of the signed manifest.
+	Subject digest.Digest `json:"subject"`
+	// Digest is the digest of the signature manifest.
+	Digest digest.Digest `json:"digest"`
+	// Key is the name of the trusted key that verified the signature.
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// AttestationVerification is the result of the verification of a DSSE
+// attestation, stored either as a layer of the attestation manifest or as a
+// sigstore bundle referrer of the image.
+type AttestationVerification struct {
+	PredicateType string        `json:"predicateType,omitempty"`
+	Digest        digest.Digest `json:"digest"`
+	Key           string        `json:"key,omitempty"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// Verify checks the signatures and DSSE attestations of the image at the
+// given reference, or of every platform of an index, against the keys of
+// the trust config.
+func (r *Resolver) Verify(ctx context.Context, in string, cfg *TrustConfig) (*VerifyResult, error) {
+	keys, err := cfg.verifiers()
+	if err != nil {
+		return nil, err
+	}
+	loc, err := ParseLocation(in)
+	if err != nil {
+		return nil, err
+	}
+	dt, desc, err := r.Get(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &VerifyResult{
+		Name:     in,
+		Digest:   desc.Digest,
+		Verified: true,
+	}
+
+	type target struct {
+		desc ocispecs.Descriptor
+		att  *ocispecs.Descriptor
+	}
+	var targets []target
+	if images.IsIndexType(desc.MediaType) {
+		var idx ocispecs.Index
+		if err := json.Unmarshal(dt, &idx); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		atts := map[string]ocispecs.Descriptor{}
+		for _, m := range idx.Manifests {
+			if isAttestationManifest(m) {
+				atts[m.Annotations[attestation.DockerAnnotationReferenceDigest]] = m
+			}
+		}
+		for _, m := range idx.Manifests {
+			if !images.IsManifestType(m.MediaType) || isAttestationManifest(m) {
+				continue
+			}
+			t := target{desc: m}
+			if att, ok := atts[m.Digest.String()]; ok {
+				t.att = &att
+			}
+			targets = append(targets, t)
+		}
+	} else {
+		targets = append(targets, target{desc: desc})
+	}
+	if len(targets) == 0 {
+		return nil, errors.Errorf("no images found in %s", in)
+	}
+
+	for _, t := range targets {
+		pv, err := r.verifyPlatform(ctx, loc, keys, t.desc, t.att)
+		if err != nil {
+			return nil, err
+		}
+		if !pv.Verified {
+			out.Verified = false
+		}
+		out.Platforms = append(out.Platforms, *pv)
+	}
+	return out, nil
+}
+
+func (r *Resolver) verifyPlatform(ctx context.Context, loc *Location, keys []trustedKey, desc ocispecs.Descriptor, att *ocispecs.Descriptor) (*PlatformVerification, error) {
+	dt, err := r.GetDescriptor(ctx, loc, desc)
+	if err != nil {
+		return nil, err
+	}
+	out := &PlatformVerification{
+		Platform: "unknown",
+		Digest:   desc.Digest,
+	}
+	if desc.Platform != nil {
+		out.Platform = platforms.FormatAll(*desc.Platform)
+	} else if p, err := r.manifestPlatform(ctx, loc, dt); err == nil && p != nil {
+		out.Platform = platforms.FormatAll(*p)
+	}
+
+	sigs, atts, err := r.verifyReferrers(ctx, loc, keys, desc, dt)
+	if err != nil {
+		return nil, err
+	}
+	out.Signatures = append(out.Signatures, sigs...)
+	out.Attestations = append(out.Attestations, atts...)
+
+	if att != nil {
+		attBytes, err := r.GetDescriptor(ctx, loc, *att)
+		if err != nil {
+			return nil, err
+		}
+		atts, err := r.verifyAttestationLayers(ctx, loc, keys, desc, attBytes)
+		if err != nil {
+			return nil, err
+		}
+		out.Attestations = append(out.Attestations, atts...)
+
+		sigs, atts, err := r.verifyReferrers(ctx, loc, keys, *att, attBytes)
+		if err != nil {
+			return nil, err
+		}
+		// the attestation manifest is only linked to the image by the
+		// annotations of the index, a signature of it does not vouch for an
+		// image it was not built with
+		if err := r.checkAttestationSubject(ctx, loc, desc.Digest, attBytes); err != nil {
+			for i := range sigs {
+				if sigs[i].Error == "" {
+					sigs[i].Error = err.Error()
+				}
+			}
+		}
+		out.Signatures = append(out.Signatures, sigs...)
+		out.Attestations = append(out.Attestations, atts...)
+	}
+
+	for _, s := range out.Signatures {
+		if s.Error == "" && s.Subject == desc.Digest {
+			out.Verified = true
+			break
+		}
+	}
+	for _, a := range out.Attestations {
+		if a.Error != "" {
+			out.Verified = false
+			break
+		}
+	}
+	return out, nil
+}
+
+func (r *Resolver) manifestPlatform(ctx context.Context, loc *Location, dt []byte) (*ocispecs.Platform, error) {
+	var mfst ocispecs.Manifest
+	if err := json.Unmarshal(dt, &mfst); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cdt, err := r.GetDescriptor(ctx, loc, mfst.Config)
+	if err != nil {
+		return nil, err
+	}
+	var p ocispecs.Platform
+	if err := json.Unmarshal(cdt, &p); err != nil || p.OS == "" {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// verifyReferrers verifies the sigstore bundle referrers of a manifest.
+// Bundles holding a message signature are reported as signatures, bundles
+// holding a DSSE envelope as attestations.
+func (r *Resolver) verifyReferrers(ctx context.Context, loc *Location, keys []trustedKey, subject ocispecs.Descriptor, subjectBytes []byte) ([]SignatureVerification, []AttestationVerification, error) {
+	refs, err := r.FetchReferrers(ctx, loc, subject.Digest, remotes.WithReferrerArtifactTypes(artifactTypeSigstoreBundle))
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, errors.Wrapf(err, "failed to fetch referrers of %s", subject.Digest)
+	}
+
+	var (
+		sigs []SignatureVerification
+		atts []AttestationVerification
+	)
+	for _, ref := range refs {
+		if ref.ArtifactType != artifactTypeSigstoreBundle {
+			continue
+		}
+		b, err := r.loadSignatureBundle(ctx, loc, subject, ref)
+		var key string
+		if err == nil {
+			key, err = verifyBundle(b, keys, subjectBytes)
+		}
+		if b != nil && b.GetDsseEnvelope() != nil {
+			a := AttestationVerification{
+				PredicateType: envelopePredicateType(b.GetDsseEnvelope().GetPayload()),
+				Digest:        ref.Digest,
+				Key:           key,
+			}
+			if err != nil {
+				a.Error = err.Error()
+			}
+			atts = append(atts, a)
+			continue
+		}
+		s := SignatureVerification{
+			Subject: subject.Digest,
+			Digest:  ref.Digest,
+			Key:     key,
+		}
+		if err != nil {
+			s.Error = err.Error()
+		}
+		sigs = append(sigs, s)
+	}
+	return sigs, atts, nil
+}
+
+func (r *Resolver) loadSignatureBundle(ctx context.Context, loc *Location, subject, desc ocispecs.Descriptor) (*bundle.Bundle, error) {
+	dt, err := r.GetDescriptor(ctx, loc, desc)
+	if err != nil {
+		return nil, err
+	}
+	var mfst ocispecs.Manifest
+	if err := json.Unmarshal(dt, &mfst); err != nil {
+		return nil, errors.Wrapf(err, "invalid signature manifest")
+	}
+	if mfst.Subject == nil || mfst.Subject.Digest != subject.Digest {
+		return nil, errors.Errorf("signature manifest subject does not match %s", subject.Digest)
+	}
+	if len(mfst.Layers) != 1 || mfst.Layers[0].MediaType != artifactTypeSigstoreBundle {
+		return nil, errors.Errorf("signature manifest does not hold a sigstore bundle")
+	}
+	bdt, err := r.GetDescriptor(ctx, loc, mfst.Layers[0])
+	if err != nil {
+		return nil, err
+	}
+	var b bundle.Bundle
+	if err := b.UnmarshalJSON(bdt); err != nil {
+		return nil, errors.Wrapf(err, "invalid sigstore bundle")
+	}
+	return &b, nil
+}
+
+// verifyBundle verifies a sigstore bundle signed with a public key against
+// the artifact it signs. It returns the name of the trusted key that
+// verified the bundle.
+func verifyBundle(b *bundle.Bundle, keys []trustedKey, artifact []byte) (string, error) {
+	if b.VerificationMaterial.GetPublicKey() == nil {
+		return "", errors.New("bundle is not signed with a public key")
+	}
+	var lastErr error
+	for _, k := range keys {
+		tm := root.NewTrustedPublicKeyMaterial(func(string) (root.TimeConstrainedVerifier, error) {
+			return root.NewExpiringKey(k.verifier, time.Time{}, time.Time{}), nil
+		})
+		v, err := verify.NewVerifier(tm, verify.WithNoObserverTimestamps())
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if _, err := v.Verify(b, verify.NewPolicy(verify.WithArtifact(bytes.NewReader(artifact)), verify.WithKey())); err != nil {
+			lastErr = err
+			continue
+		}
+		return k.name, nil
+	}
+	return "", errors.Wrap(lastErr, "not signed by a trusted key")
+}
+
+// verifyAttestationLayers verifies the DSSE envelopes of the layers of an
+// attestation manifest and checks that their statements are about the
+// image.
+func (r *Resolver) verifyAttestationLayers(ctx context.Context, loc *Location, keys []trustedKey, image ocispecs.Descriptor, dt []byte) ([]AttestationVerification, error) {
+	var mfst ocispecs.Manifest
+	if err := json.Unmarshal(dt, &mfst); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var out []AttestationVerification
+	for _, layer := range mfst.Layers {
+		if !isInTotoDSSE(layer.MediaType) {
+			continue
+		}
+		ldt, err := r.GetDescriptor(ctx, loc, layer)
+		if err != nil {
+			return nil, err
+		}
+		a := AttestationVerification{
+			PredicateType: layer.Annotations[annotationInTotoPredicateType],
+			Digest:        layer.Digest,
+		}
+		if a.Key, err = verifyEnvelope(ctx, ldt, keys, image.Digest); err != nil {
+			a.Error = err.Error()
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// checkAttestationSubject checks that all the in-toto statements of an
+// attestation manifest have the image as subject.
+func (r *Resolver) checkAttestationSubject(ctx context.Context, loc *Location, image digest.Digest, dt []byte) error {
+	var mfst ocispecs.Manifest
+	if err := json.Unmarshal(dt, &mfst); err != nil {
+		return errors.Wrapf(err, "invalid attestation manifest")
+	}
+	var n int
+	for _, layer := range mfst.Layers {
+		if layer.MediaType != inTotoGenericMime && !isInTotoDSSE(layer.MediaType) {
+			continue
+		}
+		ldt, err := r.GetDescriptor(ctx, loc, layer)
+		if err != nil {
+			return err
+		}
+		payload, err := decodeDSSE(ldt, layer.MediaType)
+		if err != nil {
+			return errors.Wrapf(err, "invalid attestation %s", layer.Digest)
+		}
+		if err := checkStatementSubject(payload, image); err != nil {
+			return errors.Wrapf(err, "attestation %s", layer.Digest)
+		}
+		n++
+	}
+	if n == 0 {
+		return errors.Errorf("attestation manifest has no statement about %s", image)
+	}
+	return nil
+}
+
+// verifyEnvelope verifies a JSON encoded DSSE envelope holding an in-toto
+// statement about subject. It returns the name of the trusted key that
+// verified the envelope.
+func verifyEnvelope(ctx context.Context, dt []byte, keys []trustedKey, subject digest.Digest) (string, error) {
+	var env dsse.Envelope
+	if err := json.Unmarshal(dt, &env); err != nil {
+		return "", errors.Wrapf(err, "invalid DSSE envelope")
+	}
+	verifiers := make([]dsse.Verifier, 0, len(keys))
+	for _, k := range keys {
+		pub, err := k.verifier.PublicKey()
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		verifiers = append(verifiers, &sigdsse.VerifierAdapter{SignatureVerifier: k.verifier, Pub: pub, PubKeyID: k.name})
+	}
+	ev, err := dsse.NewEnvelopeVerifier(verifiers...)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	accepted, payload, err := ev.VerifyAndDecode(ctx, &env)
+	if err != nil {
+		return "", errors.Wrap(err, "not signed by a trusted key")
+	}
+
+	if err := checkStatementSubject(payload, subject); err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(accepted))
+	for _, a := range accepted {
+		names = append(names, a.KeyID)
+	}
+	return strings.Join(names, ", "), nil
+}
+
+// checkStatementSubject checks that subject is one of the subjects of a JSON
+// encoded in-toto statement.
+func checkStatementSubject(payload []byte, subject digest.Digest) error {
+	var stmt struct {
+		Subject []struct {
+			Digest map[string]string `json:"digest"`
+		} `json:"subject"`
+	}
+	if err := json.Unmarshal(payload, &stmt); err != nil {
+		return errors.Wrapf(err, "invalid in-toto statement")
+	}
+	for _, s := range stmt.Subject {
+		if s.Digest[subject.Algorithm().String()] == subject.Encoded() {
+			return nil
+		}
+	}
+	return errors.Errorf("statement subject does not match %s", subject)
+}
+
+func envelopePredicateType(payload []byte) string {
+	var stmt struct {
+		PredicateType string `json:"predicateType"`
+	}
+	if err := json.Unmarshal(payload, &stmt); err != nil {
+		return ""
+	}
+	return stmt.PredicateType
+}
+
+// isAttestationManifest returns true if the descriptor of an index entry is
+// the attestation manifest of another entry.
+func isAttestationManifest(desc ocispecs.Descriptor) bool {
+	return desc.Annotations[attestation.DockerAnnotationReferenceType] == attestation.DockerAnnotationReferenceTypeDefault
+}
@@ -0,0 +1,114 @@
+// Package textdiff computes line based differences between two texts.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines printed around each change.
+const DefaultContext = 3
+
+type edit struct {
+	kind byte
+	text string
+	// a and b are the indexes of the line in the old and new texts
+	a, b int
+}
+
+// Unified returns the differences between a and b in the unified diff
+// format, with the given names in the file headers. It returns an empty
+// string if the texts are equal.
+func Unified(fromName, toName, a, b string) string {
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	for i := 0; i < len(edits); {
+		if edits[i].kind == ' ' {
+			i++
+			continue
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		start := max(0, i-DefaultContext)
+		end := i
+		for j := i; j < len(edits) && j <= end+2*DefaultContext; j++ {
+			if edits[j].kind != ' ' {
+				end = j
+			}
+		}
+		end = min(len(edits), end+DefaultContext+1)
+
+		var aCount, bCount int
+		for _, e := range edits[start:end] {
+			if e.kind != '+' {
+				aCount++
+			}
+			if e.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(edits[start].a, aCount), hunkRange(edits[start].b, bCount))
+		for _, e := range edits[start:end] {
+			sb.WriteByte(e.kind)
+			sb.WriteString(e.text)
+			sb.WriteByte('\n')
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the edits transforming a into b based on their longest
+// common subsequence.
+func diffLines(a, b []string) []edit {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	edits := make([]edit, 0, max(len(a), len(b)))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{kind: ' ', text: a[i], a: i, b: j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{kind: '-', text: a[i], a: i, b: j})
+			i++
+		default:
+			edits = append(edits, edit{kind: '+', text: b[j], a: i, b: j})
+			j++
+		}
+	}
+	return edits
+}
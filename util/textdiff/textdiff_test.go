@@ -0,0 +1,37 @@
+package textdiff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnified(t *testing.T) {
+	require.Empty(t, Unified("a", "b", "foo\nbar\n", "foo\nbar\n"))
+
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\n3\nfour\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	require.Equal(t, `--- a
++++ b
+@@ -1,7 +1,7 @@
+ 1
+ 2
+ 3
+-4
++four
+ 5
+ 6
+ 7
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`, Unified("a", "b", a, b))
+
+	require.Equal(t, `--- a
++++ b
+@@ -0,0 +1 @@
++foo
+`, Unified("a", "b", "", "foo\n"))
+}